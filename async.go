@@ -0,0 +1,82 @@
+package quickjs
+
+// AsyncFunction creates a JavaScript function for a Go function that may
+// take a while, such as one doing I/O. Each call returns a promise at once
+// and runs fn on a new goroutine. When fn returns, the promise is fulfilled
+// with its Value, or rejected with an Error carrying the message of its
+// error. fn receives its own references to this and args, which stay valid
+// until it returns, and may use the runtime as any goroutine may: each
+// method waits for the runtime mutex, so it runs between the JavaScript
+// other goroutines run.
+//
+// The promise is settled on fn's goroutine, which queues its reactions as
+// pending jobs; something must then run them, as RunEventLoop does. It
+// waits for calls still running, so a script awaiting an async function
+// completes under RunEventLoop without further help. If the context is
+// closed before fn returns, the result is dropped.
+func (c *Context) AsyncFunction(name string, fn func(ctx *Context, this Value, args []Value) (Value, error)) Value {
+	return c.Function(name, func(ctx *Context, this Value, args []Value) Value {
+		r := ctx.runtime
+		b := r.bridge
+		promisePtr, resolvePtr, rejectPtr, err := b.NewPromise(r.goCtx, ctx.ctxPtr)
+		if err != nil || promisePtr == 0 {
+			for _, arg := range args {
+				arg.freeUnlocked()
+			}
+			return ctx.ThrowError("failed to create promise")
+		}
+		resolve, reject := ctx.value(resolvePtr), ctx.value(rejectPtr)
+		// The caller frees this once the function returns
+		thisPtr, _ := b.DupValue(r.goCtx, ctx.ctxPtr, this.ptr)
+		this = ctx.value(thisPtr)
+
+		r.asyncCalls++
+		go func() {
+			result, err := fn(ctx, this, args)
+			ctx.settleAsync(resolve, reject, result, err)
+			r.lock()
+			defer r.unlock()
+			this.freeUnlocked()
+			for _, arg := range args {
+				arg.freeUnlocked()
+			}
+		}()
+		return ctx.value(promisePtr)
+	})
+}
+
+// settleAsync settles the promise of an AsyncFunction call with the outcome
+// of its Go function.
+func (c *Context) settleAsync(resolve, reject, result Value, err error) {
+	r := c.runtime
+	r.lock()
+	defer r.unlock()
+	r.asyncCalls--
+	r.wakeLoop()
+	defer resolve.freeUnlocked()
+	defer reject.freeUnlocked()
+	if result.ctx != nil {
+		defer result.freeUnlocked()
+	}
+	if c.closed || r.closed {
+		return
+	}
+
+	settle, outcome := resolve, result
+	if err != nil {
+		reason, newErr := c.newGoError(err)
+		if newErr != nil {
+			return
+		}
+		defer reason.freeUnlocked()
+		settle, outcome = reject, reason
+	} else if result.ctx == nil {
+		outcome = c.undefinedUnlocked()
+		defer outcome.freeUnlocked()
+	}
+	undefined := c.undefinedUnlocked()
+	defer undefined.freeUnlocked()
+	if val, err := settle.Call(undefined, outcome); err == nil {
+		val.freeUnlocked()
+	}
+}
@@ -0,0 +1,71 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAsyncFunction(t *testing.T) {
+	rt, ctx := newTimersContext(t)
+
+	fetch := ctx.AsyncFunction("fetch", func(ctx *Context, this Value, args []Value) (Value, error) {
+		time.Sleep(10 * time.Millisecond)
+		if len(args) == 0 {
+			return Value{}, errors.New("no key")
+		}
+		return ctx.String("value of " + args[0].String()), nil
+	})
+	if err := ctx.SetGlobal("fetch", fetch); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+
+	_, err := ctx.Eval(`
+		var log = [];
+		(async () => {
+			const p = fetch("a");
+			log.push(p instanceof Promise);
+			log.push(await p);
+			try { await fetch(); } catch (e) { log.push("rejected: " + e.message); }
+			await new Promise((resolve) => setTimeout(resolve, 5));
+			log.push(await fetch("b"));
+		})();
+		log.push("sync");
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if err := rt.RunEventLoop(context.Background()); err != nil {
+		t.Fatalf("RunEventLoop error = %v", err)
+	}
+	val, err := ctx.Eval("log.join(', ')")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	want := "true, sync, value of a, rejected: no key, value of b"
+	if got := val.String(); got != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+
+	// A call still running when the loop is canceled settles later
+	slow := ctx.AsyncFunction("slow", func(ctx *Context, this Value, args []Value) (Value, error) {
+		time.Sleep(50 * time.Millisecond)
+		return ctx.Int64(1), nil
+	})
+	p, err := slow.Call(ctx.Undefined())
+	if err != nil {
+		t.Fatalf("Call error = %v", err)
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := rt.RunEventLoop(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunEventLoop error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := rt.RunEventLoop(context.Background()); err != nil {
+		t.Fatalf("RunEventLoop error = %v", err)
+	}
+	if val, err := p.Await(); err != nil || val.String() != "1" {
+		t.Errorf("Await = %v, %v; want 1", val, err)
+	}
+}
@@ -0,0 +1,77 @@
+package quickjs
+
+import "errors"
+
+// Atom is an interned property name, created with Context.Atom. Getting or
+// setting a property by atom skips the conversion of the name that Get and
+// Set do on every call, which helps code that accesses the same few
+// properties many times.
+type Atom struct {
+	rt   *Runtime
+	atom uint32
+}
+
+// Atom returns the atom for a property name. Atoms belong to the runtime,
+// so an atom can be used with values from any of its contexts, and asking
+// for the same name again returns the same atom. Atoms are kept until the
+// runtime is closed, so they suit a fixed set of names rather than names
+// built from data. The zero Atom, returned if the atom cannot be created,
+// fails when used.
+func (c *Context) Atom(name string) Atom {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	if atom, ok := c.runtime.atoms[name]; ok {
+		return Atom{rt: c.runtime, atom: atom}
+	}
+	atom, err := c.runtime.bridge.NewAtom(c.runtime.goCtx, c.ctxPtr, name)
+	if err != nil {
+		return Atom{}
+	}
+	if c.runtime.atoms == nil {
+		c.runtime.atoms = make(map[string]uint32)
+	}
+	c.runtime.atoms[name] = atom
+	return Atom{rt: c.runtime, atom: atom}
+}
+
+// GetAtom gets the property named by an atom, like Get.
+func (v Value) GetAtom(a Atom) (Value, error) {
+	if err := v.checkAtom(a); err != nil {
+		return Value{}, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	ptr, err := v.ctx.runtime.bridge.GetPropertyAtom(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, a.atom)
+	if err != nil {
+		return Value{}, err
+	}
+	return v.ctx.checkException(ptr)
+}
+
+// SetAtom sets the property named by an atom, like Set.
+func (v Value) SetAtom(a Atom, val Value) error {
+	if err := v.checkAtom(a); err != nil {
+		return err
+	}
+	if err := checkFreed(val); err != nil {
+		return err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.bridge.SetPropertyAtom(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, a.atom, val.ptr)
+}
+
+func (v Value) checkAtom(a Atom) error {
+	if err := v.usable(); err != nil {
+		return err
+	}
+	if a.rt == nil {
+		return errors.New("invalid atom")
+	}
+	if a.rt != v.ctx.runtime {
+		return errors.New("atom belongs to another runtime")
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package quickjs
+
+import "testing"
+
+func TestAtom(t *testing.T) {
+	ctx := newTestContext(t)
+
+	name := ctx.Atom("name")
+	if again := ctx.Atom("name"); again != name {
+		t.Errorf("Atom(name) returned a different atom the second time")
+	}
+
+	obj, err := ctx.Eval("({name: 'John', 'with space': 1, 0: 'zero'})")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	val, err := obj.GetAtom(name)
+	if err != nil {
+		t.Fatalf("GetAtom error = %v", err)
+	}
+	if got := val.String(); got != "John" {
+		t.Errorf("GetAtom(name) = %q, want %q", got, "John")
+	}
+	for key, want := range map[string]string{"with space": "1", "0": "zero", "missing": "undefined"} {
+		val, err := obj.GetAtom(ctx.Atom(key))
+		if err != nil {
+			t.Fatalf("GetAtom(%q) error = %v", key, err)
+		}
+		if got := val.String(); got != want {
+			t.Errorf("GetAtom(%q) = %q, want %q", key, got, want)
+		}
+		val.free()
+	}
+
+	if err := obj.SetAtom(ctx.Atom("age"), ctx.Int32(30)); err != nil {
+		t.Fatalf("SetAtom error = %v", err)
+	}
+	if err := obj.SetAtom(name, ctx.String("Jane")); err != nil {
+		t.Fatalf("SetAtom error = %v", err)
+	}
+	if got, _ := obj.JSONStringify(); got != `{"0":"zero","name":"Jane","with space":1,"age":30}` {
+		t.Errorf("object after SetAtom = %s", got)
+	}
+
+	// Getters run, and exceptions are returned
+	getter, _ := ctx.Eval("({get name() { throw new Error('boom') }})")
+	if _, err := getter.GetAtom(name); err == nil {
+		t.Error("GetAtom with a throwing getter should fail")
+	}
+	if _, err := ctx.Undefined().GetAtom(name); err == nil {
+		t.Error("GetAtom on undefined should fail")
+	}
+
+	// Atoms are shared between the runtime's contexts but not runtimes
+	other, err := ctx.runtime.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer other.Close()
+	otherObj, _ := other.Eval("({name: 'other'})")
+	if val, err := otherObj.GetAtom(name); err != nil || val.String() != "other" {
+		t.Errorf("GetAtom in another context = %v, %v", val, err)
+	}
+	if _, err := newTestContext(t).Object().GetAtom(name); err == nil {
+		t.Error("GetAtom with an atom of another runtime should fail")
+	}
+	if _, err := obj.GetAtom(Atom{}); err == nil {
+		t.Error("GetAtom with the zero Atom should fail")
+	}
+}
+
+// BenchmarkGetString benchmarks repeated property access by name
+func TestSymbolKeys(t *testing.T) {
+	ctx := newTestContext(t)
+
+	toStringTag, err := ctx.Eval("Symbol.toStringTag")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	obj := ctx.Object()
+	ctx.SetGlobal("obj", obj)
+	if err := obj.SetKey(toStringTag, ctx.String("Widget")); err != nil {
+		t.Fatalf("SetKey error = %v", err)
+	}
+	tag, err := obj.GetKey(toStringTag)
+	if err != nil || tag.String() != "Widget" {
+		t.Errorf("GetKey = %v, %v, want Widget", tag, err)
+	}
+	result, _ := ctx.Eval("Object.prototype.toString.call(obj)")
+	if result.String() != "[object Widget]" {
+		t.Errorf("toString = %s, want [object Widget]", result.String())
+	}
+
+	global, _ := ctx.Global()
+	if err := global.SetToStringTag("Sandbox"); err != nil {
+		t.Fatalf("SetToStringTag(global) error = %v", err)
+	}
+	result, _ = ctx.Eval("[String(globalThis), Object.keys(globalThis).includes(Symbol.toStringTag.toString())].join()")
+	if result.String() != "[object Sandbox],false" {
+		t.Errorf("global = %s, want [object Sandbox],false", result.String())
+	}
+
+	// The tag is read-only, so assigning it fails
+	frozen := ctx.Object()
+	frozen.SetToStringTag("Fixed")
+	if err := frozen.SetKey(toStringTag, ctx.String("Other")); err == nil {
+		t.Error("SetKey on a read-only tag should fail")
+	}
+	tag, _ = frozen.GetKey(toStringTag)
+	if tag.String() != "Fixed" {
+		t.Errorf("tag = %s, want Fixed", tag.String())
+	}
+
+	// Non-symbol keys are converted as in JavaScript
+	arr, _ := ctx.Eval("[10, 20]")
+	elem, _ := arr.GetKey(ctx.Int32(1))
+	if elem.String() != "20" {
+		t.Errorf("GetKey(1) = %s, want 20", elem.String())
+	}
+
+	if err := ctx.Int32(1).SetToStringTag("x"); err == nil {
+		t.Error("SetToStringTag on a number should fail")
+	}
+}
+
+func BenchmarkGetString(b *testing.B) {
+	ctx := newTestContext(b)
+	obj, _ := ctx.Eval("({id: 1, name: 'John', email: 'john@example.com'})")
+
+	for b.Loop() {
+		val, err := obj.Get("email")
+		if err != nil {
+			b.Fatal(err)
+		}
+		val.free()
+	}
+}
+
+// BenchmarkGetAtom benchmarks the same access with an atom
+func BenchmarkGetAtom(b *testing.B) {
+	ctx := newTestContext(b)
+	obj, _ := ctx.Eval("({id: 1, name: 'John', email: 'john@example.com'})")
+	email := ctx.Atom("email")
+
+	for b.Loop() {
+		val, err := obj.GetAtom(email)
+		if err != nil {
+			b.Fatal(err)
+		}
+		val.free()
+	}
+}
@@ -0,0 +1,187 @@
+package quickjs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Gaurav-Gosain/quickjs/internal/bridge"
+)
+
+// EvalBytecodeFile runs a script or module compiled to bytecode, as written
+// by the engine's JS_WriteObject, which is what qjsc embeds in the programs
+// it builds. Evaluating a module returns the promise of its evaluation, as
+// EvalModule does.
+//
+// Bytecode is tied to the engine version that wrote it, so the file's
+// bytecode version is checked against the embedded engine's first, and a
+// mismatch is reported as an error rather than passed to the engine. The
+// engine does not verify bytecode beyond that, so only files from a trusted
+// source should be run.
+func (c *Context) EvalBytecodeFile(path string) (Value, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Value{}, err
+	}
+
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	fn, err := c.readBytecode(data)
+	if err != nil {
+		return Value{}, fmt.Errorf("%s: %w", path, err)
+	}
+	defer fn.freeUnlocked()
+	c.runtime.evalCount++
+	return c.runBytecode(fn)
+}
+
+// Script is code compiled to bytecode by Compile or restored by
+// LoadBytecode, which can be run any number of times without being parsed
+// again. It belongs to the context that compiled or loaded it, and holds a
+// reference to the compiled code until Free is called.
+type Script struct {
+	fn   Value // the compiled code
+	data []byte
+}
+
+// Compile compiles code as a global script without running it, applying the
+// source transform as Eval does. Syntax errors are returned as a *JSError.
+//
+//	script, _ := ctx.Compile(`greeting + ", " + name`, "template.js")
+//	defer script.Free()
+//	for _, name := range names {
+//		ctx.SetGlobal("name", ctx.String(name))
+//		val, _ := script.Run()
+//		// ...
+//	}
+func (c *Context) Compile(code, filename string) (*Script, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	code, err := c.runtime.applySourceTransform(filename, code)
+	if err != nil {
+		return nil, err
+	}
+	b := c.runtime.bridge
+	valPtr, err := b.Eval(c.runtime.goCtx, c.ctxPtr, code, filename, int32(evalCompileOnly))
+	if err != nil {
+		return nil, err
+	}
+	fn, err := c.checkException(valPtr)
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.WriteObject(c.runtime.goCtx, c.ctxPtr, fn.ptr, bridge.WriteObjBytecode)
+	if err == nil && data == nil {
+		excPtr, _ := b.GetException(c.runtime.goCtx, c.ctxPtr)
+		err = c.newJSError(excPtr)
+		_ = b.FreeValue(c.runtime.goCtx, c.ctxPtr, excPtr)
+	}
+	if err != nil {
+		fn.freeUnlocked()
+		return nil, fmt.Errorf("failed to serialize %s: %w", filename, err)
+	}
+	return &Script{fn: fn, data: data}, nil
+}
+
+// LoadBytecode restores a script from the bytecode returned by Script.Bytes,
+// or from any bytecode EvalBytecodeFile accepts, which is checked the same
+// way. The bytecode can come from another context or runtime, but only from
+// a trusted source: the engine does not verify it beyond its version.
+func (c *Context) LoadBytecode(data []byte) (*Script, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	fn, err := c.readBytecode(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{fn: fn, data: append([]byte(nil), data...)}, nil
+}
+
+// Run runs the script in its context and returns its completion value, as
+// Eval does. A script restored from a module is evaluated only on its first
+// run, and each run returns the promise of that evaluation.
+func (s *Script) Run() (Value, error) {
+	if err := s.fn.usable(); err != nil {
+		return Value{}, err
+	}
+	c := s.fn.ctx
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	c.runtime.evalCount++
+	return c.runBytecode(s.fn)
+}
+
+// Bytes returns the script's bytecode, which LoadBytecode restores. It is
+// tied to the engine version, like the files EvalBytecodeFile runs.
+func (s *Script) Bytes() []byte {
+	return append([]byte(nil), s.data...)
+}
+
+// Free releases the compiled code. Run fails with ErrFreed afterwards, but
+// Bytes can still be used. Calling Free more than once has no effect.
+func (s *Script) Free() {
+	s.fn.Free()
+}
+
+// readBytecode checks bytecode's version and deserializes it, returning the
+// compiled code. Caller must hold the mutex.
+func (c *Context) readBytecode(data []byte) (Value, error) {
+	version, err := c.bytecodeVersion()
+	if err != nil {
+		return Value{}, err
+	}
+	if len(data) == 0 {
+		return Value{}, errors.New("empty bytecode")
+	}
+	if data[0] != version {
+		return Value{}, fmt.Errorf("bytecode version %d does not match the engine's version %d", data[0], version)
+	}
+
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+	funcPtr, err := b.ReadObject(goCtx, c.ctxPtr, data, bridge.ReadObjBytecode)
+	if err != nil {
+		return Value{}, err
+	}
+	fn, err := c.checkException(funcPtr)
+	if err != nil {
+		return Value{}, fmt.Errorf("invalid bytecode: %w", err)
+	}
+	if ok, _ := b.IsCompiledCode(goCtx, fn.ptr); !ok {
+		fn.freeUnlocked()
+		return Value{}, errors.New("bytecode does not contain compiled code")
+	}
+	return fn, nil
+}
+
+// runBytecode runs compiled code. Caller must hold the mutex.
+func (c *Context) runBytecode(fn Value) (Value, error) {
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+	return c.runtime.withDeadline(func() (Value, error) {
+		valPtr, err := b.EvalFunction(goCtx, c.ctxPtr, fn.ptr)
+		if err != nil {
+			return Value{}, err
+		}
+		return c.checkException(valPtr)
+	})
+}
+
+// bytecodeVersion returns the bytecode version of the embedded engine, the
+// first byte of anything it serializes. Caller must hold the mutex.
+func (c *Context) bytecodeVersion() (byte, error) {
+	undefined := c.undefinedUnlocked()
+	defer undefined.freeUnlocked()
+	data, err := c.runtime.bridge.WriteObject(c.runtime.goCtx, c.ctxPtr, undefined.ptr, 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, errors.New("failed to determine the engine's bytecode version")
+	}
+	return data[0], nil
+}
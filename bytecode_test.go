@@ -0,0 +1,152 @@
+package quickjs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata/hello.bin holds this script, compiled by the embedded engine:
+//
+//	globalThis.loaded = 'yes';
+//	6 * 7
+
+func TestEvalBytecodeFile(t *testing.T) {
+	ctx := newTestContext(t)
+
+	val, err := ctx.EvalBytecodeFile("testdata/hello.bin")
+	if err != nil {
+		t.Fatalf("EvalBytecodeFile error = %v", err)
+	}
+	if got, _ := val.Int32(); got != 42 {
+		t.Errorf("EvalBytecodeFile = %d, want 42", got)
+	}
+	loaded, _ := ctx.Eval("globalThis.loaded")
+	if got := loaded.String(); got != "yes" {
+		t.Errorf("loaded = %q, want %q", got, "yes")
+	}
+
+	data, err := os.ReadFile("testdata/hello.bin")
+	if err != nil {
+		t.Fatalf("ReadFile error = %v", err)
+	}
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("WriteFile error = %v", err)
+		}
+		return path
+	}
+
+	// A different version byte is reported before the engine reads the file
+	version := append([]byte(nil), data...)
+	version[0]++
+	_, err = ctx.EvalBytecodeFile(write("version.bin", version))
+	if err == nil || !strings.Contains(err.Error(), "does not match the engine's version") {
+		t.Errorf("EvalBytecodeFile(version.bin) error = %v, want a version mismatch", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty.bin":     {},
+		"truncated.bin": data[:len(data)/2],
+		"corrupted.bin": append(data[:1:1], 0xff, 0xff, 0xff, 0xff),
+	} {
+		if _, err := ctx.EvalBytecodeFile(write(name, data)); err == nil {
+			t.Errorf("EvalBytecodeFile(%s) should fail", name)
+		}
+	}
+
+	if _, err := ctx.EvalBytecodeFile(filepath.Join(dir, "missing.bin")); err == nil {
+		t.Error("EvalBytecodeFile(missing.bin) should fail")
+	}
+
+	// The context is still usable
+	val, err = ctx.Eval("1 + 1")
+	if err != nil {
+		t.Fatalf("Eval after failures error = %v", err)
+	}
+	if got, _ := val.Int32(); got != 2 {
+		t.Errorf("Eval after failures = %d, want 2", got)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	ctx := newTestContext(t)
+
+	script, err := ctx.Compile(`globalThis.runs = (globalThis.runs || 0) + 1; greeting + ", " + name`, "template.js")
+	if err != nil {
+		t.Fatalf("Compile error = %v", err)
+	}
+	defer script.Free()
+	if runs, _ := ctx.Eval("typeof runs"); runs.String() != "undefined" {
+		t.Error("Compile ran the script")
+	}
+	if _, err := ctx.Eval(`var greeting = "hello"`); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	for _, name := range []string{"ada", "grace"} {
+		if err := ctx.SetGlobal("name", ctx.String(name)); err != nil {
+			t.Fatalf("SetGlobal error = %v", err)
+		}
+		val, err := script.Run()
+		if err != nil {
+			t.Fatalf("Run error = %v", err)
+		}
+		if want := "hello, " + name; val.String() != want {
+			t.Errorf("Run = %q, want %q", val.String(), want)
+		}
+	}
+
+	// The bytecode runs in another runtime
+	other := newTestContext(t)
+	if _, err := other.Eval(`var greeting = "hi", name = "linus"`); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	loaded, err := other.LoadBytecode(script.Bytes())
+	if err != nil {
+		t.Fatalf("LoadBytecode error = %v", err)
+	}
+	val, err := loaded.Run()
+	if err != nil {
+		t.Fatalf("Run error = %v", err)
+	}
+	if val.String() != "hi, linus" {
+		t.Errorf("Run = %q, want %q", val.String(), "hi, linus")
+	}
+	if runs, _ := ctx.Eval("runs"); runs.String() != "2" {
+		t.Errorf("runs = %s, want 2", runs.String())
+	}
+
+	// Errors are reported when running, not when compiling
+	thrower, err := ctx.Compile("missing()", "thrower.js")
+	if err != nil {
+		t.Fatalf("Compile error = %v", err)
+	}
+	var jsErr *JSError
+	if _, err := thrower.Run(); !errors.As(err, &jsErr) {
+		t.Errorf("Run error = %v, want a *JSError", err)
+	}
+	thrower.Free()
+	thrower.Free()
+	if _, err := thrower.Run(); !errors.Is(err, ErrFreed) {
+		t.Errorf("Run after Free error = %v, want ErrFreed", err)
+	}
+	if len(thrower.Bytes()) == 0 {
+		t.Error("Bytes after Free is empty")
+	}
+
+	if _, err := ctx.Compile("1 +", "broken.js"); !errors.As(err, &jsErr) || jsErr.Name != "SyntaxError" {
+		t.Errorf("Compile error = %v, want a SyntaxError", err)
+	}
+	bad := script.Bytes()
+	bad[0]++
+	if _, err := ctx.LoadBytecode(bad); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("LoadBytecode error = %v, want a version mismatch", err)
+	}
+	if _, err := ctx.LoadBytecode(nil); err == nil {
+		t.Error("LoadBytecode(nil) should fail")
+	}
+}
@@ -0,0 +1,81 @@
+package quickjs
+
+import (
+	"errors"
+	"reflect"
+)
+
+// WrapChannel returns an async iterable that yields the values received
+// from ch, converted as Marshal does, and completes when ch is closed, so
+// JavaScript can consume a Go stream with for await...of.
+//
+// Each call to the iterator's next method waits for the next value on ch,
+// holding the runtime locked, so the values must be sent from another
+// goroutine, and that goroutine must not use the runtime while JavaScript is
+// waiting. If the waiting call was made with a context that is done first,
+// as with EvalWithContext, next rejects with the context's error. A value
+// Marshal cannot convert rejects with a TypeError. The iterable is its own
+// iterator, so it can be consumed only once.
+func (c *Context) WrapChannel(ch <-chan any) (Value, error) {
+	if ch == nil {
+		return Value{}, errors.New("nil channel")
+	}
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	receive, funcID := c.newFunction("receive", func(ctx *Context, this Value, args []Value) Value {
+		x, ok, err := ctx.runtime.receive(ch)
+		if err != nil {
+			return ctx.ThrowError(err.Error())
+		}
+		if !ok {
+			return ctx.Null()
+		}
+		val, err := ctx.Marshal(x)
+		if err != nil {
+			return ctx.ThrowTypeError(err.Error())
+		}
+		defer val.free()
+		return ctx.ArrayFrom([]Value{val})
+	})
+	if funcID == 0 {
+		return Value{}, errNewFunction
+	}
+	defer receive.free()
+
+	channelIterator, err := c.helper("channelIterator", channelIteratorJS)
+	if err == nil {
+		var it Value
+		this := c.undefinedUnlocked()
+		defer this.freeUnlocked()
+		it, err = channelIterator.Call(this, receive)
+		if err == nil {
+			return it, nil
+		}
+	}
+	c.runtime.bridge.UnregisterGoFunc(funcID)
+	return Value{}, err
+}
+
+// receive waits for a value on ch, giving up with the context's error if a
+// context watched by an in-progress call is done first. ok is false once ch
+// is closed. Caller must hold the mutex.
+func (r *Runtime) receive(ch <-chan any) (x any, ok bool, err error) {
+	if len(r.watched) == 0 {
+		x, ok = <-ch
+		return x, ok, nil
+	}
+	cases := make([]reflect.SelectCase, 0, 1+len(r.watched))
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)})
+	for _, ctx := range r.watched {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	}
+	chosen, recv, ok := reflect.Select(cases)
+	if chosen > 0 {
+		return nil, false, r.watched[chosen-1].Err()
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return recv.Interface(), true, nil
+}
@@ -0,0 +1,87 @@
+package quickjs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrapChannel(t *testing.T) {
+	ctx := newTestContext(t)
+
+	ch := make(chan any)
+	iterable, err := ctx.WrapChannel(ch)
+	if err != nil {
+		t.Fatalf("WrapChannel error = %v", err)
+	}
+	ctx.SetGlobal("chan", iterable)
+
+	go func() {
+		defer close(ch)
+		for _, x := range []any{1, "two", map[string]any{"three": 3}, nil} {
+			time.Sleep(time.Millisecond)
+			ch <- x
+		}
+	}()
+
+	result, _, err := ctx.EvalAsyncStats(`(async () => {
+		const out = [];
+		for await (const x of chan) out.push(x);
+		return JSON.stringify(out);
+	})()`)
+	if err != nil {
+		t.Fatalf("EvalAsyncStats error = %v", err)
+	}
+	if want := `[1,"two",{"three":3},null]`; result.String() != want {
+		t.Errorf("received %s, want %s", result.String(), want)
+	}
+
+	// Once the channel is closed the iterator stays done
+	again, _, err := ctx.EvalAsyncStats("chan.next().then((r) => r.done)")
+	if err != nil || !again.Bool() {
+		t.Errorf("next after close = %v, %v, want done", again.String(), err)
+	}
+}
+
+func TestWrapChannelErrors(t *testing.T) {
+	ctx := newTestContext(t)
+
+	// A value Marshal cannot convert rejects with a TypeError
+	ch := make(chan any, 1)
+	ch <- make(chan int)
+	iterable, err := ctx.WrapChannel(ch)
+	if err != nil {
+		t.Fatalf("WrapChannel error = %v", err)
+	}
+	ctx.SetGlobal("bad", iterable)
+	result, _, err := ctx.EvalAsyncStats("bad.next().then(() => 'resolved', (e) => e.name)")
+	if err != nil {
+		t.Fatalf("EvalAsyncStats error = %v", err)
+	}
+	if result.String() != "TypeError" {
+		t.Errorf("next on an unconvertible value = %s, want TypeError", result.String())
+	}
+
+	// A wait that outlasts the call's context is abandoned
+	idle, err := ctx.WrapChannel(make(chan any))
+	if err != nil {
+		t.Fatalf("WrapChannel error = %v", err)
+	}
+	ctx.SetGlobal("idle", idle)
+	timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	promise, err := ctx.EvalWithContext(timeout, "idle.next().catch((e) => globalThis.reason = e.message)")
+	if err != nil {
+		t.Fatalf("EvalWithContext error = %v", err)
+	}
+	promise.free()
+	ctx.runtime.ExecutePendingJobs()
+	if reason, _ := ctx.Eval("reason"); !strings.Contains(reason.String(), "deadline exceeded") {
+		t.Errorf("rejection reason = %q, want the context error", reason.String())
+	}
+
+	if _, err := ctx.WrapChannel(nil); err == nil {
+		t.Error("WrapChannel(nil) should fail")
+	}
+}
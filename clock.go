@@ -0,0 +1,77 @@
+package quickjs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// dateOverrideJS replaces the global Date with a proxy that reads the
+// current time from goNow, falling back to the real clock when goNow returns
+// undefined. Dates created with explicit arguments are unaffected.
+const dateOverrideJS = `(function (goNow) {
+	const RealDate = Date;
+	const now = () => {
+		const t = goNow();
+		return t === undefined ? RealDate.now() : t;
+	};
+	globalThis.Date = new Proxy(RealDate, {
+		construct(target, args, newTarget) {
+			if (args.length === 0) args = [now()];
+			return Reflect.construct(target, args, newTarget);
+		},
+		apply() {
+			return new RealDate(now()).toString();
+		},
+		get(target, prop, receiver) {
+			if (prop === "now") return now;
+			return Reflect.get(target, prop, receiver);
+		},
+	});
+})`
+
+// SetNow overrides the clock seen by Date.now(), new Date() and Date() in
+// this context. fn returns the current time in milliseconds since the Unix
+// epoch. Pass nil to go back to the real clock. If the override cannot be
+// installed, Date is left untouched and the error is returned.
+//
+// This makes time-dependent scripts reproducible in tests:
+//
+//	ctx.SetNow(func() float64 { return 1700000000000 })
+func (c *Context) SetNow(fn func() float64) error {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	if c.nowInstalled || fn == nil {
+		c.now = fn
+		return nil
+	}
+
+	goNow, funcID := c.newFunction("now", func(ctx *Context, this Value, args []Value) Value {
+		if ctx.now == nil {
+			return ctx.undefinedUnlocked()
+		}
+		return ctx.Float64(ctx.now())
+	})
+	if funcID == 0 {
+		return errors.New("failed to create clock function")
+	}
+	defer goNow.free()
+
+	install, err := c.evalFile(dateOverrideJS, "<eval>")
+	if err == nil {
+		defer install.free()
+		this := c.undefinedUnlocked()
+		defer this.freeUnlocked()
+		var result Value
+		if result, err = install.Call(this, goNow); err == nil {
+			result.freeUnlocked()
+		}
+	}
+	if err != nil {
+		c.runtime.bridge.UnregisterGoFunc(funcID)
+		return fmt.Errorf("failed to override Date: %w", err)
+	}
+	c.now = fn
+	c.nowInstalled = true
+	return nil
+}
@@ -0,0 +1,103 @@
+package quickjs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetNow(t *testing.T) {
+	ctx := newTestContext(t)
+
+	before, err := ctx.Eval("Date.now()")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	realNow, _ := before.Float64()
+
+	const frozen = 1700000000000
+	if err := ctx.SetNow(func() float64 { return frozen }); err != nil {
+		t.Fatalf("SetNow error = %v", err)
+	}
+
+	result, err := ctx.Eval("const a = Date.now(); const b = Date.now(); [a, b, new Date().getTime()]")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if s, _ := result.JSONStringify(); s != "[1700000000000,1700000000000,1700000000000]" {
+		t.Errorf("frozen times = %s, want all 1700000000000", s)
+	}
+
+	// Dates with explicit arguments and instanceof keep working
+	result, err = ctx.Eval("const d = new Date(0); [d.getTime(), d instanceof Date, typeof Date()]")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if s, _ := result.JSONStringify(); s != `[0,true,"string"]` {
+		t.Errorf("explicit date = %s, want [0,true,\"string\"]", s)
+	}
+
+	// The clock can be advanced and reset
+	current := 1000.0
+	if err := ctx.SetNow(func() float64 { return current }); err != nil {
+		t.Fatalf("SetNow error = %v", err)
+	}
+	current += 500
+	result, _ = ctx.Eval("Date.now()")
+	if result.String() != "1500" {
+		t.Errorf("Date.now() = %s, want 1500", result.String())
+	}
+
+	if err := ctx.SetNow(nil); err != nil {
+		t.Fatalf("SetNow(nil) error = %v", err)
+	}
+	result, _ = ctx.Eval("Date.now()")
+	if now, _ := result.Float64(); now < realNow {
+		t.Errorf("Date.now() = %v after SetNow(nil), want the real clock (>= %v)", now, realNow)
+	}
+}
+
+func TestSetNowFailure(t *testing.T) {
+	ctx := newTestContext(t)
+
+	// Without Proxy the override cannot be installed
+	if _, err := ctx.Eval("var RealDate = Date; delete globalThis.Proxy"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if err := ctx.SetNow(func() float64 { return 0 }); err == nil {
+		t.Fatal("SetNow should fail without Proxy")
+	}
+
+	result, err := ctx.Eval("Date === RealDate && Date.now() > 0")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if !result.Bool() {
+		t.Errorf("Date should be left untouched after a failed SetNow")
+	}
+	if ctx.now != nil || ctx.nowInstalled {
+		t.Errorf("failed SetNow left the clock half installed")
+	}
+}
+
+func TestConfigClock(t *testing.T) {
+	fixed := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	rt, err := NewRuntimeWithConfig(Config{Clock: func() time.Time { return fixed }})
+	if err != nil {
+		t.Fatalf("NewRuntimeWithConfig error = %v", err)
+	}
+	defer rt.Close()
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer ctx.Close()
+
+	result, err := ctx.Eval("[Date.now(), Date.now(), new Date().toISOString(), performance.now() - performance.now()].join()")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	want := "1709294400000,1709294400000,2024-03-01T12:00:00.000Z,0"
+	if result.String() != want {
+		t.Errorf("clock readings = %s, want %s", result.String(), want)
+	}
+}
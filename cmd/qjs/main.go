@@ -3,6 +3,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -98,7 +99,6 @@ type replState struct {
 	rt          *quickjs.Runtime
 	rl          *readline.Instance
 	showTiming  bool
-	evalCount   int
 	multiline   strings.Builder
 	inMultiline bool
 	startTime   time.Time
@@ -241,11 +241,25 @@ func (s *replState) runFile(filename string) error {
 
 func (s *replState) eval(code string) (quickjs.Value, time.Duration, error) {
 	start := time.Now()
-	result, err := s.ctx.Eval(code)
+	result, err := s.evalInput(code)
 	duration := time.Since(start)
 	return result, duration, err
 }
 
+// evalInput evaluates a line of input. Input that starts with a brace is
+// tried as an expression first, so that {a: 1} prints an object as it does
+// in other consoles, and run as statements if it does not parse as one.
+func (s *replState) evalInput(code string) (quickjs.Value, error) {
+	if strings.HasPrefix(code, "{") && !strings.HasSuffix(code, ";") {
+		result, err := s.ctx.EvalExpression(code)
+		var jsErr *quickjs.JSError
+		if !errors.As(err, &jsErr) || jsErr.Name != "SyntaxError" {
+			return result, err
+		}
+	}
+	return s.ctx.EvalREPL(code)
+}
+
 func (s *replState) runREPL() {
 	historyFile := ""
 	if home, err := os.UserHomeDir(); err == nil {
@@ -751,6 +765,7 @@ func (s *replState) cmdInfo() {
 	runtime.ReadMemStats(&memStats)
 
 	uptime := time.Since(s.startTime)
+	stats := s.rt.Stats()
 
 	fmt.Println()
 	fmt.Println(titleStyle.Render("Runtime Information"))
@@ -763,8 +778,12 @@ func (s *replState) cmdInfo() {
 		{"OS/Arch", fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)},
 		{"Go Heap", fmt.Sprintf("%.2f MB", float64(memStats.HeapAlloc)/1024/1024)},
 		{"Go Sys", fmt.Sprintf("%.2f MB", float64(memStats.Sys)/1024/1024)},
-		{"GC Runs", fmt.Sprintf("%d", memStats.NumGC)},
-		{"Evaluations", fmt.Sprintf("%d", s.evalCount)},
+		{"Go GC Runs", fmt.Sprintf("%d", memStats.NumGC)},
+		{"JS Heap", fmt.Sprintf("%.2f MB", float64(stats.Memory.MallocSize)/1024/1024)},
+		{"JS Objects", fmt.Sprintf("%d", stats.Memory.ObjectCount)},
+		{"Contexts", fmt.Sprintf("%d", stats.Contexts)},
+		{"Evaluations", fmt.Sprintf("%d", stats.Evals)},
+		{"JS GC Runs", fmt.Sprintf("%d", stats.GCs)},
 		{"Uptime", uptime.Round(time.Second).String()},
 	}
 
@@ -808,7 +827,6 @@ func (s *replState) cmdReset() {
 	}
 
 	s.ctx = ctx
-	s.evalCount = 0
 
 	runtime.GC()
 
@@ -821,8 +839,6 @@ func (s *replState) evalAndPrint(code string) {
 		return
 	}
 
-	s.evalCount++
-
 	result, duration, err := s.eval(code)
 	if err != nil {
 		printError(err)
@@ -850,7 +866,10 @@ func formatResult(v quickjs.Value) string {
 	case v.IsString():
 		return stringStyle.Render("\"" + str + "\"")
 	case v.IsFunction():
-		return dimStyle.Render("[Function]")
+		if name, _ := v.Name(); name != "" {
+			return dimStyle.Render("[Function: " + name + "]")
+		}
+		return dimStyle.Render("[Function (anonymous)]")
 	case v.IsError():
 		return errorStyle.Render(str)
 	case v.IsBigInt():
@@ -0,0 +1,220 @@
+package quickjs
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// consoleLevels are the console methods installed by NewContext.
+var consoleLevels = []string{"log", "info", "warn", "error", "debug"}
+
+// ConsoleEntry is a single console call captured by EvalFull.
+type ConsoleEntry struct {
+	Level   string // Console method, e.g. "log" or "warn"
+	Message string // Arguments converted to strings and joined by spaces
+}
+
+// EvalFull evaluates code like Eval, additionally capturing everything it
+// logs through console.log, info, warn, error and debug. While the code
+// runs, console output is recorded instead of being passed to the log
+// function; the previous console methods are restored afterwards. The logs
+// are returned even if evaluation fails.
+//
+// Every console call adds an entry, even console.log("") or a call with no
+// arguments, whose Message is empty, so len(logs) > 0 reports whether the
+// code produced any console output. If it produced none, logs is nil.
+func (c *Context) EvalFull(code string) (result Value, logs []ConsoleEntry, err error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	restore, err := c.captureConsole(func(level, msg string) {
+		if n := c.runtime.maxLogLength; n > 0 && len(msg) > n {
+			msg = truncateUTF8(msg, n) + logEllipsis
+		}
+		logs = append(logs, ConsoleEntry{Level: level, Message: msg})
+	})
+	if err != nil {
+		return Value{}, nil, err
+	}
+	defer restore()
+
+	result, err = c.EvalFile(code, "<eval>")
+	return result, logs, err
+}
+
+// captureConsole replaces the console methods with ones that call fn, and
+// returns a function that puts the previous methods back. Caller must hold
+// the mutex.
+func (c *Context) captureConsole(fn func(level, msg string)) (restore func(), err error) {
+	console, err := c.GetGlobal("console")
+	if err != nil {
+		return nil, err
+	}
+	created := !console.IsObject()
+	if created {
+		console.free()
+		console = c.Object()
+		if err := c.SetGlobal("console", console); err != nil {
+			console.free()
+			return nil, err
+		}
+	}
+
+	prev := make(map[string]Value, len(consoleLevels))
+	var funcIDs []uint32
+	restore = func() {
+		for level, method := range prev {
+			_ = console.Set(level, method)
+			method.free()
+		}
+		for _, id := range funcIDs {
+			c.runtime.bridge.UnregisterGoFunc(id)
+		}
+		if created {
+			if global, err := c.Global(); err == nil {
+				_ = global.Delete("console")
+				global.free()
+			}
+		}
+		console.free()
+	}
+
+	for _, level := range consoleLevels {
+		method, err := console.Get(level)
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		prev[level] = method
+
+		capture, funcID := c.newFunction(level, func(ctx *Context, this Value, args []Value) Value {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				parts[i] = arg.String()
+			}
+			fn(level, strings.Join(parts, " "))
+			return ctx.undefinedUnlocked()
+		})
+		if funcID == 0 {
+			restore()
+			return nil, errNewFunction
+		}
+		funcIDs = append(funcIDs, funcID)
+		err = console.Set(level, capture)
+		capture.free()
+		if err != nil {
+			restore()
+			return nil, err
+		}
+	}
+	return restore, nil
+}
+
+// SetPrintName moves the global print function, which writes its arguments
+// to the log function like console.log, to the global called name, so that
+// an embedder can give its output primitive another name, such as echo. An
+// empty name removes it, leaving console as the only way to log. It fails
+// if the context has no print function, because the runtime was created
+// with NoConsole or the function was already removed.
+func (c *Context) SetPrintName(name string) error {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	if c.printName == "" {
+		return errors.New("context has no print function")
+	}
+	global, err := c.Global()
+	if err != nil {
+		return err
+	}
+	defer global.free()
+	fn, err := global.Get(c.printName)
+	if err != nil {
+		return err
+	}
+	defer fn.free()
+	if err := global.Delete(c.printName); err != nil {
+		return err
+	}
+	if name != "" {
+		if err := global.Set(name, fn); err != nil {
+			return err
+		}
+	}
+	c.printName = name
+	return nil
+}
+
+// logEllipsis marks a console message cut short by SetMaxLogLength.
+const logEllipsis = "…"
+
+// SetMaxLogLength limits each console message, such as a console.log or
+// print line, to n bytes, so a script cannot flood the log function. Longer
+// messages are cut at a character boundary and end in "…" before the line
+// break. The limit also applies to the messages EvalFull captures. n <= 0
+// removes the limit, which is the default.
+func (r *Runtime) SetMaxLogLength(n int) {
+	r.lock()
+	defer r.unlock()
+	r.maxLogLength = max(n, 0)
+	r.logLineLen = 0
+	r.logLineCut = false
+	r.installLogFunc()
+}
+
+// installLogFunc passes the log function to the bridge, wrapped to apply
+// the SetMaxLogLength limit if there is one. Caller must hold the mutex.
+func (r *Runtime) installLogFunc() {
+	if r.logFunc == nil || r.maxLogLength == 0 {
+		r.bridge.SetLogFunc(r.logFunc)
+		return
+	}
+	fn := r.logFunc
+	r.bridge.SetLogFunc(func(msg string) {
+		if msg = r.limitLog(msg); msg != "" {
+			fn(msg)
+		}
+	})
+}
+
+// limitLog applies the SetMaxLogLength limit to a piece of console output.
+// The engine writes a console line in several pieces, the arguments, the
+// spaces between them and the line break, so limitLog keeps track of how
+// much of the current line has been written. It is called while JavaScript
+// is running, with the mutex held.
+func (r *Runtime) limitLog(msg string) string {
+	var out strings.Builder
+	for msg != "" {
+		line, rest, newline := strings.Cut(msg, "\n")
+		if !r.logLineCut {
+			if room := r.maxLogLength - r.logLineLen; len(line) <= room {
+				out.WriteString(line)
+				r.logLineLen += len(line)
+			} else {
+				out.WriteString(truncateUTF8(line, room))
+				out.WriteString(logEllipsis)
+				r.logLineCut = true
+			}
+		}
+		if newline {
+			out.WriteByte('\n')
+			r.logLineLen = 0
+			r.logLineCut = false
+		}
+		msg = rest
+	}
+	return out.String()
+}
+
+// truncateUTF8 returns the longest prefix of s of at most n bytes that does
+// not end inside a UTF-8 sequence.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,1121 @@
+package quickjs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Inspect
+// ============================================================================
+
+// Inspect returns a human-readable representation of the value, similar to
+// Node's util.inspect. Strings are quoted, objects and arrays are expanded,
+// and an object that refers back to one of its ancestors is shown as
+// [Circular]. Objects and arrays nested more deeply than Config.MaxDepth
+// allows are shown as [Object] and [Array].
+func (v Value) Inspect() string {
+	if v.ctx == nil || v.freed() {
+		return "undefined"
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	in := inspector{maxDepth: v.ctx.runtime.maxDepth}
+	in.inspect(v)
+	return in.buf.String()
+}
+
+// inspector builds the output of Inspect. It tracks the objects on the path
+// from the root to detect cycles and limit the depth.
+type inspector struct {
+	buf       strings.Builder
+	ancestors []Value
+	maxDepth  int
+}
+
+func (in *inspector) inspect(v Value) {
+	switch {
+	case v.IsUndefined():
+		in.buf.WriteString("undefined")
+	case v.IsNull():
+		in.buf.WriteString("null")
+	case v.IsString():
+		in.buf.WriteString(quoteJS(v.String()))
+	case v.IsBigInt():
+		in.buf.WriteString(v.String() + "n")
+	case v.IsSymbol():
+		s, err := v.CallMethod("toString")
+		if err != nil {
+			in.buf.WriteString("Symbol()")
+			return
+		}
+		in.buf.WriteString(s.String())
+		s.free()
+	case !v.IsObject():
+		in.buf.WriteString(v.String())
+	case isAncestor(in.ancestors, v):
+		in.buf.WriteString("[Circular]")
+	case v.IsFunction():
+		in.buf.WriteString(functionLabel(v))
+	case v.IsError():
+		in.buf.WriteString("[" + v.String() + "]")
+	case v.IsDate():
+		s, err := v.CallMethod("toISOString")
+		if err != nil {
+			in.buf.WriteString("Invalid Date")
+			return
+		}
+		in.buf.WriteString(s.String())
+		s.free()
+	case len(in.ancestors) >= in.maxDepth:
+		if v.IsArray() {
+			in.buf.WriteString("[Array]")
+		} else {
+			in.buf.WriteString("[Object]")
+		}
+	case v.IsArray():
+		in.ancestors = append(in.ancestors, v)
+		defer func() { in.ancestors = in.ancestors[:len(in.ancestors)-1] }()
+
+		n := v.Len()
+		if n == 0 {
+			in.buf.WriteString("[]")
+			return
+		}
+		in.buf.WriteString("[ ")
+		for i := range n {
+			if i > 0 {
+				in.buf.WriteString(", ")
+			}
+			elem, err := v.GetIdx(i)
+			if err != nil {
+				in.buf.WriteString("undefined")
+				continue
+			}
+			in.inspect(elem)
+			elem.free()
+		}
+		in.buf.WriteString(" ]")
+	default:
+		in.ancestors = append(in.ancestors, v)
+		defer func() { in.ancestors = in.ancestors[:len(in.ancestors)-1] }()
+
+		kind, entries := in.entries(v)
+		switch kind {
+		case "Map", "Set":
+			in.collection(kind, entries)
+		default:
+			in.object(v, entries)
+		}
+		for _, e := range entries {
+			e.free()
+		}
+	}
+}
+
+// entries returns the kind of v ("Map", "Set" or "Object") and, respectively,
+// its [key, value] pairs, its values, or its enumerable [symbol, value]
+// properties.
+func (in *inspector) entries(v Value) (string, []Value) {
+	inspectEntries, err := v.ctx.helper("inspectEntries", inspectEntriesJS)
+	if err != nil {
+		return "Object", nil
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := inspectEntries.Call(this, v)
+	if err != nil {
+		return "Object", nil
+	}
+	defer result.free()
+
+	kindVal, _ := result.GetIdx(0)
+	kind := kindVal.String()
+	kindVal.free()
+	list, _ := result.GetIdx(1)
+	defer list.free()
+	entries := make([]Value, 0, list.Len())
+	for i := range list.Len() {
+		e, err := list.GetIdx(i)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return kind, entries
+}
+
+// collection writes a Map or Set in Node's format, e.g. Map(1) { 'a' => 1 }.
+func (in *inspector) collection(kind string, entries []Value) {
+	fmt.Fprintf(&in.buf, "%s(%d) ", kind, len(entries))
+	if len(entries) == 0 {
+		in.buf.WriteString("{}")
+		return
+	}
+	in.buf.WriteString("{ ")
+	for i, e := range entries {
+		if i > 0 {
+			in.buf.WriteString(", ")
+		}
+		if kind == "Set" {
+			in.inspect(e)
+			continue
+		}
+		key, _ := e.GetIdx(0)
+		val, _ := e.GetIdx(1)
+		in.inspect(key)
+		in.buf.WriteString(" => ")
+		in.inspect(val)
+		key.free()
+		val.free()
+	}
+	in.buf.WriteString(" }")
+}
+
+// object writes the string-keyed properties of v followed by the given
+// [symbol, value] pairs.
+func (in *inspector) object(v Value, symbols []Value) {
+	keys, _ := v.ownKeys()
+	if len(keys) == 0 && len(symbols) == 0 {
+		in.buf.WriteString("{}")
+		return
+	}
+	in.buf.WriteString("{ ")
+	for i, key := range keys {
+		if i > 0 {
+			in.buf.WriteString(", ")
+		}
+		if isIdentifier(key) {
+			in.buf.WriteString(key)
+		} else {
+			in.buf.WriteString(quoteJS(key))
+		}
+		in.buf.WriteString(": ")
+		prop, err := v.Get(key)
+		if err != nil {
+			in.buf.WriteString("undefined")
+			continue
+		}
+		in.inspect(prop)
+		prop.free()
+	}
+	for i, e := range symbols {
+		if i > 0 || len(keys) > 0 {
+			in.buf.WriteString(", ")
+		}
+		sym, _ := e.GetIdx(0)
+		val, _ := e.GetIdx(1)
+		in.buf.WriteString("[")
+		in.inspect(sym)
+		in.buf.WriteString("]: ")
+		in.inspect(val)
+		sym.free()
+		val.free()
+	}
+	in.buf.WriteString(" }")
+}
+
+// functionLabel returns the Inspect form of a function value.
+func functionLabel(v Value) string {
+	if name, _ := v.Name(); name != "" {
+		return "[Function: " + name + "]"
+	}
+	return "[Function (anonymous)]"
+}
+
+// quoteJS quotes s as a single-quoted JavaScript string literal.
+func quoteJS(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\x%02X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// isAncestor reports whether v is one of the given objects, which belong to
+// the same runtime. It compares them as sameObject does, but under a single
+// lock, since the paths it checks can be as long as Config.MaxDepth.
+func isAncestor(ancestors []Value, v Value) bool {
+	if v.ctx == nil || len(ancestors) == 0 {
+		return false
+	}
+	r := v.ctx.runtime
+	r.lock()
+	defer r.unlock()
+	for _, a := range ancestors {
+		if same, _ := r.bridge.StrictEq(r.goCtx, a.ptr, v.ptr); same {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================
+// Marshal
+// ============================================================================
+
+// valueType is the reflect.Type of Value, which Marshal passes through.
+var valueType = reflect.TypeFor[Value]()
+
+// timeType is the reflect.Type of time.Time, which Marshal converts to a
+// Date.
+var timeType = reflect.TypeFor[time.Time]()
+
+// Marshal converts a Go value into a JavaScript value, following the same
+// rules as encoding/json: structs become objects (honoring json tags,
+// including omitempty), maps with string or integer keys become objects,
+// slices and arrays become arrays, and nil pointers, maps and slices become
+// null. A []byte becomes an ArrayBuffer, a time.Time a Date with its
+// instant to the millisecond, and a Value is passed through.
+//
+// Marshal returns an error wrapping ErrMaxDepth if arrays, maps and structs
+// are nested more deeply than Config.MaxDepth allows, as they are without
+// end in a value that contains itself through a pointer.
+func (c *Context) Marshal(v any) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	return c.marshal(reflect.ValueOf(v), 0)
+}
+
+// ObjectFromMap builds a JavaScript object from m, converting each value as
+// Marshal does. A nil map gives an empty object.
+func (c *Context) ObjectFromMap(m map[string]any) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	if m == nil {
+		return c.newValue(c.runtime.bridge.NewObject(c.runtime.goCtx, c.ctxPtr))
+	}
+	return c.marshalMap(reflect.ValueOf(m), 0)
+}
+
+// marshal converts rv into a newly allocated JavaScript value that the caller
+// owns. depth is the number of arrays, maps and structs rv is nested in.
+// Caller must hold the mutex.
+func (c *Context) marshal(rv reflect.Value, depth int) (Value, error) {
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+
+	if !rv.IsValid() {
+		return c.newValue(b.NewNull(goCtx))
+	}
+	if rv.Type() == valueType {
+		v := rv.Interface().(Value)
+		if v.ctx == nil || v.freed() {
+			return c.newValue(b.NewUndefined(goCtx))
+		}
+		return c.newValue(b.DupValue(goCtx, c.ctxPtr, v.ptr))
+	}
+	if rv.Type() == timeType {
+		t := rv.Interface().(time.Time)
+		return c.newValue(b.NewDate(goCtx, c.ctxPtr, float64(t.UnixMilli())))
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		if depth >= c.runtime.maxDepth {
+			return Value{}, errMaxDepth(c.runtime.maxDepth)
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return c.newValue(b.NewNull(goCtx))
+		}
+		return c.marshal(rv.Elem(), depth)
+
+	case reflect.Bool:
+		return c.newValue(b.NewBool(goCtx, rv.Bool()))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return c.newValue(b.NewInt64(goCtx, c.ctxPtr, rv.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return c.newValue(b.NewInt64(goCtx, c.ctxPtr, int64(u)))
+		}
+		return c.newValue(b.NewFloat64(goCtx, float64(rv.Uint())))
+
+	case reflect.Float32, reflect.Float64:
+		return c.newValue(b.NewFloat64(goCtx, rv.Float()))
+
+	case reflect.String:
+		return c.newValue(b.NewString(goCtx, c.ctxPtr, rv.String()))
+
+	case reflect.Slice:
+		if rv.IsNil() {
+			return c.newValue(b.NewNull(goCtx))
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return c.newValue(b.NewArrayBuffer(goCtx, c.ctxPtr, rv.Bytes()))
+		}
+		return c.marshalArray(rv, depth)
+
+	case reflect.Array:
+		return c.marshalArray(rv, depth)
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return c.newValue(b.NewNull(goCtx))
+		}
+		return c.marshalMap(rv, depth)
+
+	case reflect.Struct:
+		return c.marshalStruct(rv, depth)
+	}
+
+	return Value{}, fmt.Errorf("cannot marshal Go value of type %s", rv.Type())
+}
+
+// newValue wraps the result of a bridge call that allocates a value.
+func (c *Context) newValue(ptr uint32, err error) (Value, error) {
+	if err != nil {
+		return Value{}, err
+	}
+	return c.value(ptr), nil
+}
+
+func (c *Context) marshalArray(rv reflect.Value, depth int) (Value, error) {
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+
+	arr, err := c.newValue(b.NewArray(goCtx, c.ctxPtr))
+	if err != nil {
+		return Value{}, err
+	}
+	for i := range rv.Len() {
+		elem, err := c.marshal(rv.Index(i), depth+1)
+		if err == nil {
+			err = b.SetPropertyUint32(goCtx, c.ctxPtr, arr.ptr, uint32(i), elem.ptr)
+			elem.freeUnlocked()
+		}
+		if err != nil {
+			arr.freeUnlocked()
+			return Value{}, err
+		}
+	}
+	return arr, nil
+}
+
+func (c *Context) marshalMap(rv reflect.Value, depth int) (Value, error) {
+	keys := make([]string, 0, rv.Len())
+	values := make(map[string]reflect.Value, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		var key string
+		switch k := iter.Key(); k.Kind() {
+		case reflect.String:
+			key = k.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key = strconv.FormatInt(k.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			key = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return Value{}, fmt.Errorf("cannot marshal map with key type %s", k.Type())
+		}
+		keys = append(keys, key)
+		values[key] = iter.Value()
+	}
+	sort.Strings(keys)
+
+	obj, err := c.newValue(c.runtime.bridge.NewObject(c.runtime.goCtx, c.ctxPtr))
+	if err != nil {
+		return Value{}, err
+	}
+	for _, key := range keys {
+		if err := c.marshalProperty(obj, key, values[key], depth+1); err != nil {
+			obj.freeUnlocked()
+			return Value{}, err
+		}
+	}
+	return obj, nil
+}
+
+func (c *Context) marshalStruct(rv reflect.Value, depth int) (Value, error) {
+	obj, err := c.newValue(c.runtime.bridge.NewObject(c.runtime.goCtx, c.ctxPtr))
+	if err != nil {
+		return Value{}, err
+	}
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := fieldForRead(rv, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		if err := c.marshalProperty(obj, f.name, fv, depth+1); err != nil {
+			obj.freeUnlocked()
+			return Value{}, err
+		}
+	}
+	return obj, nil
+}
+
+// marshalProperty converts rv and stores it as obj[key].
+func (c *Context) marshalProperty(obj Value, key string, rv reflect.Value, depth int) error {
+	val, err := c.marshal(rv, depth)
+	if err != nil {
+		return err
+	}
+	defer val.freeUnlocked()
+	return c.runtime.bridge.SetProperty(c.runtime.goCtx, c.ctxPtr, obj.ptr, key, val.ptr)
+}
+
+// fieldForRead returns the nested field of rv. It reports false if the field
+// is reached through a nil embedded pointer.
+func fieldForRead(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+// isEmptyValue reports whether rv is empty for the purposes of omitempty.
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return rv.IsZero()
+	}
+	return false
+}
+
+// ============================================================================
+// Unmarshal
+// ============================================================================
+
+// ErrMaxDepth is returned, wrapped, by Marshal and Unmarshal when a value is
+// nested more deeply than Config.MaxDepth allows.
+var ErrMaxDepth = errors.New("value is nested too deeply")
+
+// errMaxDepth returns the error for a value nested beyond limit levels.
+func errMaxDepth(limit int) error {
+	return fmt.Errorf("%w: limit is %d", ErrMaxDepth, limit)
+}
+
+// errCyclic is returned when unmarshaling an object graph that contains a
+// cycle.
+var errCyclic = errors.New("cannot unmarshal cyclic object")
+
+// Unmarshal stores the value in the Go value pointed to by out, following the
+// same rules as encoding/json: objects decode into structs (honoring json
+// tags) and string-keyed maps, arrays into slices and arrays, and any value
+// into an empty interface as nil, bool, float64, string, []any or
+// map[string]any. ArrayBuffers decode into []byte.
+//
+// Unmarshal returns an error if an object contains itself, directly or
+// through one of its properties, and an error wrapping ErrMaxDepth if
+// objects and arrays are nested more deeply than Config.MaxDepth allows.
+func (v Value) Unmarshal(out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("Unmarshal requires a non-nil pointer, got %T", out)
+	}
+	if err := v.usable(); err != nil {
+		return err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	d := decoder{maxDepth: v.ctx.runtime.maxDepth}
+	return d.decode(v, rv.Elem())
+}
+
+// decoder implements Unmarshal. It tracks the objects on the path from the
+// root to detect cycles and limit the depth.
+type decoder struct {
+	ancestors []Value
+	maxDepth  int
+	bigInts   bool // generic returns BigInts as *big.Int rather than int64
+}
+
+// enter records v as the current object and fails if it is already being
+// decoded further up the tree, or if the tree is already too deep.
+func (d *decoder) enter(v Value) error {
+	if isAncestor(d.ancestors, v) {
+		return errCyclic
+	}
+	if len(d.ancestors) >= d.maxDepth {
+		return errMaxDepth(d.maxDepth)
+	}
+	d.ancestors = append(d.ancestors, v)
+	return nil
+}
+
+func (d *decoder) leave() {
+	d.ancestors = d.ancestors[:len(d.ancestors)-1]
+}
+
+func (d *decoder) decode(v Value, rv reflect.Value) error {
+	nullish := v.IsUndefined() || v.IsNull()
+
+	if rv.Kind() == reflect.Pointer {
+		if nullish {
+			rv.SetZero()
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decode(v, rv.Elem())
+	}
+
+	if nullish {
+		switch rv.Kind() {
+		case reflect.Interface, reflect.Map, reflect.Slice:
+			rv.SetZero()
+		}
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return d.typeError(v, rv)
+		}
+		g, err := d.generic(v)
+		if err != nil {
+			return err
+		}
+		if g == nil {
+			rv.SetZero()
+		} else {
+			rv.Set(reflect.ValueOf(g))
+		}
+		return nil
+
+	case reflect.Bool:
+		if !v.IsBool() {
+			return d.typeError(v, rv)
+		}
+		rv.SetBool(v.Bool())
+		return nil
+
+	case reflect.String:
+		if !v.IsString() {
+			return d.typeError(v, rv)
+		}
+		rv.SetString(v.String())
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, err := d.number(v, rv)
+		if err != nil {
+			return err
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || rv.OverflowInt(int64(f)) {
+			return fmt.Errorf("cannot unmarshal number %s into Go value of type %s", v.String(), rv.Type())
+		}
+		rv.SetInt(int64(f))
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f, err := d.number(v, rv)
+		if err != nil {
+			return err
+		}
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || rv.OverflowUint(uint64(f)) {
+			return fmt.Errorf("cannot unmarshal number %s into Go value of type %s", v.String(), rv.Type())
+		}
+		rv.SetUint(uint64(f))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := d.number(v, rv)
+		if err != nil {
+			return err
+		}
+		if rv.OverflowFloat(f) {
+			return fmt.Errorf("cannot unmarshal number %s into Go value of type %s", v.String(), rv.Type())
+		}
+		rv.SetFloat(f)
+		return nil
+
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && !v.IsArray() {
+			data, err := v.Bytes()
+			if err != nil {
+				return d.typeError(v, rv)
+			}
+			rv.SetBytes(data)
+			return nil
+		}
+		if !v.IsArray() {
+			return d.typeError(v, rv)
+		}
+		if err := d.enter(v); err != nil {
+			return err
+		}
+		defer d.leave()
+
+		n := v.Len()
+		slice := reflect.MakeSlice(rv.Type(), n, n)
+		for i := range n {
+			if err := d.decodeIndex(v, i, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+
+	case reflect.Array:
+		if !v.IsArray() {
+			return d.typeError(v, rv)
+		}
+		if err := d.enter(v); err != nil {
+			return err
+		}
+		defer d.leave()
+
+		n := v.Len()
+		for i := range rv.Len() {
+			if i >= n {
+				rv.Index(i).SetZero()
+				continue
+			}
+			if err := d.decodeIndex(v, i, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || !v.IsObject() || v.IsArray() {
+			return d.typeError(v, rv)
+		}
+		if err := d.enter(v); err != nil {
+			return err
+		}
+		defer d.leave()
+
+		keys, err := v.ownKeys()
+		if err != nil {
+			return err
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(rv.Type(), len(keys)))
+		}
+		for _, key := range keys {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := d.decodeProperty(v, key, elem); err != nil {
+				return err
+			}
+			rv.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+		}
+		return nil
+
+	case reflect.Struct:
+		if !v.IsObject() || v.IsArray() {
+			return d.typeError(v, rv)
+		}
+		if err := d.enter(v); err != nil {
+			return err
+		}
+		defer d.leave()
+
+		keys, err := v.ownKeys()
+		if err != nil {
+			return err
+		}
+		fields := structFields(rv.Type())
+		for _, key := range keys {
+			f := fields.lookup(key)
+			if f == nil {
+				continue
+			}
+			fv, ok := fieldByIndex(rv, f.index)
+			if !ok {
+				continue
+			}
+			if err := d.decodeProperty(v, key, fv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return d.typeError(v, rv)
+}
+
+// decodeIndex decodes element i of the array v into rv.
+func (d *decoder) decodeIndex(v Value, i int, rv reflect.Value) error {
+	elem, err := v.GetIdx(i)
+	if err != nil {
+		return err
+	}
+	defer elem.free()
+	return d.decode(elem, rv)
+}
+
+// decodeProperty decodes property key of the object v into rv.
+func (d *decoder) decodeProperty(v Value, key string, rv reflect.Value) error {
+	prop, err := v.Get(key)
+	if err != nil {
+		return err
+	}
+	defer prop.free()
+	return d.decode(prop, rv)
+}
+
+// number returns v as a float64, failing if it is not a number.
+func (d *decoder) number(v Value, rv reflect.Value) (float64, error) {
+	if !v.IsNumber() {
+		return 0, d.typeError(v, rv)
+	}
+	return v.Float64()
+}
+
+func (d *decoder) typeError(v Value, rv reflect.Value) error {
+	return fmt.Errorf("cannot unmarshal JavaScript %s into Go value of type %s", v.Typeof(), rv.Type())
+}
+
+// generic converts v into the Go value used for empty interface targets.
+func (d *decoder) generic(v Value) (any, error) {
+	switch {
+	case v.IsUndefined(), v.IsNull():
+		return nil, nil
+	case v.IsBool():
+		return v.Bool(), nil
+	case v.IsNumber():
+		return v.Float64()
+	case v.IsString():
+		return v.String(), nil
+	case v.IsBigInt():
+		if d.bigInts {
+			return v.BigIntBig()
+		}
+		return v.BigInt()
+	case v.IsArray():
+		var out []any
+		err := d.decode(v, reflect.ValueOf(&out).Elem())
+		return out, err
+	case v.IsFunction(), v.IsSymbol():
+		return nil, fmt.Errorf("cannot unmarshal JavaScript %s", v.Typeof())
+	case v.IsObject():
+		out := map[string]any{}
+		err := d.decode(v, reflect.ValueOf(&out).Elem())
+		return out, err
+	}
+	return nil, fmt.Errorf("cannot unmarshal JavaScript %s", v.Typeof())
+}
+
+// ToGo converts the value into a Go value: undefined and null become nil,
+// booleans bool, numbers float64, strings string, BigInts *big.Int, arrays
+// []any and other objects map[string]any of their own enumerable
+// properties, converted in turn. Functions and symbols cannot be converted.
+// Like Unmarshal, it returns an error if an object contains itself, or if
+// objects are nested more deeply than Config.MaxDepth allows.
+func (v Value) ToGo() (any, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	d := decoder{maxDepth: v.ctx.runtime.maxDepth, bigInts: true}
+	return d.generic(v)
+}
+
+// MarshalJSON implements json.Marshaler, encoding the value as ToGo
+// converts it, so a Value can be passed to json.Marshal or embedded in a
+// struct that is. Object keys are sorted, as for any Go map, and BigInts
+// are encoded as integers. The zero Value is encoded as null.
+func (v Value) MarshalJSON() ([]byte, error) {
+	if v.ctx == nil {
+		return []byte("null"), nil
+	}
+	g, err := v.ToGo()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(g)
+}
+
+// MapToGo returns the entries of a JavaScript Map as a Go map from key to
+// value. Every key must be a string; a Map with any other key returns an
+// error instead of converting it, since distinct keys such as 1 and "1"
+// would collide. The values are new references owned by the caller.
+func (v Value) MapToGo() (map[string]Value, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	mapEntries, err := v.ctx.helper("mapEntries", mapEntriesJS)
+	if err != nil {
+		return nil, err
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	flat, err := mapEntries.Call(this, v)
+	if err != nil {
+		return nil, err
+	}
+	defer flat.free()
+
+	n := flat.Len()
+	m := make(map[string]Value, n/2)
+	for i := 0; i+1 < n; i += 2 {
+		key, err := flat.GetIdx(i)
+		if err != nil {
+			return nil, err
+		}
+		name := key.String()
+		key.free()
+		val, err := flat.GetIdx(i + 1)
+		if err != nil {
+			for _, val := range m {
+				val.free()
+			}
+			return nil, err
+		}
+		m[name] = val
+	}
+	return m, nil
+}
+
+// AsGoFunc returns a Go function that calls v, which must be a JavaScript
+// function. Arguments are converted with Marshal and the result is converted
+// with Unmarshal into an any, so objects come back as map[string]any and
+// arrays as []any. The returned function holds its own reference to v and
+// can be stored and called any number of times while the context is open.
+func (v Value) AsGoFunc() func(args ...any) (any, error) {
+	if err := v.usable(); err != nil {
+		return func(...any) (any, error) { return nil, err }
+	}
+	c := v.ctx
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	if !v.IsFunction() {
+		return func(...any) (any, error) { return nil, errors.New("value is not a function") }
+	}
+	fn, err := c.newValue(c.runtime.bridge.DupValue(c.runtime.goCtx, c.ctxPtr, v.ptr))
+	if err != nil {
+		return func(...any) (any, error) { return nil, err }
+	}
+
+	return func(args ...any) (any, error) {
+		c.runtime.lock()
+		defer c.runtime.unlock()
+		if c.closed {
+			return nil, errors.New("context is closed")
+		}
+
+		vals := make([]Value, 0, len(args))
+		defer func() {
+			for _, val := range vals {
+				val.free()
+			}
+		}()
+		for i, arg := range args {
+			val, err := c.marshal(reflect.ValueOf(arg), 0)
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %w", i, err)
+			}
+			vals = append(vals, val)
+		}
+
+		this := c.undefinedUnlocked()
+		defer this.freeUnlocked()
+		result, err := fn.Call(this, vals...)
+		if err != nil {
+			return nil, err
+		}
+		defer result.free()
+
+		var out any
+		d := decoder{maxDepth: c.runtime.maxDepth}
+		if err := d.decode(result, reflect.ValueOf(&out).Elem()); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+}
+
+// ============================================================================
+// Number Parsing
+// ============================================================================
+
+// ParseInt parses s as JavaScript's parseInt does, which is more lenient
+// than strconv: leading whitespace is skipped, parsing stops at the first
+// character that is not a digit, and with radix 0 or 16 a 0x prefix selects
+// hexadecimal. A radix of 0 picks the base automatically; otherwise it must be
+// between 2 and 36. It returns an error if s does not start with a number or
+// the result does not fit in an int64.
+func (c *Context) ParseInt(s string, radix int) (int64, error) {
+	f, err := c.parseNumber("parseInteger", parseIntegerJS, s, radix)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) {
+		return 0, fmt.Errorf("parseInt %q: not a number", s)
+	}
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("parseInt %q: value out of range", s)
+	}
+	return int64(f), nil
+}
+
+// ParseFloat parses s as JavaScript's parseFloat does: leading whitespace is
+// skipped and parsing stops at the first character that cannot continue a
+// decimal number, so "1e3px" gives 1000. "Infinity" is accepted. It returns an
+// error if s does not start with a number.
+func (c *Context) ParseFloat(s string) (float64, error) {
+	f, err := c.parseNumber("parseFloat", parseFloatJS, s, 0)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) {
+		return 0, fmt.Errorf("parseFloat %q: not a number", s)
+	}
+	return f, nil
+}
+
+// parseNumber calls the named parsing helper with s and radix.
+func (c *Context) parseNumber(name, src, s string, radix int) (float64, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	parse, err := c.helper(name, src)
+	if err != nil {
+		return 0, err
+	}
+	str := c.String(s)
+	defer str.free()
+	r := c.Int32(int32(radix))
+	defer r.free()
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := parse.Call(this, str, r)
+	if err != nil {
+		return 0, err
+	}
+	defer result.free()
+	return result.Float64()
+}
+
+// ============================================================================
+// Struct Fields
+// ============================================================================
+
+// field describes a struct field as seen by Marshal and Unmarshal.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+type fieldList []field
+
+// lookup returns the field with the given name, falling back to a
+// case-insensitive match like encoding/json.
+func (fl fieldList) lookup(name string) *field {
+	for i := range fl {
+		if fl[i].name == name {
+			return &fl[i]
+		}
+	}
+	for i := range fl {
+		if strings.EqualFold(fl[i].name, name) {
+			return &fl[i]
+		}
+	}
+	return nil
+}
+
+// structFields returns the exported fields of t, honoring json tags and
+// promoting the fields of embedded structs.
+func structFields(t reflect.Type) fieldList {
+	var fields fieldList
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, f := range structFields(ft) {
+					f.index = append([]int{i}, f.index...)
+					fields = append(fields, f)
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+	return fields
+}
+
+// fieldByIndex returns the nested field of rv, allocating nil embedded
+// pointers along the way. It reports false if an embedded pointer is to an
+// unexported type and cannot be allocated.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
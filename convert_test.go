@@ -0,0 +1,618 @@
+package quickjs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStrictEquals(t *testing.T) {
+	ctx := newTestContext(t)
+
+	_, err := ctx.Eval("var a = {}; var b = {};")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	a1, _ := ctx.GetGlobal("a")
+	a2, _ := ctx.GetGlobal("a")
+	b, _ := ctx.GetGlobal("b")
+
+	if !a1.StrictEquals(a2) {
+		t.Errorf("a should strictly equal itself")
+	}
+	if a1.StrictEquals(b) {
+		t.Errorf("a should not strictly equal b")
+	}
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"'ab'", "'a' + 'b'", true},
+		{"'ab'", "'ba'", false},
+		{"NaN", "NaN", false},
+		{"0", "-0", true},
+		{"1", "1.0", true},
+		{"1", "'1'", false},
+		{"null", "undefined", false},
+		{"10n", "5n * 2n", true},
+	}
+	for _, tt := range tests {
+		x, err := ctx.Eval(tt.a)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.a, err)
+		}
+		y, err := ctx.Eval(tt.b)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.b, err)
+		}
+		if got := x.StrictEquals(y); got != tt.want {
+			t.Errorf("%s === %s = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"undefined", "undefined"},
+		{"null", "null"},
+		{"42", "42"},
+		{"'it\\'s'", `'it\'s'`},
+		{"123n", "123n"},
+		{"Symbol('s')", "Symbol(s)"},
+		{"[]", "[]"},
+		{"({})", "{}"},
+		{"[1, 'two', [3]]", "[ 1, 'two', [ 3 ] ]"},
+		{"({a: 1, 'b-c': true, d: null})", "{ a: 1, 'b-c': true, d: null }"},
+		{"(function add() {})", "[Function: add]"},
+		{"new Error('boom')", "[Error: boom]"},
+		{"new Date(0)", "1970-01-01T00:00:00.000Z"},
+		{"new Map()", "Map(0) {}"},
+		{"new Map([['a', 1], [2, {b: 3}]])", "Map(2) { 'a' => 1, 2 => { b: 3 } }"},
+		{"new Set([1, 'x'])", "Set(2) { 1, 'x' }"},
+		{"({[Symbol('s')]: 1})", "{ [Symbol(s)]: 1 }"},
+		{"({a: 1, [Symbol.iterator]: 2})", "{ a: 1, [Symbol(Symbol.iterator)]: 2 }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			val, err := ctx.Eval(tt.code)
+			if err != nil {
+				t.Fatalf("Eval error = %v", err)
+			}
+			if got := val.Inspect(); got != tt.want {
+				t.Errorf("Inspect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectCircular(t *testing.T) {
+	ctx := newTestContext(t)
+
+	val, err := ctx.Eval("const a = {}; a.self = a; a")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, want := val.Inspect(), "{ self: [Circular] }"; got != want {
+		t.Errorf("Inspect() = %q, want %q", got, want)
+	}
+
+	// Shared references that are not cycles are expanded each time
+	val, err = ctx.Eval("const shared = {x: 1}; [shared, shared]")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, want := val.Inspect(), "[ { x: 1 }, { x: 1 } ]"; got != want {
+		t.Errorf("Inspect() = %q, want %q", got, want)
+	}
+
+	val, err = ctx.Eval("const m = new Map(); m.set('m', m); m")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, want := val.Inspect(), "Map(1) { 'm' => [Circular] }"; got != want {
+		t.Errorf("Inspect() = %q, want %q", got, want)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	ctx := newTestContext(t)
+
+	type Address struct {
+		City string `json:"city"`
+	}
+	type Person struct {
+		Name    string   `json:"name"`
+		Age     int      `json:"age"`
+		Tags    []string `json:"tags"`
+		Address *Address `json:"address"`
+		Ignored string   `json:"-"`
+	}
+
+	val, err := ctx.Eval(`({name: "Alice", age: 30, tags: ["a", "b"], address: {city: "Paris"}, Ignored: "x"})`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	var p Person
+	if err := val.Unmarshal(&p); err != nil {
+		t.Fatalf("Unmarshal error = %v", err)
+	}
+	want := Person{Name: "Alice", Age: 30, Tags: []string{"a", "b"}, Address: &Address{City: "Paris"}}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Unmarshal = %+v, want %+v", p, want)
+	}
+
+	var generic any
+	if err := val.Unmarshal(&generic); err != nil {
+		t.Fatalf("Unmarshal error = %v", err)
+	}
+	m, ok := generic.(map[string]any)
+	if !ok {
+		t.Fatalf("Unmarshal into any = %T, want map[string]any", generic)
+	}
+	if m["age"] != float64(30) {
+		t.Errorf("age = %v, want 30", m["age"])
+	}
+
+	var n int
+	frac, _ := ctx.Eval("1.5")
+	if err := frac.Unmarshal(&n); err == nil {
+		t.Errorf("Unmarshal of 1.5 into int should fail")
+	}
+	if err := val.Unmarshal(p); err == nil {
+		t.Errorf("Unmarshal into non-pointer should fail")
+	}
+}
+
+func TestUnmarshalCircular(t *testing.T) {
+	ctx := newTestContext(t)
+
+	val, err := ctx.Eval("const a = {}; a.self = a; a")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	var m map[string]any
+	err = val.Unmarshal(&m)
+	if err == nil {
+		t.Fatalf("Unmarshal of a cyclic object should fail")
+	}
+	if !strings.Contains(err.Error(), "cyclic") {
+		t.Errorf("Unmarshal error = %v, want a cyclic object error", err)
+	}
+
+	// The context is still usable afterwards
+	result, err := ctx.Eval("a.self === a")
+	if err != nil || !result.Bool() {
+		t.Errorf("a.self === a = %v, %v; want true", result, err)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	ctx := newTestContext(t)
+
+	val, err := ctx.Eval(`({
+		nil: null, undef: undefined, ok: true, n: 1.5, s: "str",
+		big: 12345678901234567890n, list: [1, "two", [3]], nested: {a: {b: 2}},
+	})`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	got, err := val.ToGo()
+	if err != nil {
+		t.Fatalf("ToGo error = %v", err)
+	}
+	bigInt, _ := new(big.Int).SetString("12345678901234567890", 10)
+	want := map[string]any{
+		"nil": nil, "undef": nil, "ok": true, "n": 1.5, "s": "str",
+		"big": bigInt, "list": []any{float64(1), "two", []any{float64(3)}},
+		"nested": map[string]any{"a": map[string]any{"b": float64(2)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToGo() = %#v, want %#v", got, want)
+	}
+
+	data, err := json.Marshal(map[string]any{"result": val})
+	if err != nil {
+		t.Fatalf("json.Marshal error = %v", err)
+	}
+	wantJSON := `{"result":{"big":12345678901234567890,"list":[1,"two",[3]],"n":1.5,"nested":{"a":{"b":2}},"nil":null,"ok":true,"s":"str","undef":null}}`
+	if string(data) != wantJSON {
+		t.Errorf("json.Marshal = %s, want %s", data, wantJSON)
+	}
+	if data, err := json.Marshal(Value{}); err != nil || string(data) != "null" {
+		t.Errorf("json.Marshal of the zero Value = %s, %v; want null", data, err)
+	}
+
+	cyclic, _ := ctx.Eval("var c = {list: []}; c.list.push(c); c")
+	if _, err := cyclic.ToGo(); err == nil || !strings.Contains(err.Error(), "cyclic") {
+		t.Errorf("ToGo of a cyclic object error = %v, want a cyclic object error", err)
+	}
+	if _, err := json.Marshal(cyclic); err == nil {
+		t.Error("json.Marshal of a cyclic object should fail")
+	}
+	fn, _ := ctx.Eval("() => 1")
+	if _, err := fn.ToGo(); err == nil {
+		t.Error("ToGo of a function should fail")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	ctx := newTestContext(t)
+
+	type Inner struct {
+		Score float64 `json:"score"`
+	}
+	type Record struct {
+		Name    string         `json:"name"`
+		Count   int            `json:"count"`
+		Tags    []string       `json:"tags"`
+		Meta    map[string]any `json:"meta"`
+		Inner   *Inner         `json:"inner"`
+		Empty   string         `json:"empty,omitempty"`
+		Skipped string         `json:"-"`
+		Raw     []byte         `json:"raw"`
+	}
+
+	val, err := ctx.Marshal(Record{
+		Name:    "test",
+		Count:   3,
+		Tags:    []string{"a", "b"},
+		Meta:    map[string]any{"z": true, "a": nil},
+		Inner:   &Inner{Score: 1.5},
+		Skipped: "x",
+		Raw:     []byte{1, 2, 3},
+	})
+	if err != nil {
+		t.Fatalf("Marshal error = %v", err)
+	}
+	ctx.SetGlobal("record", val)
+
+	result, err := ctx.Eval(`JSON.stringify({...record, raw: new Uint8Array(record.raw).join(",")})`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	want := `{"name":"test","count":3,"tags":["a","b"],"meta":{"a":null,"z":true},"inner":{"score":1.5},"raw":"1,2,3"}`
+	if result.String() != want {
+		t.Errorf("record = %s, want %s", result.String(), want)
+	}
+
+	// Times become Dates, through pointers too, and nil pointers null
+	when := time.Date(2024, 3, 1, 12, 30, 0, 5e8, time.FixedZone("X", 3600))
+	val, err = ctx.Marshal(map[string]any{"at": when, "ptr": &when, "none": (*time.Time)(nil)})
+	if err != nil {
+		t.Fatalf("Marshal error = %v", err)
+	}
+	ctx.SetGlobal("times", val)
+	result, err = ctx.Eval(`[times.at instanceof Date, times.at.toISOString(), times.ptr.getTime() === times.at.getTime(), times.none].join(" ")`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, want := result.String(), "true 2024-03-01T11:30:00.500Z true "; got != want {
+		t.Errorf("times = %q, want %q", got, want)
+	}
+
+	// A Value is passed through unchanged
+	obj, _ := ctx.Eval("({x: 1})")
+	wrapped, err := ctx.Marshal(map[string]Value{"obj": obj})
+	if err != nil {
+		t.Fatalf("Marshal error = %v", err)
+	}
+	inner, _ := wrapped.Get("obj")
+	if !inner.StrictEquals(obj) {
+		t.Errorf("Marshal should pass Values through")
+	}
+
+	if _, err := ctx.Marshal(func() {}); err == nil {
+		t.Errorf("Marshal of a func should fail")
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	ctx := newTestContext(t)
+
+	// Go values are checked against the default limit. One that contains
+	// itself would otherwise recurse until the stack is exhausted.
+	type loop struct {
+		Next *loop `json:"next"`
+	}
+	l := &loop{}
+	l.Next = l
+	if _, err := ctx.Marshal(l); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Marshal of a cyclic value error = %v, want ErrMaxDepth", err)
+	}
+	var nested any = "leaf"
+	for range 2000 {
+		nested = []any{nested}
+	}
+	if _, err := ctx.Marshal(nested); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Marshal of a deep slice error = %v, want ErrMaxDepth", err)
+	}
+	if _, err := ctx.Marshal(map[string]any{"a": []any{map[string]any{"b": 1}}}); err != nil {
+		t.Errorf("Marshal of a shallow value error = %v", err)
+	}
+
+	// Converting JavaScript values deeply is slow, so those are checked
+	// against a lower limit
+	rt, err := NewRuntimeWithConfig(Config{MaxDepth: 50})
+	if err != nil {
+		t.Fatalf("NewRuntimeWithConfig error = %v", err)
+	}
+	defer rt.Close()
+	small, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer small.Close()
+
+	deep, err := small.Eval(`
+		let o = { leaf: true };
+		for (let i = 0; i < 2000; i++) o = { child: o };
+		o
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	var out any
+	if err := deep.Unmarshal(&out); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Unmarshal error = %v, want ErrMaxDepth", err)
+	}
+	type node struct {
+		Child *node `json:"child"`
+	}
+	var n node
+	if err := deep.Unmarshal(&n); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Unmarshal into struct error = %v, want ErrMaxDepth", err)
+	}
+	want := strings.Repeat("{ child: ", 50) + "[Object]" + strings.Repeat(" }", 50)
+	if got := deep.Inspect(); got != want {
+		t.Errorf("Inspect = %.80s..., want it to stop at the limit", got)
+	}
+
+	if _, err := small.Marshal(nested); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Marshal error = %v, want ErrMaxDepth", err)
+	}
+	shallow, _ := small.Eval("[[[1]]]")
+	if err := shallow.Unmarshal(&out); err != nil {
+		t.Errorf("Unmarshal of a shallow array error = %v", err)
+	}
+	if got := shallow.Inspect(); got != "[ [ [ 1 ] ] ]" {
+		t.Errorf("Inspect = %s, want [ [ [ 1 ] ] ]", got)
+	}
+}
+
+func TestObjectFromMap(t *testing.T) {
+	ctx := newTestContext(t)
+
+	obj, err := ctx.ObjectFromMap(map[string]any{
+		"name":    "app",
+		"port":    8080,
+		"ratio":   0.5,
+		"debug":   true,
+		"tags":    []string{"a", "b"},
+		"limits":  map[string]any{"cpu": 2, "nested": map[string]any{"ok": false}},
+		"missing": nil,
+	})
+	if err != nil {
+		t.Fatalf("ObjectFromMap error = %v", err)
+	}
+	want := `{"debug":true,"limits":{"cpu":2,"nested":{"ok":false}},"missing":null,"name":"app","port":8080,"ratio":0.5,"tags":["a","b"]}`
+	if got, _ := obj.JSONStringify(); got != want {
+		t.Errorf("JSONStringify() = %s, want %s", got, want)
+	}
+
+	empty, err := ctx.ObjectFromMap(nil)
+	if err != nil {
+		t.Fatalf("ObjectFromMap(nil) error = %v", err)
+	}
+	if got, _ := empty.JSONStringify(); got != "{}" {
+		t.Errorf("ObjectFromMap(nil) = %s, want {}", got)
+	}
+
+	if _, err := ctx.ObjectFromMap(map[string]any{"ch": make(chan int)}); err == nil {
+		t.Errorf("ObjectFromMap should fail on a channel value")
+	}
+}
+
+func TestMapToGo(t *testing.T) {
+	ctx := newTestContext(t)
+
+	m, err := ctx.Eval("new Map([['a', 1], ['b', 2], ['c', {n: 3}]])")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	got, err := m.MapToGo()
+	if err != nil {
+		t.Fatalf("MapToGo error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("MapToGo returned %d entries, want 3", len(got))
+	}
+	for key, want := range map[string]string{"a": "1", "b": "2", "c": `{"n":3}`} {
+		s, _ := got[key].JSONStringify()
+		if s != want {
+			t.Errorf("MapToGo()[%q] = %s, want %s", key, s, want)
+		}
+	}
+
+	empty, _ := ctx.Eval("new Map()")
+	if got, err := empty.MapToGo(); err != nil || len(got) != 0 {
+		t.Errorf("MapToGo(empty) = %v, %v; want no entries", got, err)
+	}
+
+	for _, code := range []string{"new Map([['a', 1], [2, 'two']])", "new Map([[{}, 1]])", "({a: 1})", "[['a', 1]]"} {
+		v, _ := ctx.Eval("(" + code + ")")
+		if _, err := v.MapToGo(); err == nil {
+			t.Errorf("MapToGo(%s) should fail", code)
+		}
+	}
+}
+
+func TestAsGoFunc(t *testing.T) {
+	ctx := newTestContext(t)
+
+	fn, err := ctx.Eval("(a, b) => ({sum: a + b, parts: [a, b]})")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	add := fn.AsGoFunc()
+
+	for i := range 3 {
+		got, err := add(i, 10)
+		if err != nil {
+			t.Fatalf("add(%d, 10) error = %v", i, err)
+		}
+		want := map[string]any{"sum": float64(i + 10), "parts": []any{float64(i), float64(10)}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("add(%d, 10) = %#v, want %#v", i, got, want)
+		}
+	}
+
+	// The function survives a collection once nothing in JavaScript
+	// refers to it
+	ctx.runtime.RunGC()
+	if got, err := add("a", "b"); err != nil || !reflect.DeepEqual(got.(map[string]any)["sum"], "ab") {
+		t.Errorf("add(\"a\", \"b\") = %v, %v", got, err)
+	}
+
+	thrower, _ := ctx.Eval("() => { throw new RangeError('nope') }")
+	if _, err := thrower.AsGoFunc()(); err == nil || err.Error() != "nope" {
+		t.Errorf("calling a throwing function error = %v, want nope", err)
+	}
+
+	notFunc, _ := ctx.Eval("42")
+	if _, err := notFunc.AsGoFunc()(); err == nil {
+		t.Errorf("AsGoFunc on a number should fail when called")
+	}
+}
+
+func TestClone(t *testing.T) {
+	ctx := newTestContext(t)
+
+	orig, err := ctx.Eval(`({name: "a", list: [1, {x: 2}], when: new Date(0), tags: new Set(["t"])})`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	clone, err := orig.Clone()
+	if err != nil {
+		t.Fatalf("Clone error = %v", err)
+	}
+	ctx.SetGlobal("orig", orig)
+	ctx.SetGlobal("copy", clone)
+
+	result, err := ctx.Eval(`
+		copy.name = "b";
+		copy.list[1].x = 3;
+		copy.tags.add("u");
+		JSON.stringify([orig.name, orig.list[1].x, orig.tags.size, copy.when instanceof Date, copy.when !== orig.when])
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, want := result.String(), `["a",2,1,true,true]`; got != want {
+		t.Errorf("after mutating clone = %s, want %s", got, want)
+	}
+
+	cyclic, _ := ctx.Eval("const c = {}; c.self = c; c")
+	clone, err = cyclic.Clone()
+	if err != nil {
+		t.Fatalf("Clone of cyclic object error = %v", err)
+	}
+	self, _ := clone.Get("self")
+	if !self.StrictEquals(clone) || clone.StrictEquals(cyclic) {
+		t.Errorf("cyclic clone should reference itself, not the original")
+	}
+
+	fn, _ := ctx.Eval("(function() {})")
+	if _, err := fn.Clone(); err == nil {
+		t.Errorf("Clone of a function should fail")
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	ctx := newTestContext(t)
+
+	intTests := []struct {
+		s     string
+		radix int
+	}{
+		{"0x1f", 0},
+		{"0x1f", 16},
+		{"  42abc", 0},
+		{"1e3", 0},
+		{"-17", 10},
+		{"101", 2},
+		{"zz", 36},
+		{"08", 0},
+	}
+	for _, tt := range intTests {
+		want, err := ctx.Eval(fmt.Sprintf("parseInt(%q, %d)", tt.s, tt.radix))
+		if err != nil {
+			t.Fatalf("Eval error = %v", err)
+		}
+		wantInt, _ := want.Int64()
+		got, err := ctx.ParseInt(tt.s, tt.radix)
+		if err != nil {
+			t.Errorf("ParseInt(%q, %d) error = %v", tt.s, tt.radix, err)
+			continue
+		}
+		if got != wantInt {
+			t.Errorf("ParseInt(%q, %d) = %d, want %d", tt.s, tt.radix, got, wantInt)
+		}
+	}
+
+	floatTests := []string{"1e3", "  3.14abc", "-0.5", ".5", "Infinity", "0x10"}
+	for _, s := range floatTests {
+		want, err := ctx.Eval(fmt.Sprintf("parseFloat(%q)", s))
+		if err != nil {
+			t.Fatalf("Eval error = %v", err)
+		}
+		wantFloat, _ := want.Float64()
+		got, err := ctx.ParseFloat(s)
+		if err != nil {
+			t.Errorf("ParseFloat(%q) error = %v", s, err)
+			continue
+		}
+		if got != wantFloat {
+			t.Errorf("ParseFloat(%q) = %v, want %v", s, got, wantFloat)
+		}
+	}
+
+	for _, s := range []string{"abc", "", "0x"} {
+		if _, err := ctx.ParseInt(s, 0); err == nil {
+			t.Errorf("ParseInt(%q) should fail", s)
+		}
+		if _, err := ctx.ParseFloat(s); err == nil && s != "0x" {
+			t.Errorf("ParseFloat(%q) should fail", s)
+		}
+	}
+	if _, err := ctx.ParseInt("1", 1); err == nil {
+		t.Errorf("ParseInt with radix 1 should fail")
+	}
+	if _, err := ctx.ParseInt("1e30", 0); err != nil {
+		t.Errorf("ParseInt(\"1e30\") error = %v, want 1", err)
+	}
+	if _, err := ctx.ParseInt("99999999999999999999", 10); err == nil {
+		t.Errorf("ParseInt of a value beyond int64 should fail")
+	}
+
+	// Replacing the globals does not affect the Go helpers
+	if _, err := ctx.Eval("parseInt = parseFloat = () => 7"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if n, _ := ctx.ParseInt("12", 10); n != 12 {
+		t.Errorf("ParseInt after replacing parseInt = %d, want 12", n)
+	}
+}
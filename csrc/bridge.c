@@ -106,6 +106,10 @@ extern void host_log(uint32_t ptr, uint32_t len);
 __attribute__((import_module("env"), import_name("host_call_go")))
 extern uint32_t host_call_go(uint32_t ctx_ptr, uint32_t func_id, int32_t argc, uint32_t argv_ptr);
 
+// Host function consulted by the interrupt handler; non-zero interrupts
+__attribute__((import_module("env"), import_name("host_interrupt")))
+extern int32_t host_interrupt(uint32_t rt_ptr);
+
 // ============================================================================
 // Runtime and Context Management
 // ============================================================================
@@ -483,6 +487,42 @@ int32_t qjs_set_property_uint32(uint32_t ctx_ptr, uint32_t obj_ptr, uint32_t idx
     return JS_SetPropertyUint32(ctx, obj, idx, JS_DupValue(ctx, val));
 }
 
+// ============================================================================
+// Atoms (interned property names)
+// ============================================================================
+
+__attribute__((export_name("qjs_new_atom")))
+uint32_t qjs_new_atom(uint32_t ctx_ptr, uint32_t name_ptr, uint32_t len) {
+    if (!ctx_ptr || !name_ptr) return JS_ATOM_NULL;
+    JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
+    const char* name = (const char*)(uintptr_t)name_ptr;
+    return JS_NewAtomLen(ctx, name, len);
+}
+
+__attribute__((export_name("qjs_free_atom")))
+void qjs_free_atom(uint32_t ctx_ptr, uint32_t atom) {
+    if (!ctx_ptr) return;
+    JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
+    JS_FreeAtom(ctx, atom);
+}
+
+__attribute__((export_name("qjs_get_property_atom")))
+uint32_t qjs_get_property_atom(uint32_t ctx_ptr, uint32_t obj_ptr, uint32_t atom) {
+    if (!ctx_ptr) return 0;
+    JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
+    JSValue obj = load_jsvalue(obj_ptr);
+    return store_jsvalue(JS_GetProperty(ctx, obj, atom));
+}
+
+__attribute__((export_name("qjs_set_property_atom")))
+int32_t qjs_set_property_atom(uint32_t ctx_ptr, uint32_t obj_ptr, uint32_t atom, uint32_t val_ptr) {
+    if (!ctx_ptr) return -1;
+    JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
+    JSValue obj = load_jsvalue(obj_ptr);
+    JSValue val = load_jsvalue(val_ptr);
+    return JS_SetProperty(ctx, obj, atom, JS_DupValue(ctx, val));
+}
+
 // ============================================================================
 // Global Object
 // ============================================================================
@@ -637,6 +677,19 @@ uint32_t qjs_throw_reference_error(uint32_t ctx_ptr, uint32_t msg_ptr) {
     return store_jsvalue(JS_ThrowReferenceError(ctx, "%s", msg));
 }
 
+// Stack trace of the running code, as the stack property of a new Error
+// reports it: one line per frame, innermost first
+__attribute__((export_name("qjs_get_stack_trace")))
+uint32_t qjs_get_stack_trace(uint32_t ctx_ptr) {
+    if (!ctx_ptr) return 0;
+    JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
+    JSValue err = JS_NewError(ctx);
+    if (JS_IsException(err)) return store_jsvalue(err);
+    JSValue stack = JS_GetPropertyStr(ctx, err, "stack");
+    JS_FreeValue(ctx, err);
+    return store_jsvalue(stack);
+}
+
 // ============================================================================
 // Value Management
 // ============================================================================
@@ -659,6 +712,13 @@ void qjs_free_value(uint32_t ctx_ptr, uint32_t val_ptr) {
     free_jsvalue_slot(val_ptr);
 }
 
+// Return a slot to the freelist without freeing its value, for a slot whose
+// reference the engine has taken over
+__attribute__((export_name("qjs_release_slot")))
+void qjs_release_slot(uint32_t val_ptr) {
+    free_jsvalue_slot(val_ptr);
+}
+
 // ============================================================================
 // JSON
 // ============================================================================
@@ -1006,6 +1066,71 @@ void qjs_set_max_stack_size(uint32_t rt_ptr, uint32_t stack_size) {
     JS_SetMaxStackSize(rt, stack_size);
 }
 
+// ============================================================================
+// Interrupt Handler
+// ============================================================================
+
+// QuickJS calls this every few thousand operations; returning non-zero
+// aborts the running code with an uncatchable "interrupted" error.
+static int interrupt_handler(JSRuntime* rt, void* opaque) {
+    (void)opaque;
+    return host_interrupt((uint32_t)(uintptr_t)rt) != 0;
+}
+
+__attribute__((export_name("qjs_set_interrupt_handler")))
+void qjs_set_interrupt_handler(uint32_t rt_ptr) {
+    if (!rt_ptr) return;
+    JSRuntime* rt = (JSRuntime*)(uintptr_t)rt_ptr;
+    JS_SetInterruptHandler(rt, interrupt_handler, NULL);
+}
+
+// ============================================================================
+// Module Loader
+// ============================================================================
+
+// QuickJS calls this for an imported module it has not loaded yet, with the
+// name already resolved against the importing module. The Go callback whose
+// ID is opaque returns the module's source, or throws.
+static JSModuleDef* go_module_loader(JSContext* ctx, const char* module_name, void* opaque) {
+    uint32_t* arg_ptrs = (uint32_t*)arena_alloc(sizeof(uint32_t));
+    if (!arg_ptrs) return NULL;
+    arg_ptrs[0] = store_jsvalue(JS_NewString(ctx, module_name));
+
+    uint32_t result_ptr = host_call_go(
+        (uint32_t)(uintptr_t)ctx,
+        (uint32_t)(uintptr_t)opaque,
+        1,
+        (uint32_t)(uintptr_t)arg_ptrs
+    );
+    JSValue source = load_jsvalue(result_ptr);
+    if (JS_IsException(source)) return NULL;
+
+    size_t len;
+    const char* code = JS_ToCStringLen(ctx, &len, source);
+    JS_FreeValue(ctx, source);
+    if (!code) return NULL;
+
+    JSValue func_val = JS_Eval(ctx, code, len, module_name,
+                               JS_EVAL_TYPE_MODULE | JS_EVAL_FLAG_COMPILE_ONLY);
+    JS_FreeCString(ctx, code);
+    if (JS_IsException(func_val)) return NULL;
+
+    // The module stays alive in the context's list of loaded modules
+    JSModuleDef* m = JS_VALUE_GET_PTR(func_val);
+    JS_FreeValue(ctx, func_val);
+    return m;
+}
+
+// Sets the Go callback that supplies the source of imported modules. A
+// func_id of 0 removes it, so imports of unknown modules fail.
+__attribute__((export_name("qjs_set_module_loader")))
+void qjs_set_module_loader(uint32_t rt_ptr, uint32_t func_id) {
+    if (!rt_ptr) return;
+    JSRuntime* rt = (JSRuntime*)(uintptr_t)rt_ptr;
+    JS_SetModuleLoaderFunc(rt, NULL, func_id ? go_module_loader : NULL,
+                           (void*)(uintptr_t)func_id);
+}
+
 // ============================================================================
 // Utility: Get Error Message
 // ============================================================================
@@ -1070,3 +1195,47 @@ uint32_t qjs_to_string(uint32_t ctx_ptr, uint32_t val_ptr) {
     JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
     return store_jsvalue(JS_ToString(ctx, load_jsvalue(val_ptr)));
 }
+
+// ============================================================================
+// Bytecode
+// ============================================================================
+
+// Serialize a value with JS_WriteObject. Returns a buffer allocated with
+// js_malloc, whose size is written to size_ptr, or 0 if an exception
+// was thrown.
+__attribute__((export_name("qjs_write_object")))
+uint32_t qjs_write_object(uint32_t ctx_ptr, uint32_t val_ptr, uint32_t size_ptr, int32_t flags) {
+    if (!ctx_ptr || !size_ptr) return 0;
+    JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
+    size_t* size = (size_t*)(uintptr_t)size_ptr;
+    return (uint32_t)(uintptr_t)JS_WriteObject(ctx, size, load_jsvalue(val_ptr), flags);
+}
+
+__attribute__((export_name("qjs_read_object")))
+uint32_t qjs_read_object(uint32_t ctx_ptr, uint32_t buf_ptr, uint32_t len, int32_t flags) {
+    if (!ctx_ptr || !buf_ptr) return 0;
+    JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
+    const uint8_t* buf = (const uint8_t*)(uintptr_t)buf_ptr;
+    return store_jsvalue(JS_ReadObject(ctx, buf, len, flags));
+}
+
+// Run compiled code read by qjs_read_object, resolving a module's imports
+// first. The slot passed in keeps its value.
+__attribute__((export_name("qjs_eval_function")))
+uint32_t qjs_eval_function(uint32_t ctx_ptr, uint32_t func_ptr) {
+    if (!ctx_ptr) return 0;
+    JSContext* ctx = (JSContext*)(uintptr_t)ctx_ptr;
+    JSValue func = load_jsvalue(func_ptr);
+    if (JS_VALUE_GET_TAG(func) == JS_TAG_MODULE && JS_ResolveModule(ctx, func) < 0) {
+        return store_jsvalue(JS_EXCEPTION);
+    }
+    // JS_EvalFunction takes ownership of the function
+    return store_jsvalue(JS_EvalFunction(ctx, JS_DupValue(ctx, func)));
+}
+
+// Check for compiled code that qjs_eval_function can run, a script or a module
+__attribute__((export_name("qjs_is_compiled_code")))
+int32_t qjs_is_compiled_code(uint32_t val_ptr) {
+    int tag = JS_VALUE_GET_TAG(load_jsvalue(val_ptr));
+    return tag == JS_TAG_MODULE || tag == JS_TAG_FUNCTION_BYTECODE;
+}
@@ -1997,6 +1997,11 @@ void JS_SetInterruptHandler(JSRuntime *rt, JSInterruptHandler *cb, void *opaque)
     rt->interrupt_opaque = opaque;
 }
 
+void JS_ResetInterruptCounter(JSContext *ctx)
+{
+    ctx->interrupt_counter = JS_INTERRUPT_COUNTER_INIT;
+}
+
 void JS_SetCanBlock(JSRuntime *rt, bool can_block)
 {
     rt->can_block = can_block;
@@ -2588,15 +2593,13 @@ JSRuntime *JS_GetRuntime(JSContext *ctx)
 
 static void update_stack_limit(JSRuntime *rt)
 {
-#if defined(__wasi__)
-    rt->stack_limit = 0; /* no limit */
-#else
-    if (rt->stack_size == 0) {
+    /* a stack top below the size, as on WASI where the stack sits low in
+       linear memory, leaves no room for a limit */
+    if (rt->stack_size == 0 || rt->stack_size >= rt->stack_top) {
         rt->stack_limit = 0; /* no limit */
     } else {
         rt->stack_limit = rt->stack_top - rt->stack_size;
     }
-#endif
 }
 
 void JS_SetMaxStackSize(JSRuntime *rt, size_t stack_size)
@@ -0,0 +1,81 @@
+package quickjs
+
+// maxCauseDepth bounds how many levels of Error.cause are converted, so a
+// cause chain that loops back on itself cannot recurse forever.
+const maxCauseDepth = 32
+
+// JSError is the error returned when JavaScript code throws.
+//
+// If the thrown error has a cause property, it is converted too and returned
+// by Unwrap, so errors.Is, errors.As and errors.Unwrap follow the cause
+// chain across the Go/JavaScript boundary.
+type JSError struct {
+	Name    string // Error name, e.g. "TypeError"; empty if a non-Error was thrown
+	Message string // Error message
+	Stack   string // Stack trace, if available
+	Cause   error  // Converted cause property, or nil
+}
+
+// Error returns the error message.
+func (e *JSError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error's cause.
+func (e *JSError) Unwrap() error {
+	return e.Cause
+}
+
+// newJSError converts a thrown value into a JSError. Caller must hold the
+// mutex.
+func (c *Context) newJSError(excPtr uint32) *JSError {
+	return c.newJSErrorDepth(Value{ctx: c, ptr: excPtr}, nil)
+}
+
+func (c *Context) newJSErrorDepth(exc Value, seen []Value) *JSError {
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+
+	// Thrown primitives have no message property; use their string form.
+	var msg string
+	if exc.IsObject() {
+		msg, _ = b.GetErrorMessage(goCtx, c.ctxPtr, exc.ptr)
+	} else {
+		msg = exc.String()
+	}
+	if msg == "" {
+		msg = "JavaScript exception"
+	}
+	jsErr := &JSError{Message: msg}
+	if !exc.IsObject() {
+		return jsErr
+	}
+
+	if name, err := exc.Get("name"); err == nil {
+		if name.IsString() {
+			jsErr.Name = name.String()
+		}
+		name.free()
+	}
+	if stack, err := exc.Get("stack"); err == nil {
+		if stack.IsString() {
+			jsErr.Stack = stack.String()
+		}
+		stack.free()
+	}
+
+	seen = append(seen, exc)
+	if len(seen) >= maxCauseDepth || !exc.Has("cause") {
+		return jsErr
+	}
+	cause, err := exc.Get("cause")
+	if err != nil {
+		return jsErr
+	}
+	defer cause.free()
+	if cause.IsObject() && isAncestor(seen, cause) {
+		return jsErr
+	}
+	jsErr.Cause = c.newJSErrorDepth(cause, seen)
+	return jsErr
+}
@@ -0,0 +1,83 @@
+package quickjs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestJSError(t *testing.T) {
+	ctx := newTestContext(t)
+
+	_, err := ctx.Eval("null.foo")
+	var jsErr *JSError
+	if !errors.As(err, &jsErr) {
+		t.Fatalf("Eval error = %T, want *JSError", err)
+	}
+	if jsErr.Name != "TypeError" {
+		t.Errorf("Name = %q, want %q", jsErr.Name, "TypeError")
+	}
+	if jsErr.Message == "" || jsErr.Error() != jsErr.Message {
+		t.Errorf("Error() = %q, Message = %q", jsErr.Error(), jsErr.Message)
+	}
+	if !strings.Contains(jsErr.Stack, "<eval>") {
+		t.Errorf("Stack = %q, want it to mention <eval>", jsErr.Stack)
+	}
+	if jsErr.Cause != nil {
+		t.Errorf("Cause = %v, want nil", jsErr.Cause)
+	}
+
+	_, err = ctx.Eval("throw 'plain string'")
+	if err == nil || err.Error() != "plain string" {
+		t.Errorf("throw 'plain string' error = %v", err)
+	}
+}
+
+func TestJSErrorCause(t *testing.T) {
+	ctx := newTestContext(t)
+
+	_, err := ctx.Eval("throw new Error('outer', { cause: new Error('inner') })")
+	if err == nil {
+		t.Fatalf("Eval should return an error")
+	}
+	if err.Error() != "outer" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "outer")
+	}
+
+	inner := errors.Unwrap(err)
+	if inner == nil {
+		t.Fatalf("errors.Unwrap returned nil")
+	}
+	if inner.Error() != "inner" {
+		t.Errorf("cause = %q, want %q", inner.Error(), "inner")
+	}
+	if errors.Unwrap(inner) != nil {
+		t.Errorf("inner error should have no cause")
+	}
+
+	// A cause chain that loops back on itself terminates
+	_, err = ctx.Eval("const e = new Error('loop'); e.cause = e; throw e")
+	if err == nil {
+		t.Fatalf("Eval should return an error")
+	}
+	if errors.Unwrap(err) != nil {
+		t.Errorf("self-referencing cause should not be followed")
+	}
+}
+
+func TestLongErrorMessage(t *testing.T) {
+	ctx := newTestContext(t)
+
+	for _, n := range []int{1023, 1024, 5000, 100000} {
+		want := strings.Repeat("v", n)
+		_, err := ctx.Eval(fmt.Sprintf("throw new TypeError('v'.repeat(%d))", n))
+		var jsErr *JSError
+		if !errors.As(err, &jsErr) {
+			t.Fatalf("Eval error = %v, want a *JSError", err)
+		}
+		if jsErr.Message != want {
+			t.Errorf("message of %d bytes returned as %d bytes", n, len(jsErr.Message))
+		}
+	}
+}
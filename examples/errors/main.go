@@ -151,21 +151,19 @@ func main() {
 	// === Throwing Errors from Go Functions ===
 	fmt.Println("\n=== Throwing Errors from Go Functions ===")
 
-	// Create a Go function that validates input
-	validateFn := ctx.Function("validateAge", func(ctx *quickjs.Context, this quickjs.Value, args []quickjs.Value) quickjs.Value {
+	// Create a Go function that validates input. Returning an error throws
+	// it as a JavaScript Error, which scripts can catch.
+	validateFn := ctx.FunctionWithError("validateAge", func(ctx *quickjs.Context, this quickjs.Value, args []quickjs.Value) (quickjs.Value, error) {
 		if len(args) < 1 {
-			// Return an error value
-			errVal, _ := ctx.Eval(`new Error("Age is required")`)
-			return errVal
+			return quickjs.Value{}, fmt.Errorf("age is required")
 		}
 
 		age, err := args[0].Int32()
 		if err != nil || age < 0 || age > 150 {
-			errVal, _ := ctx.Eval(`new RangeError("Age must be between 0 and 150")`)
-			return errVal
+			return quickjs.Value{}, fmt.Errorf("age must be between 0 and 150")
 		}
 
-		return ctx.Bool(true)
+		return ctx.Bool(true), nil
 	})
 	ctx.SetGlobal("validateAge", validateFn)
 
@@ -173,13 +171,13 @@ func main() {
 	testCases := []string{
 		`validateAge(25)`,
 		`validateAge(-5)`,
-		`validateAge(200)`,
+		`try { validateAge(200) } catch (e) { "caught: " + e.message }`,
 	}
 
 	for _, tc := range testCases {
-		result, _ := ctx.Eval(tc)
-		if result.IsError() {
-			fmt.Printf("%s -> Error: %s\n", tc, result.String())
+		result, err := ctx.Eval(tc)
+		if err != nil {
+			fmt.Printf("%s -> Error: %v\n", tc, err)
 		} else {
 			fmt.Printf("%s -> %s\n", tc, result.String())
 		}
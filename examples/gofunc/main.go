@@ -128,21 +128,19 @@ func main() {
 	// Create a calculator object with Go-backed methods
 	ctx.Eval(`var calc = {}`)
 
-	calcAdd := ctx.Function("calcAdd", func(ctx *quickjs.Context, this quickjs.Value, args []quickjs.Value) quickjs.Value {
-		a, _ := args[0].Float64()
-		b, _ := args[1].Float64()
-		return ctx.Float64(a + b)
-	})
-
-	calcMul := ctx.Function("calcMul", func(ctx *quickjs.Context, this quickjs.Value, args []quickjs.Value) quickjs.Value {
-		a, _ := args[0].Float64()
-		b, _ := args[1].Float64()
-		return ctx.Float64(a * b)
-	})
-
 	calcObj, _ := ctx.GetGlobal("calc")
-	calcObj.Set("add", calcAdd)
-	calcObj.Set("multiply", calcMul)
+	calcObj.SetFunctions(map[string]quickjs.GoFunc{
+		"add": func(ctx *quickjs.Context, this quickjs.Value, args []quickjs.Value) quickjs.Value {
+			a, _ := args[0].Float64()
+			b, _ := args[1].Float64()
+			return ctx.Float64(a + b)
+		},
+		"multiply": func(ctx *quickjs.Context, this quickjs.Value, args []quickjs.Value) quickjs.Value {
+			a, _ := args[0].Float64()
+			b, _ := args[1].Float64()
+			return ctx.Float64(a * b)
+		},
+	})
 
 	result, _ = ctx.Eval("calc.add(5, 3)")
 	fmt.Printf("calc.add(5, 3) = %s\n", result.String())
@@ -199,8 +199,8 @@ func main() {
 
 	ctx.Eval(`var scores = [85, 92, 78, 96, 88]`)
 
-	// Call push via Eval (simpler)
-	ctx.Eval(`scores.push(91)`)
+	scores, _ := ctx.GetGlobal("scores")
+	scores.Push(ctx.Int32(91))
 
 	result, _ = ctx.Eval(`scores.join(", ")`)
 	fmt.Printf("Scores after push: %s\n", result.String())
@@ -0,0 +1,143 @@
+package quickjs
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+)
+
+// ErrFreed is returned by methods called on a Value that has been freed,
+// or that was returned from a GoFunc and so handed over to JavaScript.
+var ErrFreed = errors.New("value has been freed")
+
+// valueRef records whether the reference a Value holds has been released.
+// It is shared by the copies of the Value, so freeing one frees them all.
+type valueRef struct {
+	freed atomic.Bool
+}
+
+// pendingRelease is a slot to release the next time the mutex is taken.
+// Finalizers run on a goroutine of their own, where the runtime cannot be
+// used, so they queue the slot instead of freeing it.
+type pendingRelease struct {
+	ctx      *Context
+	ptr      uint32
+	consumed bool // the engine owns the reference; only the slot is freed
+}
+
+// value returns a Value holding the reference in slot ptr, which the caller
+// owns.
+func (c *Context) value(ptr uint32) Value {
+	ref := &valueRef{}
+	if c.runtime.finalizers && ptr != 0 {
+		runtime.SetFinalizer(ref, func(ref *valueRef) {
+			if !ref.freed.Load() {
+				c.runtime.queueRelease(pendingRelease{ctx: c, ptr: ptr})
+			}
+		})
+	}
+	return Value{ctx: c, ptr: ptr, ref: ref}
+}
+
+// Free releases the value's reference, so the JavaScript value can be
+// collected once nothing else refers to it, and returns its slot to the
+// runtime. Freeing a value again, or any copy of it, does nothing. Methods
+// called on a freed value return ErrFreed, or the result they give for the
+// zero Value if they return no error. Values of a closed context or runtime
+// need not be freed; their memory is already released.
+func (v Value) Free() {
+	if v.ctx == nil {
+		return
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	v.freeUnlocked()
+}
+
+// freeUnlocked is Free for a value that is not the zero Value. Caller must
+// hold the mutex.
+func (v Value) freeUnlocked() {
+	if v.ref != nil && v.ref.freed.Swap(true) {
+		return
+	}
+	if v.ctx.closed || v.ctx.runtime.closed {
+		return
+	}
+	_ = v.ctx.runtime.bridge.FreeValue(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+}
+
+// usable returns the error a method should return for the value, if it is
+// the zero Value or has been freed.
+func (v Value) usable() error {
+	if v.ctx == nil {
+		return errors.New("nil value")
+	}
+	if v.freed() {
+		return ErrFreed
+	}
+	return nil
+}
+
+// freed reports whether the value's reference has been released.
+func (v Value) freed() bool {
+	return v.ref != nil && v.ref.freed.Load()
+}
+
+// checkFreed returns ErrFreed if any of vals has been freed, for methods
+// passing values to the engine.
+func checkFreed(vals ...Value) error {
+	for _, v := range vals {
+		if v.freed() {
+			return ErrFreed
+		}
+	}
+	return nil
+}
+
+// handOver marks the result of a GoFunc as taken over by the engine, which
+// loads the value from its slot and keeps the reference. The slot itself is
+// released later, once the engine is done with it. Caller must hold the
+// mutex.
+func (r *Runtime) handOver(v Value) {
+	if v.ctx == nil || v.ptr == 0 || v.freed() {
+		return
+	}
+	if v.ref != nil {
+		v.ref.freed.Store(true)
+	}
+	r.queueRelease(pendingRelease{ctx: v.ctx, ptr: v.ptr, consumed: true})
+}
+
+// queueRelease queues a slot for releasePending. It may be called without
+// holding the runtime mutex.
+func (r *Runtime) queueRelease(p pendingRelease) {
+	r.pendingMu.Lock()
+	r.pending = append(r.pending, p)
+	r.pendingMu.Unlock()
+	r.hasPending.Store(true)
+}
+
+// releasePending releases the queued slots. It runs when the mutex is
+// taken by a call from outside the runtime, so no value being released can
+// be in use by a call in progress. Caller must hold the mutex.
+func (r *Runtime) releasePending() {
+	r.pendingMu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.hasPending.Store(false)
+	r.pendingMu.Unlock()
+
+	if r.closed {
+		return
+	}
+	for _, p := range pending {
+		if p.ctx.closed {
+			continue
+		}
+		if p.consumed {
+			_ = r.bridge.ReleaseSlot(r.goCtx, p.ptr)
+		} else {
+			_ = r.bridge.FreeValue(r.goCtx, p.ctx.ctxPtr, p.ptr)
+		}
+	}
+}
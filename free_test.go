@@ -0,0 +1,341 @@
+package quickjs
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestValueFree(t *testing.T) {
+	ctx := newTestContext(t)
+
+	obj, err := ctx.Eval("({n: 1})")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if err := ctx.SetGlobal("kept", obj); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+	copied := obj
+	obj.Free()
+	obj.Free()
+	copied.Free()
+
+	if _, err := obj.Get("n"); !errors.Is(err, ErrFreed) {
+		t.Errorf("Get after Free error = %v, want ErrFreed", err)
+	}
+	if _, err := copied.JSONStringify(); !errors.Is(err, ErrFreed) {
+		t.Errorf("JSONStringify of a freed copy error = %v, want ErrFreed", err)
+	}
+	if !obj.IsUndefined() {
+		t.Error("freed value should report undefined")
+	}
+	if err := ctx.SetGlobal("again", obj); !errors.Is(err, ErrFreed) {
+		t.Errorf("SetGlobal of a freed value error = %v, want ErrFreed", err)
+	}
+	global, err := ctx.Global()
+	if err != nil {
+		t.Fatalf("Global error = %v", err)
+	}
+	defer global.Free()
+	if err := global.SetAtom(ctx.Atom("again"), obj); !errors.Is(err, ErrFreed) {
+		t.Errorf("SetAtom of a freed value error = %v, want ErrFreed", err)
+	}
+
+	// SetGlobal took its own reference
+	val, err := ctx.Eval("kept.n")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 1 {
+		t.Errorf("kept.n = %d, want 1", n)
+	}
+
+	// Freeing returns the slot, so far more values than there are slots
+	// can be made
+	for i := range 70000 {
+		v, err := ctx.Eval("'x'.repeat(10)")
+		if err != nil {
+			t.Fatalf("Eval %d error = %v", i, err)
+		}
+		v.Free()
+	}
+
+	// The value a GoFunc returns is handed over to JavaScript
+	var returned Value
+	fn := ctx.Function("make", func(ctx *Context, this Value, args []Value) Value {
+		returned = ctx.String("made")
+		return returned
+	})
+	if err := ctx.SetGlobal("make", fn); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+	fn.Free()
+	val, err = ctx.Eval("make() + make()")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got := val.String(); got != "mademade" {
+		t.Errorf("make() + make() = %q, want %q", got, "mademade")
+	}
+	if _, err := returned.JSONStringify(); !errors.Is(err, ErrFreed) {
+		t.Errorf("use of a returned value error = %v, want ErrFreed", err)
+	}
+
+	// Returning it, or any freed value, again throws rather than handing
+	// over a slot JavaScript may already have reused
+	fn = ctx.Function("again", func(ctx *Context, this Value, args []Value) Value {
+		return returned
+	})
+	if err := ctx.SetGlobal("again", fn); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+	fn.Free()
+	threw, err := ctx.Eval("const made = make(); try { again(); false } catch (e) { e instanceof TypeError && made === 'made' }")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if !threw.Bool() {
+		t.Error("returning a handed over value should throw a TypeError")
+	}
+	threw.Free()
+
+	var zero Value
+	zero.Free()
+	ctx.Close()
+	val.Free()
+}
+
+func TestFinalizers(t *testing.T) {
+	rt, err := NewRuntimeWithConfig(Config{Finalizers: true})
+	if err != nil {
+		t.Fatalf("NewRuntimeWithConfig error = %v", err)
+	}
+	defer rt.Close()
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer ctx.Close()
+
+	// Values dropped without Free are reclaimed once collected
+	for i := range 70000 {
+		if _, err := ctx.Eval("({})"); err != nil {
+			t.Fatalf("Eval %d error = %v", i, err)
+		}
+		if i%10000 == 0 {
+			runtime.GC()
+		}
+	}
+	runtime.GC()
+	runtime.GC()
+	if err := rt.RunGC(); err != nil {
+		t.Fatalf("RunGC error = %v", err)
+	}
+	usage, err := rt.MemoryUsage()
+	if err != nil {
+		t.Fatalf("MemoryUsage error = %v", err)
+	}
+	if usage.ObjectCount > 10000 {
+		t.Errorf("ObjectCount = %d after dropping 70000 objects", usage.ObjectCount)
+	}
+
+	// A freed value is not released again by its finalizer
+	val, err := ctx.Eval("({})")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	val.Free()
+	val = Value{}
+	runtime.GC()
+	runtime.GC()
+	if _, err := ctx.Eval("1"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+}
+
+// freeSlots returns the number of free value slots, found by taking them
+// all and giving them back.
+func freeSlots(t *testing.T, ctx *Context) int {
+	t.Helper()
+	r := ctx.runtime
+	r.lock()
+	defer r.unlock()
+	var ptrs []uint32
+	for {
+		ptr, err := r.bridge.NewUndefined(r.goCtx)
+		if err != nil {
+			t.Fatalf("NewUndefined error = %v", err)
+		}
+		if ptr == 0 {
+			break
+		}
+		ptrs = append(ptrs, ptr)
+	}
+	for _, ptr := range ptrs {
+		if err := r.bridge.FreeValue(r.goCtx, ctx.ctxPtr, ptr); err != nil {
+			t.Fatalf("FreeValue error = %v", err)
+		}
+	}
+	return len(ptrs)
+}
+
+// TestHelperCallsReleaseSlots checks that methods calling JavaScript
+// helpers give back every value slot they take, apart from the values they
+// return, so they can be called any number of times.
+func TestHelperCallsReleaseSlots(t *testing.T) {
+	ctx := newTestContext(t)
+
+	obj, err := ctx.Eval("({a: 1, b: [1, 2]})")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	defer obj.Free()
+	m, err := ctx.Eval("new Map([[1, 'one']])")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	defer m.Free()
+
+	floats, err := ctx.Eval("new Float32Array([1, 2])")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	defer floats.Free()
+
+	addFn, err := ctx.Eval("(a, b) => a + b")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	defer addFn.Free()
+	add := addFn.AsGoFunc()
+
+	arr, err := ctx.Eval("[1, 2, 3]")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	defer arr.Free()
+
+	tr, err := ctx.Transformer("(doc) => doc.a")
+	if err != nil {
+		t.Fatalf("Transformer error = %v", err)
+	}
+	defer tr.Close()
+
+	strMap, err := ctx.Eval("new Map([['a', {}], ['b', 2]])")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	defer strMap.Free()
+
+	key := ctx.String("a")
+	defer key.Free()
+
+	fail := ctx.FunctionWithError("fail", func(ctx *Context, this Value, args []Value) (Value, error) {
+		return Value{}, errors.New("boom")
+	})
+	defer fail.Free()
+	undefined := ctx.Undefined()
+	defer undefined.Free()
+
+	tests := []struct {
+		name string
+		call func()
+	}{
+		{"Clone", func() {
+			clone, _ := obj.Clone()
+			clone.Free()
+		}},
+		{"Inspect", func() { _ = m.Inspect() }},
+		{"StrictEquals", func() { _ = obj.StrictEquals(m) }},
+		{"SetNow", func() {
+			other, _ := ctx.runtime.NewContext()
+			_ = other.SetNow(func() float64 { return 0 })
+			other.Close()
+		}},
+		{"Iterate", func() { _ = m.Iterate(func(Value) error { return nil }) }},
+		{"IterateAsync", func() { _ = m.IterateAsync(func(Value) error { return nil }) }},
+		{"IsPlainObject", func() { _ = obj.IsPlainObject() }},
+		{"Float32Slice", func() { _, _ = floats.Float32Slice() }},
+		{"ParseInt", func() { _, _ = ctx.ParseInt("0x1f", 0) }},
+		{"ParseFloat", func() { _, _ = ctx.ParseFloat("1e3px") }},
+		{"AsGoFunc", func() { _, _ = add(1, 2) }},
+		{"AllKeys", func() { _, _ = obj.AllKeys() }},
+		{"SetLength", func() { _ = arr.SetLength(2) }},
+		{"Hash", func() { _, _ = obj.Hash() }},
+		{"IsJSONSerializable", func() { _ = obj.IsJSONSerializable() }},
+		{"Transformer.Apply", func() { _, _ = tr.Apply([]byte(`{"a": 1}`)) }},
+		{"MapToGo", func() {
+			entries, _ := strMap.MapToGo()
+			for _, val := range entries {
+				val.Free()
+			}
+		}},
+		{"ReadOnlyView", func() {
+			view, _ := obj.ReadOnlyView()
+			view.Free()
+		}},
+		{"CallSpread", func() {
+			result, _ := addFn.CallSpread(obj, arr)
+			result.Free()
+		}},
+		{"ToNumber", func() { _, _ = obj.ToNumber() }},
+		{"SetName", func() { _ = addFn.SetName("add") }},
+		{"WrapChannel", func() {
+			it, _ := ctx.WrapChannel(make(chan any))
+			it.Free()
+		}},
+		{"CallerLocation", func() { _, _, _ = ctx.CallerLocation() }},
+		{"DefineAccessor", func() {
+			_ = obj.DefineAccessor("x", func(ctx *Context, this Value, args []Value) Value { return ctx.Int32(1) }, nil)
+		}},
+		{"RegisterErrorClass", func() {
+			class, _ := ctx.RegisterErrorClass("AppError", "")
+			class.Free()
+		}},
+		{"InKey", func() { _ = obj.InKey(key) }},
+		{"GetKey", func() {
+			val, _ := obj.GetKey(key)
+			val.Free()
+		}},
+		{"SetKey", func() { _ = obj.SetKey(key, key) }},
+		{"SetToStringTag", func() { _ = obj.SetToStringTag("Thing") }},
+		{"EvalJSON", func() { _, _ = ctx.EvalJSON("({a: 1})") }},
+		{"JSONStringify", func() { _, _ = obj.JSONStringify() }},
+		{"IsDetachedBuffer", func() { _ = floats.IsDetachedBuffer() }},
+		{"ParameterNames", func() { _, _ = addFn.ParameterNames() }},
+		{"EvalCollect", func() {
+			globals, _ := ctx.EvalCollect("var collected = 1")
+			for _, val := range globals {
+				val.Free()
+			}
+		}},
+		{"WrapSlice", func() {
+			proxy, _ := ctx.WrapSlice(&[]any{1})
+			proxy.Free()
+		}},
+		{"RunMain", func() {
+			result, _ := ctx.RunMain("function main(args) { return args.length }", []string{"a"})
+			result.Free()
+		}},
+		{"Resolve", func() {
+			p, _ := ctx.Resolve(obj)
+			p.Free()
+		}},
+		{"Reject", func() {
+			p, _ := ctx.Reject(obj)
+			p.Free()
+		}},
+		{"FunctionWithError", func() { _, _ = fail.Call(undefined) }},
+	}
+	for _, tt := range tests {
+		tt.call() // builds the helper, which the context keeps
+		before := freeSlots(t, ctx)
+		for range 3 {
+			tt.call()
+		}
+		if after := freeSlots(t, ctx); after != before {
+			t.Errorf("%s kept %d value slots over 3 calls", tt.name, before-after)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package quickjs
+
+// helper returns the JavaScript function defined by src, compiling it the
+// first time it is requested in this context. Helpers are used for
+// operations that are simpler to express in JavaScript than through the
+// bridge, without adding anything to the global object. Caller must hold the
+// mutex.
+func (c *Context) helper(name, src string) (Value, error) {
+	if fn, ok := c.helpers[name]; ok {
+		return fn, nil
+	}
+	fn, err := c.evalFile("("+src+")", "<"+name+">")
+	if err != nil {
+		return Value{}, err
+	}
+	if c.helpers == nil {
+		c.helpers = make(map[string]Value)
+	}
+	c.helpers[name] = fn
+	return fn, nil
+}
+
+// cloneJS deep-copies a value, keeping shared and cyclic references intact.
+const cloneJS = `function clone(value) {
+	const seen = new Map();
+	const copy = (v) => {
+		if (typeof v === "function" || typeof v === "symbol") {
+			throw new TypeError(typeof v + " values cannot be cloned");
+		}
+		if (v === null || typeof v !== "object") return v;
+		if (seen.has(v)) return seen.get(v);
+
+		let out;
+		if (Array.isArray(v)) {
+			out = new Array(v.length);
+			seen.set(v, out);
+			for (let i = 0; i < v.length; i++) out[i] = copy(v[i]);
+			return out;
+		}
+		if (v instanceof Date) out = new Date(v.getTime());
+		else if (v instanceof RegExp) out = new RegExp(v.source, v.flags);
+		else if (v instanceof ArrayBuffer) out = v.slice(0);
+		else if (ArrayBuffer.isView(v)) out = v.slice ? v.slice() : new DataView(v.buffer.slice(v.byteOffset, v.byteOffset + v.byteLength));
+		else if (v instanceof Map) {
+			out = new Map();
+			seen.set(v, out);
+			for (const [k, val] of v) out.set(copy(k), copy(val));
+			return out;
+		} else if (v instanceof Set) {
+			out = new Set();
+			seen.set(v, out);
+			for (const val of v) out.add(copy(val));
+			return out;
+		} else if (v instanceof Error) {
+			out = new v.constructor(v.message);
+			if ("cause" in v) out.cause = copy(v.cause);
+		} else out = {};
+		seen.set(v, out);
+		for (const key of Object.keys(v)) out[key] = copy(v[key]);
+		return out;
+	};
+	return copy(value);
+}`
+
+// iteratorJS returns the iterator of an iterable, as used by for...of.
+const iteratorJS = `function iterator(iterable) {
+	const method = iterable == null ? undefined : iterable[Symbol.iterator];
+	if (typeof method !== "function") {
+		throw new TypeError("value is not iterable");
+	}
+	return method.call(iterable);
+}`
+
+// asyncIteratorJS returns the async iterator of an iterable, as used by
+// for await...of. A sync iterator is wrapped so its values are awaited.
+const asyncIteratorJS = `function asyncIterator(iterable) {
+	if (iterable != null && typeof iterable[Symbol.asyncIterator] === "function") {
+		return iterable[Symbol.asyncIterator]();
+	}
+	const method = iterable == null ? undefined : iterable[Symbol.iterator];
+	if (typeof method !== "function") {
+		throw new TypeError("value is not async iterable");
+	}
+	const it = method.call(iterable);
+	return {
+		next() {
+			const { done, value } = it.next();
+			return Promise.resolve(value).then((value) => ({ done, value }));
+		},
+		return() {
+			return Promise.resolve(typeof it.return === "function" ? it.return() : { done: true });
+		},
+	};
+}`
+
+// settleJS records the outcome of a promise, or any other value, once it
+// settles.
+const settleJS = `function settle(value) {
+	const state = { settled: false };
+	Promise.resolve(value).then(
+		(value) => { state.settled = true; state.value = value; },
+		(reason) => { state.settled = true; state.reason = reason; },
+	);
+	return state;
+}`
+
+// isPlainObjectJS reports whether an object's prototype is Object.prototype
+// or null.
+const isPlainObjectJS = `function isPlainObject(obj) {
+	const proto = Object.getPrototypeOf(obj);
+	return proto === null || proto === Object.prototype;
+}`
+
+// strictEqualsJS applies the === operator.
+const strictEqualsJS = `function strictEquals(a, b) {
+	return a === b;
+}`
+
+// inspectEntriesJS returns the kind of an object with the entries Inspect
+// shows besides its string-keyed properties.
+const inspectEntriesJS = `function inspectEntries(obj) {
+	if (obj instanceof Map) return ["Map", Array.from(obj)];
+	if (obj instanceof Set) return ["Set", Array.from(obj)];
+	const symbols = Object.getOwnPropertySymbols(obj)
+		.filter((s) => Object.prototype.propertyIsEnumerable.call(obj, s));
+	return ["Object", symbols.map((s) => [s, obj[s]])];
+}`
+
+// typedArrayInfoJS describes a typed array's position in its buffer, or
+// returns null for anything else.
+const typedArrayInfoJS = `function typedArrayInfo(v) {
+	if (!ArrayBuffer.isView(v) || v instanceof DataView) return null;
+	return [v[Symbol.toStringTag], v.buffer, v.byteOffset, v.length];
+}`
+
+// parseIntegerJS and parseFloatJS apply the global number parsers, captured
+// when each helper is first compiled so that later replacements of the
+// globals do not affect them.
+const parseIntegerJS = `((parse) => function parseInteger(s, radix) {
+	return parse(s, radix);
+})(parseInt)`
+
+const parseFloatJS = `((parse) => function parseFloat(s) {
+	return parse(s);
+})(parseFloat)`
+
+// allKeysJS lists the string keys along a value's prototype chain.
+const allKeysJS = `function allKeys(value) {
+	if (value === null || value === undefined) {
+		throw new TypeError("cannot list the keys of " + value);
+	}
+	const keys = new Set();
+	const seen = new Set();
+	for (let o = Object(value); o !== null && !seen.has(o); o = Object.getPrototypeOf(o)) {
+		seen.add(o);
+		for (const key of Object.getOwnPropertyNames(o)) keys.add(key);
+	}
+	return Array.from(keys);
+}`
+
+// setLengthJS assigns an array's length in strict mode, so that failures
+// such as a frozen array throw instead of being ignored.
+const setLengthJS = `function setLength(arr, n) {
+	"use strict";
+	arr.length = n;
+}`
+
+// canonicalJSONJS serializes a value like JSON.stringify, but with object
+// keys sorted, so that equal values give the same string whatever order
+// their properties were added in. The value must be JSON-serializable, as
+// checked by isJSONSerializableJS.
+const canonicalJSONJS = `function canonicalJSON(value) {
+	const encode = (v) => {
+		if (v === null || typeof v !== "object") return JSON.stringify(v);
+		if (Array.isArray(v)) return "[" + v.map(encode).join(",") + "]";
+		return "{" + Object.keys(v).sort().map((k) => JSON.stringify(k) + ":" + encode(v[k])).join(",") + "}";
+	};
+	return encode(value);
+}`
+
+// applyJSONJS calls a Transformer's function and stringifies its result.
+const applyJSONJS = `function applyJSON(fn, input) {
+	const out = JSON.stringify(fn(input));
+	if (out === undefined) {
+		throw new TypeError("transform result is not JSON-serializable");
+	}
+	return out;
+}`
+
+// mapEntriesJS flattens a Map with string keys into [key, value, ...] for
+// MapToGo.
+const mapEntriesJS = `function mapEntries(m) {
+	if (!(m instanceof Map)) throw new TypeError("value is not a Map");
+	const out = [];
+	for (const [k, v] of m) {
+		if (typeof k !== "string") {
+			throw new TypeError("Map key of type " + typeof k + " is not a string");
+		}
+		out.push(k, v);
+	}
+	return out;
+}`
+
+// isJSONSerializableJS implements IsJSONSerializable.
+const isJSONSerializableJS = `function isJSONSerializable(value) {
+	const ancestors = [];
+	const check = (v) => {
+		switch (typeof v) {
+		case "string":
+		case "boolean":
+			return true;
+		case "number":
+			return Number.isFinite(v);
+		case "object":
+			break;
+		default:
+			return false;
+		}
+		if (v === null) return true;
+		if (ancestors.includes(v)) return false;
+		const isArray = Array.isArray(v);
+		const proto = Object.getPrototypeOf(v);
+		if (!isArray && proto !== Object.prototype && proto !== null) return false;
+		if (Object.getOwnPropertySymbols(v).some((s) => Object.prototype.propertyIsEnumerable.call(v, s))) {
+			return false;
+		}
+		ancestors.push(v);
+		let ok = true;
+		if (isArray) {
+			for (let i = 0; ok && i < v.length; i++) ok = i in v && check(v[i]);
+		} else {
+			ok = Object.keys(v).every((k) => check(v[k]));
+		}
+		ancestors.pop();
+		return ok;
+	};
+	return check(value);
+}`
+
+// callSpreadJS implements CallSpread.
+const callSpreadJS = `function callSpread(fn, thisArg, args) {
+	return Reflect.apply(fn, thisArg, [...args]);
+}`
+
+// getKeyJS and setKeyJS implement Value.GetKey and SetKey. setKey runs in
+// strict mode so that failed assignments throw, as they do for Set.
+const getKeyJS = `(obj, key) => obj[key]`
+
+const setKeyJS = `function setKey(obj, key, val) {
+	"use strict";
+	obj[key] = val;
+}`
+
+// setToStringTagJS implements Value.SetToStringTag.
+const setToStringTagJS = `function setToStringTag(obj, tag) {
+	Object.defineProperty(obj, Symbol.toStringTag, { value: tag, configurable: true });
+}`
+
+// isDetachedJS implements Value.IsDetachedBuffer with the detached getter
+// of ArrayBuffer.prototype, which throws for anything but an ArrayBuffer.
+const isDetachedJS = `(() => {
+	const detached = Object.getOwnPropertyDescriptor(ArrayBuffer.prototype, "detached").get;
+	return (v) => {
+		try {
+			return detached.call(v);
+		} catch {
+			return false;
+		}
+	};
+})()`
+
+// inJS implements Value.InKey.
+const inJS = `(key, obj) => key in obj`
+
+// registerErrorClassJS implements RegisterErrorClass. The class and its
+// prototype get name as their name, and the class becomes a non-enumerable
+// global like the built-in error classes.
+const registerErrorClassJS = `function registerErrorClass(name, parent) {
+	const Parent = globalThis[parent];
+	if (typeof Parent !== "function" || (Parent !== Error && !(Parent.prototype instanceof Error))) {
+		throw new TypeError(parent + " is not an error class");
+	}
+	const cls = class extends Parent {};
+	Object.defineProperty(cls, "name", { value: name, configurable: true });
+	Object.defineProperty(cls.prototype, "name", { value: name, writable: true, configurable: true });
+	Object.defineProperty(globalThis, name, { value: cls, writable: true, configurable: true });
+	return cls;
+}`
+
+// defineAccessorJS implements DefineAccessor. An undefined getter or setter
+// leaves that half of the accessor out.
+const defineAccessorJS = `function defineAccessor(obj, name, get, set) {
+	Object.defineProperty(obj, name, { get, set, enumerable: true, configurable: true });
+}`
+
+// setNameJS implements SetName, with the attributes functions are created
+// with.
+const setNameJS = `function setName(fn, name) {
+	Object.defineProperty(fn, "name", {value: name, writable: false, enumerable: false, configurable: true});
+}`
+
+// toNumberJS implements ToNumber.
+const toNumberJS = `function toNumber(v) {
+	return +v;
+}`
+
+// readOnlyViewJS implements ReadOnlyView. Frozen properties are returned
+// unwrapped, as the Proxy invariants require.
+const readOnlyViewJS = `function readOnlyView(root) {
+	const views = new WeakMap();
+	const view = (v) => {
+		if (v === null || (typeof v !== "object" && typeof v !== "function")) return v;
+		let p = views.get(v);
+		if (p === undefined) {
+			p = new Proxy(v, handler);
+			views.set(v, p);
+		}
+		return p;
+	};
+	const deny = () => false;
+	const handler = {
+		get(target, key, receiver) {
+			const value = Reflect.get(target, key, receiver);
+			const d = Reflect.getOwnPropertyDescriptor(target, key);
+			return d !== undefined && !d.configurable && d.writable === false ? value : view(value);
+		},
+		getOwnPropertyDescriptor(target, key) {
+			const d = Reflect.getOwnPropertyDescriptor(target, key);
+			if (d !== undefined && d.configurable) {
+				if ("value" in d) d.value = view(d.value);
+				if (d.get !== undefined) d.get = view(d.get);
+				if (d.set !== undefined) d.set = view(d.set);
+			}
+			return d;
+		},
+		apply: (target, thisArg, args) => view(Reflect.apply(target, thisArg, args)),
+		construct: (target, args, newTarget) => view(Reflect.construct(target, args, newTarget)),
+		set: deny,
+		defineProperty: deny,
+		deleteProperty: deny,
+		setPrototypeOf: deny,
+		preventExtensions: deny,
+	};
+	return view(root);
+}`
+
+// kindsJS implements KindsOf, returning one digit per argument with the
+// value of its Kind.
+const kindsJS = `function kinds(...vals) {
+	const codes = {
+		undefined: "0", boolean: "2", number: "3", bigint: "4",
+		string: "5", symbol: "6", object: "7", function: "9",
+	};
+	let out = "";
+	for (const v of vals) {
+		if (v === null) out += "1";
+		else if (Array.isArray(v)) out += "8";
+		else out += codes[typeof v] ?? "7";
+	}
+	return out;
+}`
+
+// channelIteratorJS builds the iterator returned by WrapChannel around its
+// receive function, which returns the next value in a one-element array, or
+// null once the channel is closed.
+const channelIteratorJS = `function channelIterator(receive) {
+	let finished = false;
+	return {
+		next() {
+			if (finished) return Promise.resolve({ done: true, value: undefined });
+			try {
+				const box = receive();
+				if (box === null) {
+					finished = true;
+					return Promise.resolve({ done: true, value: undefined });
+				}
+				return Promise.resolve({ done: false, value: box[0] });
+			} catch (e) {
+				return Promise.reject(e);
+			}
+		},
+		return(value) {
+			finished = true;
+			return Promise.resolve({ done: true, value });
+		},
+		[Symbol.asyncIterator]() {
+			return this;
+		},
+	};
+}`
+
+// functionSourceJS returns the source text of a function, ignoring any
+// toString method it has of its own.
+const functionSourceJS = `function functionSource(fn) {
+	return Function.prototype.toString.call(fn);
+}`
+
+// globalsSnapshotJS records the value of each own data property of the
+// global object, for globalsChanged to compare against.
+const globalsSnapshotJS = `function globalsSnapshot() {
+	const snapshot = new Map();
+	for (const key of Object.getOwnPropertyNames(globalThis)) {
+		const desc = Object.getOwnPropertyDescriptor(globalThis, key);
+		if (desc && "value" in desc) snapshot.set(key, desc.value);
+	}
+	return snapshot;
+}`
+
+// globalsChangedJS returns a Map of the own data properties of the global
+// object that are new or hold a different value than in the snapshot.
+// Accessors are skipped, so no getter runs.
+const globalsChangedJS = `function globalsChanged(snapshot) {
+	const changed = new Map();
+	for (const key of Object.getOwnPropertyNames(globalThis)) {
+		const desc = Object.getOwnPropertyDescriptor(globalThis, key);
+		if (!desc || !("value" in desc)) continue;
+		if (snapshot.has(key) && Object.is(snapshot.get(key), desc.value)) continue;
+		changed.set(key, desc.value);
+	}
+	return changed;
+}`
+
+// sliceProxyJS implements WrapSlice: an array Proxy whose elements and
+// length are read from and written to the Go slice through the given
+// functions. Other properties, such as the array methods, come from the
+// array target, and the methods then work through the proxy.
+const sliceProxyJS = `function sliceProxy(length, get, set, setLength) {
+	const index = (key) => {
+		if (typeof key !== "string") return -1;
+		const i = Number(key);
+		return String(i >>> 0) === key && i !== 4294967295 ? i : -1;
+	};
+	const element = (i) => ({ value: get(i), writable: true, enumerable: true, configurable: true });
+	const handler = {
+		get(target, key, receiver) {
+			if (key === "length") return length();
+			const i = index(key);
+			if (i >= 0) return i < length() ? get(i) : undefined;
+			return Reflect.get(target, key, receiver);
+		},
+		set(target, key, value, receiver) {
+			if (key === "length") {
+				const n = Number(value);
+				if (n >>> 0 !== n) throw new RangeError("invalid array length");
+				setLength(n);
+				return true;
+			}
+			const i = index(key);
+			if (i >= 0) {
+				set(i, value);
+				return true;
+			}
+			return Reflect.set(target, key, value);
+		},
+		has(target, key) {
+			const i = index(key);
+			return i >= 0 ? i < length() : Reflect.has(target, key);
+		},
+		deleteProperty(target, key) {
+			const i = index(key);
+			if (i < 0) return Reflect.deleteProperty(target, key);
+			if (i < length()) set(i, undefined);
+			return true;
+		},
+		defineProperty(target, key, desc) {
+			const i = index(key);
+			if (i < 0 && key !== "length") return Reflect.defineProperty(target, key, desc);
+			return "value" in desc && handler.set(target, key, desc.value);
+		},
+		getOwnPropertyDescriptor(target, key) {
+			if (key === "length") {
+				return { value: length(), writable: true, enumerable: false, configurable: false };
+			}
+			const i = index(key);
+			if (i >= 0) return i < length() ? element(i) : undefined;
+			return Reflect.getOwnPropertyDescriptor(target, key);
+		},
+		ownKeys(target) {
+			const keys = Array.from({ length: length() }, (_, i) => String(i));
+			return keys.concat(Reflect.ownKeys(target).filter((key) => index(key) < 0));
+		},
+	};
+	return new Proxy([], handler);
+}`
+
+// resolvedJS and rejectedJS implement Context.Resolve and Context.Reject.
+const resolvedJS = `(value) => Promise.resolve(value)`
+
+const rejectedJS = `(reason) => Promise.reject(reason)`
+
+// newErrorJS makes the Error thrown for a Go error, see FunctionWithError.
+const newErrorJS = `(message) => new Error(message)`
+
+// callMainJS implements RunMain. It is compiled in global scope, so main
+// may be declared with let or const as well as function or var.
+const callMainJS = `function callMain(args) {
+	if (typeof main !== "function") throw new TypeError("script does not define a main function");
+	return main(args);
+}`
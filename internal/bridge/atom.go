@@ -0,0 +1,59 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+)
+
+// NewAtom returns the atom for a property name. The atom holds a reference
+// that FreeAtom releases.
+func (b *Bridge) NewAtom(ctx context.Context, ctxPtr uint32, name string) (uint32, error) {
+	namePtr, err := b.WriteString(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	results, err := b.fnNewAtom.Call(ctx, uint64(ctxPtr), uint64(namePtr), uint64(len(name)))
+	if err != nil {
+		return 0, err
+	}
+	atom := uint32(results[0])
+	if atom == 0 { // JS_ATOM_NULL
+		return 0, errors.New("failed to create atom")
+	}
+	return atom, nil
+}
+
+// FreeAtom releases an atom returned by NewAtom.
+func (b *Bridge) FreeAtom(ctx context.Context, ctxPtr, atom uint32) error {
+	_, err := b.fnFreeAtom.Call(ctx, uint64(ctxPtr), uint64(atom))
+	return err
+}
+
+// GetPropertyAtom gets the property named by an atom, like GetProperty.
+func (b *Bridge) GetPropertyAtom(ctx context.Context, ctxPtr, objPtr, atom uint32) (uint32, error) {
+	results, err := b.fnGetPropertyAtom.Call(ctx, uint64(ctxPtr), uint64(objPtr), uint64(atom))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(results[0]), nil
+}
+
+// SetPropertyAtom sets the property named by an atom, like SetProperty.
+func (b *Bridge) SetPropertyAtom(ctx context.Context, ctxPtr, objPtr, atom, valPtr uint32) error {
+	results, err := b.fnSetPropertyAtom.Call(ctx, uint64(ctxPtr), uint64(objPtr), uint64(atom), uint64(valPtr))
+	if err != nil {
+		return err
+	}
+	if int32(results[0]) < 0 {
+		return errors.New("failed to set property")
+	}
+	return nil
+}
+
+// ReleaseSlot returns a slot to the free list without freeing the value in
+// it, for a slot whose reference the engine has taken over, such as the
+// result of a Go function.
+func (b *Bridge) ReleaseSlot(ctx context.Context, slot uint32) error {
+	_, err := b.fnReleaseSlot.Call(ctx, uint64(slot))
+	return err
+}
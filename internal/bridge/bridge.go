@@ -8,7 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
@@ -115,6 +118,10 @@ type Bridge struct {
 	fnDeleteProperty      api.Function
 	fnGetPropertyUint32   api.Function
 	fnSetPropertyUint32   api.Function
+	fnNewAtom             api.Function
+	fnFreeAtom            api.Function
+	fnGetPropertyAtom     api.Function
+	fnSetPropertyAtom     api.Function
 	fnGetGlobalObject     api.Function
 	fnCall                api.Function
 	fnCallConstructor     api.Function
@@ -127,8 +134,10 @@ type Bridge struct {
 	fnThrowRangeError     api.Function
 	fnThrowSyntaxError    api.Function
 	fnThrowReferenceError api.Function
+	fnGetStackTrace       api.Function
 	fnDupValue            api.Function
 	fnFreeValue           api.Function
+	fnReleaseSlot         api.Function
 	fnJSONParse           api.Function
 	fnJSONStringify       api.Function
 	fnRunGC               api.Function
@@ -149,13 +158,83 @@ type Bridge struct {
 	fnStrictEq            api.Function
 	fnSetMemoryLimit      api.Function
 	fnSetMaxStackSize     api.Function
+	fnSetInterruptHandler api.Function
+	fnSetModuleLoader     api.Function
 	fnGetErrorMessage     api.Function
 	fnGetErrorStack       api.Function
 	fnToString            api.Function
+	fnWriteObject         api.Function
+	fnReadObject          api.Function
+	fnEvalFunction        api.Function
+	fnIsCompiledCode      api.Function
+
+	// QuickJS API functions exported directly from the engine
+	fnJSExecutePendingJob     api.Function
+	fnJSComputeMemoryUsage    api.Function
+	fnJSIsJobPending          api.Function
+	fnJSUpdateStackTop        api.Function
+	fnJSResetInterruptCounter api.Function
+	fnJSMalloc                api.Function
+	fnJSFree                  api.Function
+
+	// The module's stack pointer, and the base of the stack allocated by
+	// SetStackSize
+	sp        api.MutableGlobal
+	stackBase uint32
+
+	// Set to make running code throw an "interrupted" error; see
+	// SetInterrupt
+	interrupt atomic.Bool
+
+	// Fuel left, while metering; see StartFuel
+	fuel     int64
+	metering bool
+}
+
+// reentrantFunction is an exported function that may be called again while
+// a call to it is still in progress, as when Go calls a JavaScript function
+// that calls a Go function, which calls a JavaScript function in turn. A
+// wazero api.Function keeps its call stack in the instance, so reusing it for
+// the nested call corrupts the outer one; nested calls use spare instances
+// instead. Calls are serialized by the caller, so no locking is needed.
+//
+// A call that traps leaves the stack pointer where the trap happened, so the
+// outermost call puts it back.
+type reentrantFunction struct {
+	api.Function // instance used by the outermost call
+
+	module api.Module
+	sp     api.MutableGlobal
+	name   string
+	busy   bool
+	spares []api.Function
+}
+
+func (f *reentrantFunction) Call(ctx context.Context, params ...uint64) ([]uint64, error) {
+	if !f.busy {
+		f.busy = true
+		defer func() { f.busy = false }()
+		sp := f.sp.Get()
+		results, err := f.Function.Call(ctx, params...)
+		if err != nil {
+			f.sp.Set(sp)
+		}
+		return results, err
+	}
+
+	var fn api.Function
+	if n := len(f.spares); n > 0 {
+		fn, f.spares = f.spares[n-1], f.spares[:n-1]
+	} else {
+		fn = f.module.ExportedFunction(f.name)
+	}
+	defer func() { f.spares = append(f.spares, fn) }()
+	return fn.Call(ctx, params...)
 }
 
-// New creates a new Bridge instance.
-func New(ctx context.Context) (*Bridge, error) {
+// New creates a new Bridge instance. If clock is not nil, the module reads
+// both its wall clock and its monotonic clock from it.
+func New(ctx context.Context, clock func() time.Time) (*Bridge, error) {
 	b := &Bridge{
 		logFunc: func(msg string) {
 			fmt.Print(msg)
@@ -187,6 +266,9 @@ func New(ctx context.Context) (*Bridge, error) {
 		NewFunctionBuilder().
 		WithFunc(b.hostCallGo).
 		Export("host_call_go").
+		NewFunctionBuilder().
+		WithFunc(b.hostInterrupt).
+		Export("host_interrupt").
 		Instantiate(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to instantiate host module: %w", err)
@@ -198,7 +280,18 @@ func New(ctx context.Context) (*Bridge, error) {
 		return nil, fmt.Errorf("failed to compile WASM module: %w", err)
 	}
 
-	b.module, err = b.wasmRuntime.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	moduleConfig := wazero.NewModuleConfig()
+	if clock != nil {
+		moduleConfig = moduleConfig.
+			WithWalltime(func() (int64, int32) {
+				t := clock()
+				return t.Unix(), int32(t.Nanosecond())
+			}, 1).
+			WithNanotime(func() int64 {
+				return clock().UnixNano()
+			}, 1)
+	}
+	b.module, err = b.wasmRuntime.InstantiateModule(ctx, compiled, moduleConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to instantiate WASM module: %w", err)
 	}
@@ -217,12 +310,18 @@ func New(ctx context.Context) (*Bridge, error) {
 }
 
 func (b *Bridge) initFunctions() error {
+	sp, ok := b.module.ExportedGlobal(stackPointerExport).(api.MutableGlobal)
+	if !ok {
+		return errors.New("WASM module does not export its stack pointer")
+	}
+	b.sp = sp
+
 	getFn := func(name string) (api.Function, error) {
 		fn := b.module.ExportedFunction(name)
 		if fn == nil {
 			return nil, fmt.Errorf("function %s not found in WASM module", name)
 		}
-		return fn, nil
+		return &reentrantFunction{Function: fn, module: b.module, sp: sp, name: name}, nil
 	}
 
 	var err error
@@ -393,6 +492,18 @@ func (b *Bridge) initFunctions() error {
 	if b.fnSetPropertyUint32, err = getFn("qjs_set_property_uint32"); err != nil {
 		return err
 	}
+	if b.fnNewAtom, err = getFn("qjs_new_atom"); err != nil {
+		return err
+	}
+	if b.fnFreeAtom, err = getFn("qjs_free_atom"); err != nil {
+		return err
+	}
+	if b.fnGetPropertyAtom, err = getFn("qjs_get_property_atom"); err != nil {
+		return err
+	}
+	if b.fnSetPropertyAtom, err = getFn("qjs_set_property_atom"); err != nil {
+		return err
+	}
 	if b.fnGetGlobalObject, err = getFn("qjs_get_global_object"); err != nil {
 		return err
 	}
@@ -433,6 +544,9 @@ func (b *Bridge) initFunctions() error {
 	if b.fnThrowReferenceError, err = getFn("qjs_throw_reference_error"); err != nil {
 		return err
 	}
+	if b.fnGetStackTrace, err = getFn("qjs_get_stack_trace"); err != nil {
+		return err
+	}
 
 	// Value management
 	if b.fnDupValue, err = getFn("qjs_dup_value"); err != nil {
@@ -441,6 +555,9 @@ func (b *Bridge) initFunctions() error {
 	if b.fnFreeValue, err = getFn("qjs_free_value"); err != nil {
 		return err
 	}
+	if b.fnReleaseSlot, err = getFn("qjs_release_slot"); err != nil {
+		return err
+	}
 
 	// JSON
 	if b.fnJSONParse, err = getFn("qjs_json_parse"); err != nil {
@@ -525,6 +642,12 @@ func (b *Bridge) initFunctions() error {
 	if b.fnSetMaxStackSize, err = getFn("qjs_set_max_stack_size"); err != nil {
 		return err
 	}
+	if b.fnSetInterruptHandler, err = getFn("qjs_set_interrupt_handler"); err != nil {
+		return err
+	}
+	if b.fnSetModuleLoader, err = getFn("qjs_set_module_loader"); err != nil {
+		return err
+	}
 
 	// Error utilities
 	if b.fnGetErrorMessage, err = getFn("qjs_get_error_message"); err != nil {
@@ -539,6 +662,43 @@ func (b *Bridge) initFunctions() error {
 		return err
 	}
 
+	// Bytecode
+	if b.fnWriteObject, err = getFn("qjs_write_object"); err != nil {
+		return err
+	}
+	if b.fnReadObject, err = getFn("qjs_read_object"); err != nil {
+		return err
+	}
+	if b.fnEvalFunction, err = getFn("qjs_eval_function"); err != nil {
+		return err
+	}
+	if b.fnIsCompiledCode, err = getFn("qjs_is_compiled_code"); err != nil {
+		return err
+	}
+
+	// QuickJS API
+	if b.fnJSExecutePendingJob, err = getFn("JS_ExecutePendingJob"); err != nil {
+		return err
+	}
+	if b.fnJSComputeMemoryUsage, err = getFn("JS_ComputeMemoryUsage"); err != nil {
+		return err
+	}
+	if b.fnJSIsJobPending, err = getFn("JS_IsJobPending"); err != nil {
+		return err
+	}
+	if b.fnJSUpdateStackTop, err = getFn("JS_UpdateStackTop"); err != nil {
+		return err
+	}
+	if b.fnJSResetInterruptCounter, err = getFn("JS_ResetInterruptCounter"); err != nil {
+		return err
+	}
+	if b.fnJSMalloc, err = getFn("js_malloc"); err != nil {
+		return err
+	}
+	if b.fnJSFree, err = getFn("js_free"); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -910,6 +1070,25 @@ func (b *Bridge) IsPromise(ctx context.Context, ctxPtr, valPtr uint32) (bool, er
 	return results[0] != 0, nil
 }
 
+// NewPromise creates a pending promise and returns it together with the
+// functions that resolve and reject it.
+func (b *Bridge) NewPromise(ctx context.Context, ctxPtr uint32) (promisePtr, resolvePtr, rejectPtr uint32, err error) {
+	funcsPtr, err := b.Alloc(ctx, 8)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer b.Free(ctx, funcsPtr)
+	results, err := b.fnNewPromise.Call(ctx, uint64(ctxPtr), uint64(funcsPtr))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	buf, ok := b.memory.Read(funcsPtr, 8)
+	if !ok {
+		return 0, 0, 0, errors.New("failed to read resolving functions")
+	}
+	return uint32(results[0]), binary.LittleEndian.Uint32(buf), binary.LittleEndian.Uint32(buf[4:]), nil
+}
+
 // ============================================================================
 // Value Conversion
 // ============================================================================
@@ -1006,6 +1185,38 @@ func (b *Bridge) ToString(ctx context.Context, ctxPtr, valPtr uint32) (string, e
 	return str, nil
 }
 
+// ToStringBytes converts a value to a string like ToString, returning its
+// UTF-8 bytes. The length comes from JS_ToCStringLen, so the whole string
+// is returned, including any NUL characters. A nil slice with a nil error
+// means the conversion threw; the exception is left pending.
+func (b *Bridge) ToStringBytes(ctx context.Context, ctxPtr, valPtr uint32) ([]byte, error) {
+	lenPtr, err := b.Alloc(ctx, 4)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Free(ctx, lenPtr)
+
+	results, err := b.fnToCStringLen.Call(ctx, uint64(ctxPtr), uint64(valPtr), uint64(lenPtr))
+	if err != nil {
+		return nil, err
+	}
+	strPtr := uint32(results[0])
+	if strPtr == 0 {
+		return nil, nil
+	}
+	defer b.fnFreeCString.Call(ctx, uint64(ctxPtr), uint64(strPtr))
+
+	n, ok := b.memory.ReadUint32Le(lenPtr)
+	if !ok {
+		return nil, errors.New("failed to read string length")
+	}
+	data, ok := b.memory.Read(strPtr, n)
+	if !ok {
+		return nil, errors.New("failed to read string")
+	}
+	return bytes.Clone(data), nil
+}
+
 // ============================================================================
 // Value Creation
 // ============================================================================
@@ -1180,6 +1391,53 @@ func (b *Bridge) SetPropertyUint32(ctx context.Context, ctxPtr, objPtr, idx, val
 	return nil
 }
 
+// Flags for GetOwnPropertyNames, matching QuickJS's JS_GPN_* constants.
+const (
+	GPNStringMask = 1 << 0
+	GPNSymbolMask = 1 << 1
+	GPNEnumOnly   = 1 << 4
+)
+
+// GetOwnPropertyNames returns the names of the object's own properties
+// selected by flags.
+func (b *Bridge) GetOwnPropertyNames(ctx context.Context, ctxPtr, objPtr uint32, flags int32) ([]string, error) {
+	countPtr, err := b.Alloc(ctx, 4)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Free(ctx, countPtr)
+
+	results, err := b.fnGetOwnPropertyNames.Call(ctx, uint64(ctxPtr), uint64(objPtr), uint64(countPtr), uint64(uint32(flags)))
+	if err != nil {
+		return nil, err
+	}
+	arrPtr := uint32(results[0])
+	if arrPtr == 0 {
+		return nil, errors.New("failed to get own property names")
+	}
+	defer b.FreeValue(ctx, ctxPtr, arrPtr)
+
+	countBuf, ok := b.memory.Read(countPtr, 4)
+	if !ok {
+		return nil, errors.New("failed to read property count")
+	}
+	count := binary.LittleEndian.Uint32(countBuf)
+
+	names := make([]string, count)
+	for i := range count {
+		namePtr, err := b.GetPropertyUint32(ctx, ctxPtr, arrPtr, i)
+		if err != nil {
+			return nil, err
+		}
+		names[i], err = b.ToString(ctx, ctxPtr, namePtr)
+		_ = b.FreeValue(ctx, ctxPtr, namePtr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
 func (b *Bridge) GetGlobalObject(ctx context.Context, ctxPtr uint32) (uint32, error) {
 	results, err := b.fnGetGlobalObject.Call(ctx, uint64(ctxPtr))
 	if err != nil {
@@ -1297,6 +1555,24 @@ func (b *Bridge) HasException(ctx context.Context, ctxPtr uint32) (bool, error)
 	return results[0] != 0, nil
 }
 
+// GetStackTrace returns the stack trace of the running code, which is the
+// stack property of a new Error: a line per frame, innermost first.
+func (b *Bridge) GetStackTrace(ctx context.Context, ctxPtr uint32) (uint32, error) {
+	results, err := b.fnGetStackTrace.Call(ctx, uint64(ctxPtr))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(results[0]), nil
+}
+
+func (b *Bridge) Throw(ctx context.Context, ctxPtr, valPtr uint32) (uint32, error) {
+	results, err := b.fnThrow.Call(ctx, uint64(ctxPtr), uint64(valPtr))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(results[0]), nil
+}
+
 func (b *Bridge) ThrowError(ctx context.Context, ctxPtr uint32, msg string) (uint32, error) {
 	msgPtr, err := b.WriteString(ctx, msg)
 	if err != nil {
@@ -1321,19 +1597,36 @@ func (b *Bridge) ThrowTypeError(ctx context.Context, ctxPtr uint32, msg string)
 	return uint32(results[0]), nil
 }
 
+// errorMessageBufSize is the buffer size GetErrorMessage starts with.
+const errorMessageBufSize = 1024
+
+// GetErrorMessage returns an error's message, or its string form if the
+// message cannot be converted. qjs_get_error_message truncates the message
+// to fit the buffer, so a message that fills it is fetched again with a
+// larger one, up to the size of the transfer heap.
 func (b *Bridge) GetErrorMessage(ctx context.Context, ctxPtr, errPtr uint32) (string, error) {
-	bufPtr, err := b.Alloc(ctx, 1024)
+	heapSize, err := b.GetHeapSize(ctx)
 	if err != nil {
 		return "", err
 	}
+	maxSize := heapSize / 2
 
-	results, err := b.fnGetErrorMessage.Call(ctx, uint64(ctxPtr), uint64(errPtr), uint64(bufPtr), 1024)
-	if err != nil {
-		return "", err
+	size := uint32(errorMessageBufSize)
+	for {
+		bufPtr, err := b.Alloc(ctx, size)
+		if err != nil {
+			return "", err
+		}
+		results, err := b.fnGetErrorMessage.Call(ctx, uint64(ctxPtr), uint64(errPtr), uint64(bufPtr), uint64(size))
+		if err != nil {
+			return "", err
+		}
+		msgLen := uint32(results[0])
+		if msgLen+1 < size || size >= maxSize {
+			return b.ReadCString(bufPtr, msgLen+1), nil
+		}
+		size = min(size*4, maxSize)
 	}
-	msgLen := uint32(results[0])
-
-	return b.ReadCString(bufPtr, msgLen+1), nil
 }
 
 func (b *Bridge) GetErrorStack(ctx context.Context, ctxPtr, errPtr uint32) (string, error) {
@@ -1378,12 +1671,38 @@ func (b *Bridge) JSONParse(ctx context.Context, ctxPtr uint32, json string) (uin
 	return uint32(results[0]), nil
 }
 
+// JSONParseBytes parses JSON held in a byte slice. The bytes are copied into
+// WASM memory as they are, without first converting them to a string.
+func (b *Bridge) JSONParseBytes(ctx context.Context, ctxPtr uint32, data []byte) (uint32, error) {
+	size, err := b.GetHeapSize(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if uint64(len(data))+1 > uint64(size) {
+		return 0, fmt.Errorf("JSON document of %d bytes exceeds the %d byte transfer buffer", len(data), size)
+	}
+	jsonPtr, err := b.Alloc(ctx, uint32(len(data))+1)
+	if err != nil {
+		return 0, err
+	}
+	// JS_ParseJSON requires the input to be NUL-terminated
+	if !b.memory.Write(jsonPtr, data) || !b.memory.WriteByte(jsonPtr+uint32(len(data)), 0) {
+		return 0, errors.New("failed to write JSON to WASM memory")
+	}
+	results, err := b.fnJSONParse.Call(ctx, uint64(ctxPtr), uint64(jsonPtr), uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(results[0]), nil
+}
+
 func (b *Bridge) JSONStringify(ctx context.Context, ctxPtr, valPtr uint32) (string, error) {
 	results, err := b.fnJSONStringify.Call(ctx, uint64(ctxPtr), uint64(valPtr))
 	if err != nil {
 		return "", err
 	}
 	strValPtr := uint32(results[0])
+	defer b.FreeValue(ctx, ctxPtr, strValPtr)
 	return b.ToString(ctx, ctxPtr, strValPtr)
 }
 
@@ -1408,6 +1727,39 @@ func (b *Bridge) ExecutePendingJobs(ctx context.Context, rtPtr uint32) (int32, e
 	return int32(results[0]), nil
 }
 
+// IsJobPending reports whether the runtime has pending jobs.
+func (b *Bridge) IsJobPending(ctx context.Context, rtPtr uint32) (bool, error) {
+	results, err := b.fnJSIsJobPending.Call(ctx, uint64(rtPtr))
+	if err != nil {
+		return false, err
+	}
+	return uint32(results[0]) != 0, nil
+}
+
+// ExecutePendingJob runs a single pending job. It returns 1 if a job ran, 0
+// if there were none, and -1 if the job threw, in which case jobCtxPtr is the
+// context holding the pending exception.
+func (b *Bridge) ExecutePendingJob(ctx context.Context, rtPtr uint32) (ret int32, jobCtxPtr uint32, err error) {
+	pctxPtr, err := b.Alloc(ctx, 4)
+	if err != nil {
+		return -1, 0, err
+	}
+	defer b.Free(ctx, pctxPtr)
+	results, err := b.fnJSExecutePendingJob.Call(ctx, uint64(rtPtr), uint64(pctxPtr))
+	if err != nil {
+		return -1, 0, err
+	}
+	ret = int32(results[0])
+	if ret < 0 {
+		buf, ok := b.memory.Read(pctxPtr, 4)
+		if !ok {
+			return ret, 0, errors.New("failed to read job context")
+		}
+		jobCtxPtr = binary.LittleEndian.Uint32(buf)
+	}
+	return ret, jobCtxPtr, nil
+}
+
 // ============================================================================
 // BigInt
 // ============================================================================
@@ -1474,6 +1826,17 @@ func (b *Bridge) Instanceof(ctx context.Context, ctxPtr, valPtr, ctorPtr uint32)
 	return int32(results[0]) > 0, nil
 }
 
+// StrictEq reports whether two values have the same tag and payload. This
+// identifies objects, but is not the === operator: equal strings stored
+// separately differ, and NaN and -0 are compared by their bits.
+func (b *Bridge) StrictEq(ctx context.Context, val1Ptr, val2Ptr uint32) (bool, error) {
+	results, err := b.fnStrictEq.Call(ctx, uint64(val1Ptr), uint64(val2Ptr))
+	if err != nil {
+		return false, err
+	}
+	return int32(results[0]) != 0, nil
+}
+
 func (b *Bridge) Typeof(ctx context.Context, ctxPtr, valPtr uint32) (string, error) {
 	results, err := b.fnTypeof.Call(ctx, uint64(ctxPtr), uint64(valPtr))
 	if err != nil {
@@ -1504,27 +1867,42 @@ func (b *Bridge) NewArrayBuffer(ctx context.Context, ctxPtr uint32, data []byte)
 }
 
 func (b *Bridge) GetArrayBuffer(ctx context.Context, ctxPtr, valPtr uint32) ([]byte, error) {
-	lenPtr, err := b.Alloc(ctx, 4)
+	bufPtr, length, err := b.ArrayBufferData(ctx, ctxPtr, valPtr)
 	if err != nil {
 		return nil, err
 	}
+	return b.ReadBytes(bufPtr, length), nil
+}
+
+// ArrayBufferData returns the address and length of an ArrayBuffer's data in
+// WASM memory, without copying it. The address is valid until the buffer is
+// freed or detached.
+func (b *Bridge) ArrayBufferData(ctx context.Context, ctxPtr, valPtr uint32) (ptr, length uint32, err error) {
+	lenPtr, err := b.Alloc(ctx, 4)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer b.Free(ctx, lenPtr)
 
 	results, err := b.fnGetArrayBuffer.Call(ctx, uint64(ctxPtr), uint64(valPtr), uint64(lenPtr))
 	if err != nil {
-		return nil, err
+		return 0, 0, err
 	}
 	bufPtr := uint32(results[0])
 	if bufPtr == 0 {
-		return nil, errors.New("not an ArrayBuffer")
+		// JS_GetArrayBuffer throws a TypeError; drop it so it is not
+		// reported by the next call that checks for an exception
+		if exc, err := b.GetException(ctx, ctxPtr); err == nil {
+			b.FreeValue(ctx, ctxPtr, exc)
+		}
+		return 0, 0, errors.New("not an ArrayBuffer")
 	}
 
 	lenBuf, ok := b.memory.Read(lenPtr, 4)
 	if !ok {
-		return nil, errors.New("failed to read length")
+		return 0, 0, errors.New("failed to read length")
 	}
-	length := binary.LittleEndian.Uint32(lenBuf)
-
-	return b.ReadBytes(bufPtr, length), nil
+	return bufPtr, binary.LittleEndian.Uint32(lenBuf), nil
 }
 
 // ============================================================================
@@ -1571,15 +1949,93 @@ func (b *Bridge) SetMemoryLimit(ctx context.Context, rtPtr, limit uint32) error
 	return err
 }
 
+// SetMaxStackSize limits how far below the stack top the stack may grow
+// before calls throw a RangeError. Zero removes the limit.
 func (b *Bridge) SetMaxStackSize(ctx context.Context, rtPtr, stackSize uint32) error {
 	_, err := b.fnSetMaxStackSize.Call(ctx, uint64(rtPtr), uint64(stackSize))
 	return err
 }
 
+// SetupInterruptHandler installs the bridge's interrupt handler on the
+// runtime. QuickJS calls it every few thousand operations, and it makes the
+// running code throw an uncatchable "interrupted" error once SetInterrupt
+// sets the interrupt flag.
+func (b *Bridge) SetupInterruptHandler(ctx context.Context, rtPtr uint32) error {
+	_, err := b.fnSetInterruptHandler.Call(ctx, uint64(rtPtr))
+	return err
+}
+
+// SetModuleLoader makes the runtime load the modules that are imported but
+// not yet loaded by calling the Go function funcID, registered with
+// RegisterGoFunc, with the module's name. The function returns the source
+// of the module, or throws to fail the import. A funcID of 0 removes the
+// loader.
+func (b *Bridge) SetModuleLoader(ctx context.Context, rtPtr, funcID uint32) error {
+	_, err := b.fnSetModuleLoader.Call(ctx, uint64(rtPtr), uint64(funcID))
+	return err
+}
+
+// SetInterrupt sets or clears the interrupt flag. Unlike other Bridge
+// methods it does not call into the module, so it may be used while another
+// goroutine is running code.
+func (b *Bridge) SetInterrupt(interrupt bool) {
+	b.interrupt.Store(interrupt)
+}
+
+// hostInterrupt is the interrupt handler installed by SetupInterruptHandler.
+// It uses a unit of fuel if the bridge is metering it, and reports whether
+// the interrupt flag is set.
+//
+// Compiled WASM code cannot be preempted by the Go scheduler, so a script
+// that runs without calling back into Go would block any stop-the-world
+// pause, such as a garbage collection, until it returns. The goroutine that
+// would interrupt it is then stopped too, and the process hangs. The handler
+// yields to the scheduler to avoid this.
+func (b *Bridge) hostInterrupt(_ context.Context, _ uint32) uint32 {
+	b.useFuel()
+	runtime.Gosched()
+	if b.interrupt.Load() {
+		return 1
+	}
+	return 0
+}
+
 // ============================================================================
 // Memory Info
 // ============================================================================
 
+// memoryUsageFields is the number of int64 fields in JSMemoryUsage.
+const memoryUsageFields = 26
+
+// ComputeMemoryUsage returns the fields of the runtime's JSMemoryUsage, in
+// declaration order: malloc_size, malloc_limit, memory_used_size,
+// malloc_count, memory_used_count, atom_count, atom_size, str_count,
+// str_size, obj_count, obj_size, prop_count, prop_size, shape_count,
+// shape_size, js_func_count, js_func_size, js_func_code_size,
+// js_func_pc2line_count, js_func_pc2line_size, c_func_count, array_count,
+// fast_array_count, fast_array_elements, binary_object_count and
+// binary_object_size.
+func (b *Bridge) ComputeMemoryUsage(ctx context.Context, rtPtr uint32) ([]int64, error) {
+	usagePtr, err := b.Alloc(ctx, memoryUsageFields*8)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Free(ctx, usagePtr)
+
+	if _, err := b.fnJSComputeMemoryUsage.Call(ctx, uint64(rtPtr), uint64(usagePtr)); err != nil {
+		return nil, err
+	}
+	buf, ok := b.memory.Read(usagePtr, memoryUsageFields*8)
+	if !ok {
+		return nil, errors.New("failed to read memory usage")
+	}
+	fields := make([]int64, memoryUsageFields)
+	for i := range fields {
+		fields[i] = int64(binary.LittleEndian.Uint64(buf[i*8:]))
+	}
+	return fields, nil
+}
+
 func (b *Bridge) GetHeapPtr(ctx context.Context) (uint32, error) {
 	results, err := b.fnGetHeapPtr.Call(ctx)
 	if err != nil {
@@ -0,0 +1,86 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+)
+
+// Flags for WriteObject and ReadObject.
+const (
+	WriteObjBytecode = 1 << 0 // JS_WRITE_OBJ_BYTECODE: allow functions and modules
+	ReadObjBytecode  = 1 << 0 // JS_READ_OBJ_BYTECODE: allow functions and modules
+)
+
+// WriteObject serializes a value with JS_WriteObject. A nil slice with a nil
+// error means the engine threw; the exception is left pending.
+func (b *Bridge) WriteObject(ctx context.Context, ctxPtr, valPtr uint32, flags int) ([]byte, error) {
+	sizePtr, err := b.Alloc(ctx, 4)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Free(ctx, sizePtr)
+
+	results, err := b.fnWriteObject.Call(ctx, uint64(ctxPtr), uint64(valPtr), uint64(sizePtr), uint64(flags))
+	if err != nil {
+		return nil, err
+	}
+	bufPtr := uint32(results[0])
+	if bufPtr == 0 {
+		return nil, nil
+	}
+	defer b.fnJSFree.Call(ctx, uint64(ctxPtr), uint64(bufPtr))
+	size, ok := b.memory.ReadUint32Le(sizePtr)
+	if !ok {
+		return nil, errors.New("failed to read bytecode size")
+	}
+	data, ok := b.memory.Read(bufPtr, size)
+	if !ok {
+		return nil, errors.New("failed to read bytecode")
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// ReadObject deserializes data written by WriteObject with JS_ReadObject,
+// returning the value, or the exception, in a new slot. The data is copied
+// to the engine's heap rather than the bridge's arena, since bytecode can be
+// larger than the arena.
+func (b *Bridge) ReadObject(ctx context.Context, ctxPtr uint32, data []byte, flags int) (uint32, error) {
+	results, err := b.fnJSMalloc.Call(ctx, uint64(ctxPtr), uint64(max(len(data), 1)))
+	if err != nil {
+		return 0, err
+	}
+	bufPtr := uint32(results[0])
+	if bufPtr == 0 {
+		return 0, errors.New("failed to allocate bytecode buffer")
+	}
+	defer b.fnJSFree.Call(ctx, uint64(ctxPtr), uint64(bufPtr))
+	if !b.memory.Write(bufPtr, data) {
+		return 0, errors.New("failed to write bytecode")
+	}
+	results, err = b.fnReadObject.Call(ctx, uint64(ctxPtr), uint64(bufPtr), uint64(len(data)), uint64(flags))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(results[0]), nil
+}
+
+// EvalFunction runs compiled code read by ReadObject with JS_EvalFunction,
+// resolving a module's imports first, and returns the result, or the
+// exception, in a new slot. The slot passed in keeps its value.
+func (b *Bridge) EvalFunction(ctx context.Context, ctxPtr, funcPtr uint32) (uint32, error) {
+	results, err := b.fnEvalFunction.Call(ctx, uint64(ctxPtr), uint64(funcPtr))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(results[0]), nil
+}
+
+// IsCompiledCode reports whether the value in a slot is compiled code that
+// EvalFunction can run, a script or a module.
+func (b *Bridge) IsCompiledCode(ctx context.Context, valPtr uint32) (bool, error) {
+	results, err := b.fnIsCompiledCode.Call(ctx, uint64(valPtr))
+	if err != nil {
+		return false, err
+	}
+	return results[0] != 0, nil
+}
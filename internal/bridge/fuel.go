@@ -0,0 +1,48 @@
+package bridge
+
+import "context"
+
+// Fuel is metered by counting the calls QuickJS makes to its interrupt
+// handler (see hostInterrupt). QuickJS calls the handler after a fixed
+// number of operations rather than at intervals of time, so the count
+// depends only on the code run, not on the machine.
+
+// StartFuel starts metering fuel: each call QuickJS makes to its interrupt
+// handler uses one unit, and once none is left the interrupt flag (see
+// SetInterrupt) is set, so running code is interrupted at the next call.
+// Metering continues until StopFuel.
+func (b *Bridge) StartFuel(fuel int64) {
+	b.fuel = fuel
+	b.metering = true
+	if fuel <= 0 {
+		b.SetInterrupt(true)
+	}
+}
+
+// StopFuel stops metering fuel and returns the amount left, which is zero
+// once the fuel ran out. It does not clear the interrupt flag.
+func (b *Bridge) StopFuel() int64 {
+	b.metering = false
+	return max(b.fuel, 0)
+}
+
+// useFuel uses a unit of fuel, if the bridge is metering it. It is called
+// on the goroutine running the module.
+func (b *Bridge) useFuel() {
+	if !b.metering {
+		return
+	}
+	b.fuel--
+	if b.fuel <= 0 {
+		b.SetInterrupt(true)
+	}
+}
+
+// ResetInterruptCounter restarts the count of operations until the next
+// call to the interrupt handler, which otherwise carries over from earlier
+// code, so that fuel is used the same way however the context was used
+// before.
+func (b *Bridge) ResetInterruptCounter(ctx context.Context, ctxPtr uint32) error {
+	_, err := b.fnJSResetInterruptCounter.Call(ctx, uint64(ctxPtr))
+	return err
+}
@@ -0,0 +1,48 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// C code compiled to WASM keeps its stack in linear memory, below the
+// address held in the module's __stack_pointer global. The module is linked
+// with the default 64 KiB stack, placed just above its static data, and
+// nothing stops the stack from growing into that data.
+//
+// The module exports the global. This lets SetStackSize point it at the top
+// of a larger block of memory, and lets calls that trap reset it (see
+// reentrantFunction).
+
+// stackPointerExport is the name the __stack_pointer global is exported as.
+const stackPointerExport = "__stack_pointer"
+
+// LinkedStackSize is the size of the stack the module is linked with.
+const LinkedStackSize = 64 * 1024
+
+// wasmPageSize is the unit WASM memory grows in.
+const wasmPageSize = 64 * 1024
+
+// SetStackSize moves the stack the module runs on to a new block of at least
+// size bytes and updates the runtime's record of the stack top so its stack
+// limit (see SetMaxStackSize) is measured from there. The block is made by
+// growing the module's memory, so it is not part of the heap and does not
+// count towards the memory limit; it is released with the module. It must
+// be called before any code runs, with no call into the module in progress.
+func (b *Bridge) SetStackSize(ctx context.Context, rtPtr, size uint32) error {
+	if b.stackBase != 0 {
+		return errors.New("stack size already set")
+	}
+
+	pages := (size + wasmPageSize - 1) / wasmPageSize
+	prev, ok := b.memory.Grow(pages)
+	if !ok {
+		return fmt.Errorf("failed to allocate a %d byte stack", size)
+	}
+
+	b.stackBase = prev * wasmPageSize
+	b.sp.Set(uint64(b.stackBase + pages*wasmPageSize))
+	_, err := b.fnJSUpdateStackTop.Call(ctx, uint64(rtPtr))
+	return err
+}
@@ -0,0 +1,308 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// interruptRetry is how often a watcher re-sets the interrupt flag until
+// the interrupted call returns, in case a write raced with memory growth.
+const interruptRetry = 10 * time.Millisecond
+
+// The WithContext variants of Eval, Call, CallMethod and New abort running
+// JavaScript when ctx is canceled or its deadline passes, returning an error
+// that wraps both ErrInterrupted and ctx.Err(). The abort covers everything
+// run during the call, including functions called from Go callbacks and
+// pending jobs, and throws an error that JavaScript code cannot catch. The context stays usable afterwards.
+//
+// Cancellation is delivered by a goroutine that watches ctx while the call
+// runs. Running scripts yield to the Go scheduler at QuickJS's periodic
+// interrupt checks, so the watcher gets to run even with GOMAXPROCS=1 or
+// while a garbage collection is waiting for the script.
+
+// ErrInterrupted is wrapped by the error returned when running JavaScript
+// is aborted because the context of a WithContext call, or the runtime's
+// deadline, is done. The error also wraps the context's error, so
+// errors.Is(err, context.DeadlineExceeded) tells a timeout from a
+// cancellation. A call whose context is done before it starts runs nothing
+// and returns the same error.
+var ErrInterrupted = errors.New("interrupted")
+
+// EvalWithContext evaluates JavaScript code like Eval, aborting it if ctx is
+// done before it finishes.
+func (c *Context) EvalWithContext(ctx context.Context, code string) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	return c.runtime.interruptible(ctx, func() (Value, error) {
+		return c.EvalFile(code, "<eval>")
+	})
+}
+
+// CallWithContext calls the value as a function like Call, aborting the
+// call if ctx is done before it returns.
+func (v Value) CallWithContext(ctx context.Context, this Value, args ...Value) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.interruptible(ctx, func() (Value, error) {
+		return v.Call(this, args...)
+	})
+}
+
+// CallTimeout calls the value as a function like Call, aborting the call if
+// it has not returned after d, in which case the error wraps
+// context.DeadlineExceeded. It is shorthand for CallWithContext with a
+// context.WithTimeout.
+func (v Value) CallTimeout(d time.Duration, this Value, args ...Value) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
+	}
+	ctx, cancel := context.WithTimeout(v.ctx.runtime.goCtx, d)
+	defer cancel()
+	return v.CallWithContext(ctx, this, args...)
+}
+
+// CallMethodWithContext calls a method on the value like CallMethod,
+// aborting the call if ctx is done before it returns.
+func (v Value) CallMethodWithContext(ctx context.Context, method string, args ...Value) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.interruptible(ctx, func() (Value, error) {
+		return v.CallMethod(method, args...)
+	})
+}
+
+// NewWithContext calls the value as a constructor like New, aborting the
+// call if ctx is done before it returns.
+func (v Value) NewWithContext(ctx context.Context, args ...Value) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.interruptible(ctx, func() (Value, error) {
+		return v.New(args...)
+	})
+}
+
+// interruptible runs fn, interrupting it if ctx is done first. If ctx is
+// already done, or fn fails because it was interrupted, an error wrapping
+// ErrInterrupted and ctx.Err() is returned in place of the JavaScript
+// error. Caller must hold the mutex.
+func (r *Runtime) interruptible(ctx context.Context, fn func() (Value, error)) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return Value{}, fmt.Errorf("%w: %w", ErrInterrupted, err)
+	}
+	stop := r.watchContext(ctx)
+	val, err := fn()
+	if ctxErr := stop(); ctxErr != nil && isInterrupted(err) {
+		return Value{}, fmt.Errorf("%w: %w", ErrInterrupted, ctxErr)
+	}
+	return val, err
+}
+
+// watchContext starts a goroutine that sets the interrupt flag once ctx is
+// done, and returns a function that stops it and returns ctx.Err(). Calls
+// may nest, as when a Go callback makes an interruptible call of its own, so
+// the flag is cleared only when no remaining watched context is done.
+// Caller must hold the mutex.
+func (r *Runtime) watchContext(ctx context.Context) (stop func() error) {
+	if ctx.Done() == nil {
+		return func() error { return nil }
+	}
+	r.watched = append(r.watched, ctx)
+
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+		case <-quit:
+			return
+		}
+		ticker := time.NewTicker(interruptRetry)
+		defer ticker.Stop()
+		for {
+			r.setInterrupt(true)
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return func() error {
+		close(quit)
+		<-exited
+		r.watched = r.watched[:len(r.watched)-1]
+		r.resetInterrupt()
+		return ctx.Err()
+	}
+}
+
+// resetInterrupt clears the interrupt flag after an interruptible call
+// returns, unless Close is waiting or a remaining watched context is done.
+// Caller must hold the mutex.
+func (r *Runtime) resetInterrupt() {
+	r.setInterrupt(r.closing.Load() || slices.ContainsFunc(r.watched, func(ctx context.Context) bool {
+		return ctx.Err() != nil
+	}))
+}
+
+// setInterrupt sets or clears the interrupt flag. It may be called without
+// holding the runtime mutex.
+func (r *Runtime) setInterrupt(interrupt bool) {
+	r.bridge.SetInterrupt(interrupt)
+}
+
+// isInterrupted reports whether err is the error QuickJS throws when the
+// interrupt handler aborts execution.
+func isInterrupted(err error) bool {
+	var jsErr *JSError
+	return errors.As(err, &jsErr) && jsErr.Name == "InternalError" && jsErr.Message == "interrupted"
+}
+
+// SetDeadline sets a deadline for all JavaScript the runtime runs, as if
+// every call were made with EvalWithContext and a context with that
+// deadline. Once it passes, running code is aborted and Eval, EvalFile,
+// EvalModule, Call, CallMethod, New and ExecutePendingJobs return an error
+// wrapping ErrInterrupted and context.DeadlineExceeded, until the deadline
+// is changed. A zero time removes the deadline. Per-call contexts still
+// apply and may end a call sooner.
+func (r *Runtime) SetDeadline(d time.Time) {
+	r.lock()
+	defer r.unlock()
+	r.deadline = d
+}
+
+// withDeadline runs fn, interrupting it at the deadline set with
+// SetDeadline, if any. Calls made while fn runs share its deadline rather
+// than watching it again. Caller must hold the mutex.
+func (r *Runtime) withDeadline(fn func() (Value, error)) (Value, error) {
+	if r.closed || r.closing.Load() {
+		return Value{}, ErrClosed
+	}
+	if r.deadline.IsZero() || r.inDeadline {
+		return r.closeAborted(fn())
+	}
+	ctx, cancel := context.WithDeadline(r.goCtx, r.deadline)
+	defer cancel()
+
+	r.inDeadline = true
+	defer func() { r.inDeadline = false }()
+	return r.closeAborted(r.interruptible(ctx, fn))
+}
+
+// closeAborted returns ErrClosed in place of err if err is the interrupt
+// thrown because Close is waiting for the mutex. Caller must hold the mutex.
+func (r *Runtime) closeAborted(val Value, err error) (Value, error) {
+	if r.closing.Load() && isInterrupted(err) {
+		return Value{}, ErrClosed
+	}
+	return val, err
+}
+
+// lockInterrupting acquires the mutex like lock. If another goroutine holds
+// it, the JavaScript that goroutine is running is interrupted until the
+// mutex is released, so that calls running when Close is called return
+// ErrClosed rather than block it.
+func (r *Runtime) lockInterrupting() {
+	r.lockMu.Lock()
+	held := r.lockHolder == getGoroutineID()
+	r.lockMu.Unlock()
+	if held {
+		r.lock()
+		return
+	}
+
+	r.closing.Store(true)
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interruptRetry)
+		defer ticker.Stop()
+		for {
+			r.setInterrupt(true)
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	r.lock()
+	close(quit)
+	<-exited
+	r.closing.Store(false)
+	r.setInterrupt(false)
+}
+
+// ErrOutOfFuel is returned by EvalWithFuel when the code uses up its fuel.
+var ErrOutOfFuel = errors.New("out of fuel")
+
+// EvalWithFuel evaluates JavaScript code like Eval, aborting it once it
+// uses up the given amount of fuel, and returns the fuel left. A unit of
+// fuel is one of QuickJS's periodic interrupt checks, made every 10000
+// function calls and loop iterations or so. Unlike a timeout, the fuel a
+// script uses depends only on the script and the engine, so the same script
+// with the same fuel runs out at the same point, or completes, on every run
+// and every machine, whatever the context ran before, which suits
+// reproducible sandboxing.
+//
+// Running out of fuel throws an error JavaScript code cannot catch, and
+// EvalWithFuel returns ErrOutOfFuel with no fuel left. The code is stopped
+// at the interrupt check after the one that used the last unit. Fuel is
+// used by everything run during the call, including Go callbacks and the
+// JavaScript they call, but not by pending jobs left for
+// ExecutePendingJobs. A call made while another EvalWithFuel is running,
+// from a Go callback, is also limited by the fuel the outer call has left,
+// and uses it up.
+func (c *Context) EvalWithFuel(code string, fuel int64) (Value, int64, error) {
+	r := c.runtime
+	r.lock()
+	defer r.unlock()
+
+	code, err := r.transformSource("<eval>", code)
+	if err != nil {
+		return Value{}, fuel, err
+	}
+
+	limit := fuel
+	var outer int64
+	metering := r.metering
+	if metering {
+		outer = r.bridge.StopFuel()
+		limit = min(fuel, outer)
+	} else if err := r.bridge.ResetInterruptCounter(r.goCtx, c.ctxPtr); err != nil {
+		return Value{}, fuel, err
+	}
+	r.metering = true
+	r.bridge.StartFuel(limit)
+	result, err := c.evalFile(code, "<eval>")
+	left := r.bridge.StopFuel()
+	used := limit - left
+	r.metering = metering
+	if metering {
+		r.bridge.StartFuel(outer - used)
+	}
+	if !metering || outer-used > 0 {
+		r.resetInterrupt()
+	}
+
+	if left == 0 && isInterrupted(err) {
+		return Value{}, 0, ErrOutOfFuel
+	}
+	return result, fuel - used, err
+}
@@ -0,0 +1,388 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestEvalWithContext(t *testing.T) {
+	ctx := newTestContext(t)
+
+	timeout, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ctx.EvalWithContext(timeout, "while (true) {}")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("EvalWithContext error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !errors.Is(err, ErrInterrupted) {
+		t.Errorf("EvalWithContext error = %v, want ErrInterrupted", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("interrupt took %v", elapsed)
+	}
+
+	// The interrupt cannot be caught by the script
+	timeout, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = ctx.EvalWithContext(timeout, "try { while (true) {} } catch (e) { 'caught' }")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EvalWithContext error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The context is still usable, and calls that finish in time succeed
+	val, err := ctx.EvalWithContext(context.Background(), "1 + 2")
+	if err != nil {
+		t.Fatalf("EvalWithContext error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 3 {
+		t.Errorf("1 + 2 = %d, want 3", n)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ctx.EvalWithContext(canceled, "globalThis.ran = true"); !errors.Is(err, context.Canceled) || !errors.Is(err, ErrInterrupted) {
+		t.Errorf("EvalWithContext error = %v, want %v and ErrInterrupted", err, context.Canceled)
+	}
+	if ran, _ := ctx.Eval("typeof ran"); ran.String() != "undefined" {
+		t.Errorf("code should not run with an already canceled context")
+	}
+}
+
+func TestCallWithContext(t *testing.T) {
+	ctx := newTestContext(t)
+
+	_, err := ctx.Eval(`
+		function spin() { for (;;) {} }
+		var Spinner = class { constructor() { spin(); } };
+		var obj = { spin };
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	spin, _ := ctx.GetGlobal("spin")
+	spinner, _ := ctx.GetGlobal("Spinner")
+	obj, _ := ctx.GetGlobal("obj")
+
+	calls := map[string]func(context.Context) (Value, error){
+		"Call": func(c context.Context) (Value, error) {
+			return spin.CallWithContext(c, ctx.Undefined())
+		},
+		"CallMethod": func(c context.Context) (Value, error) {
+			return obj.CallMethodWithContext(c, "spin")
+		},
+		"New": func(c context.Context) (Value, error) {
+			return spinner.NewWithContext(c)
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			timeout, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if _, err := call(timeout); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+
+	// A Go callback calling into a looping function is interrupted too
+	run := ctx.Function("run", func(ctx *Context, this Value, args []Value) Value {
+		result, err := spin.Call(ctx.Undefined())
+		if err != nil {
+			return ctx.ThrowError(err.Error())
+		}
+		return result
+	})
+	timeout, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := run.CallWithContext(timeout, ctx.Undefined()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if val, err := ctx.Eval("'still ' + 'usable'"); err != nil || val.String() != "still usable" {
+		t.Errorf("Eval after interrupts = %v, %v", val, err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	ctx := newTestContext(t)
+
+	spin, err := ctx.Eval("(function spin() { for (;;) {} })")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	start := time.Now()
+	if _, err := spin.CallTimeout(50*time.Millisecond, ctx.Undefined()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CallTimeout error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("CallTimeout returned after %v", elapsed)
+	}
+
+	add, _ := ctx.Eval("(a, b) => a + b")
+	val, err := add.CallTimeout(time.Second, ctx.Undefined(), ctx.Int32(2), ctx.Int32(3))
+	if err != nil {
+		t.Fatalf("CallTimeout after a timeout error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 5 {
+		t.Errorf("CallTimeout = %d, want 5", n)
+	}
+}
+
+func TestEvalWithContextSingleProc(t *testing.T) {
+	prev := runtime.GOMAXPROCS(1)
+	defer runtime.GOMAXPROCS(prev)
+	ctx := newTestContext(t)
+
+	timeout, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ctx.EvalWithContext(timeout, "while (true) {}"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("EvalWithContext error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestEvalWithContextDuringGC(t *testing.T) {
+	ctx := newTestContext(t)
+
+	// A collection started while the script spins must not stall the
+	// interrupt
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+
+	for range 5 {
+		timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err := ctx.EvalWithContext(timeout, "while (true) {}")
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("EvalWithContext error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	}
+}
+
+func TestSetDeadline(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	spin, err := ctx.Eval("(function spin() { for (;;) {} })")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	rt.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	start := time.Now()
+	_, err = ctx.Eval("while (true) {}")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Eval error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("interrupt took %v", elapsed)
+	}
+
+	// Once passed, the deadline stops every call
+	if _, err := spin.Call(ctx.Undefined()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Call error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, err := ctx.Eval("1 + 1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Eval error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	rt.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := spin.Call(ctx.Undefined()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Call error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	rt.SetDeadline(time.Time{})
+	val, err := ctx.Eval("1 + 2")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 3 {
+		t.Errorf("1 + 2 = %d, want 3", n)
+	}
+}
+
+func TestEvalCollect(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	if _, err := ctx.Eval("var untouched = 1; var replaced = 1"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	rt.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	globals, err := ctx.EvalCollect("progress = 'started'; replaced = 2; var count = 0; while (true) count++")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("EvalCollect error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	rt.SetDeadline(time.Time{})
+
+	if got := globals["progress"].String(); got != "started" {
+		t.Errorf("progress = %q, want %q", got, "started")
+	}
+	if n, _ := globals["replaced"].Int32(); n != 2 {
+		t.Errorf("replaced = %d, want 2", n)
+	}
+	if n, _ := globals["count"].Int32(); n <= 0 {
+		t.Errorf("count = %d, want some iterations", n)
+	}
+	if _, ok := globals["untouched"]; ok {
+		t.Error("untouched global should not be collected")
+	}
+	if len(globals) != 3 {
+		t.Errorf("EvalCollect collected %d globals, want 3", len(globals))
+	}
+
+	// Code that throws or completes is collected too
+	globals, err = ctx.EvalCollect("thrown = true; throw new Error('boom')")
+	if err == nil {
+		t.Error("EvalCollect should return the thrown error")
+	}
+	if !globals["thrown"].Bool() {
+		t.Error("thrown should be collected")
+	}
+	globals, err = ctx.EvalCollect("function declared() {}")
+	if err != nil {
+		t.Fatalf("EvalCollect error = %v", err)
+	}
+	if !globals["declared"].IsFunction() {
+		t.Error("declared should be collected")
+	}
+}
+
+func TestEvalWithFuel(t *testing.T) {
+	ctx := newTestContext(t)
+
+	// A script that runs out stops at the same point every time
+	spin := func(ctx *Context) int64 {
+		t.Helper()
+		_, left, err := ctx.EvalWithFuel("globalThis.n = 0; for (;;) n++", 5)
+		if !errors.Is(err, ErrOutOfFuel) {
+			t.Fatalf("EvalWithFuel error = %v, want %v", err, ErrOutOfFuel)
+		}
+		if left != 0 {
+			t.Errorf("fuel left = %d, want 0", left)
+		}
+		n, err := ctx.Eval("n")
+		if err != nil {
+			t.Fatalf("Eval error = %v", err)
+		}
+		count, _ := n.Int64()
+		return count
+	}
+	first := spin(ctx)
+	if first == 0 {
+		t.Fatal("script made no progress before running out of fuel")
+	}
+	if _, err := ctx.Eval("for (let i = 0; i < 12345; i++) {}"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	other, err := ctx.runtime.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer other.Close()
+	for _, c := range []*Context{ctx, ctx, other} {
+		if n := spin(c); n != first {
+			t.Errorf("script ran %d iterations, want %d as on the first run", n, first)
+		}
+	}
+
+	// A script that completes uses the same fuel every time
+	code := "let sum = 0; for (let i = 0; i < 100000; i++) sum += i; sum"
+	var want int64 = -1
+	for range 3 {
+		val, left, err := ctx.EvalWithFuel(code, 1000)
+		if err != nil {
+			t.Fatalf("EvalWithFuel error = %v", err)
+		}
+		if f, _ := val.Float64(); f != 4999950000 {
+			t.Errorf("EvalWithFuel = %v, want 4999950000", f)
+		}
+		if left <= 0 || left >= 1000 {
+			t.Errorf("fuel left = %d, want some used and some left", left)
+		}
+		if want >= 0 && left != want {
+			t.Errorf("fuel left = %d, want %d as on the first run", left, want)
+		}
+		want = left
+		code = "{" + code + "}"
+	}
+
+	// The context is usable afterwards, without a limit
+	val, err := ctx.Eval("let x = 0; for (let i = 0; i < 1000000; i++) x++; x")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 1000000 {
+		t.Errorf("Eval = %d, want 1000000", n)
+	}
+}
+
+func TestCloseAbortsRunningEval(t *testing.T) {
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	started := make(chan struct{})
+	ctx.SetGlobal("started", ctx.Function("started", func(ctx *Context, this Value, args []Value) Value {
+		close(started)
+		return ctx.Undefined()
+	}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ctx.EvalWithContext(context.Background(), "started(); while (true) {}")
+		done <- err
+	}()
+	<-started
+
+	closed := make(chan error, 1)
+	go func() { closed <- rt.Close() }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("running eval error = %v, want %v", err, ErrClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("running eval was not aborted by Close")
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	if _, err := ctx.Eval("1"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Eval after Close error = %v, want %v", err, ErrClosed)
+	}
+	if _, err := rt.NewContext(); !errors.Is(err, ErrClosed) {
+		t.Errorf("NewContext after Close error = %v, want %v", err, ErrClosed)
+	}
+	if err := ctx.Close(); err != nil {
+		t.Errorf("Context.Close after Close error = %v", err)
+	}
+	if err := rt.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
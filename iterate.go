@@ -0,0 +1,169 @@
+package quickjs
+
+import "errors"
+
+// ErrUnsettled is returned by Await, and the other methods that wait for a
+// promise, when it is still pending after every queued job has run, so
+// waiting longer could never settle it.
+var ErrUnsettled = errors.New("promise did not settle: no pending jobs left")
+
+// Iterate calls fn with each value produced by an iterable, such as an
+// array, Map, Set, string or generator, like a for...of loop. If fn returns
+// an error, iteration stops, the iterator's return method is called, and
+// the error is returned. Each value is freed when fn returns.
+func (v Value) Iterate(fn func(val Value) error) error {
+	return v.iterate(fn, false)
+}
+
+// IterateAsync is like Iterate, but consumes an async iterable such as an
+// async generator, like a for await...of loop. Each promise returned by the
+// iterator is awaited by running pending jobs until it settles; a rejected
+// promise stops iteration with its reason as a *JSError. Sync iterables are
+// accepted too, with each of their values awaited.
+func (v Value) IterateAsync(fn func(val Value) error) error {
+	return v.iterate(fn, true)
+}
+
+func (v Value) iterate(fn func(val Value) error, async bool) error {
+	if err := v.usable(); err != nil {
+		return err
+	}
+	c := v.ctx
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	name, src := "iterator", iteratorJS
+	if async {
+		name, src = "asyncIterator", asyncIteratorJS
+	}
+	getIterator, err := c.helper(name, src)
+	if err != nil {
+		return err
+	}
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	it, err := getIterator.Call(this, v)
+	if err != nil {
+		return err
+	}
+	defer it.free()
+
+	for {
+		done, val, err := c.iteratorNext(it, async)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		err = fn(val)
+		val.free()
+		if err != nil {
+			c.iteratorReturn(it, async)
+			return err
+		}
+	}
+}
+
+// iteratorNext advances an iterator and returns its result. Caller must hold
+// the mutex.
+func (c *Context) iteratorNext(it Value, async bool) (done bool, val Value, err error) {
+	result, err := it.CallMethod("next")
+	if err != nil {
+		return false, Value{}, err
+	}
+	if async {
+		settled, err := c.await(result)
+		result.free()
+		if err != nil {
+			return false, Value{}, err
+		}
+		result = settled
+	}
+	defer result.free()
+
+	if !result.IsObject() {
+		return false, Value{}, errors.New("iterator result is not an object")
+	}
+	doneVal, err := result.Get("done")
+	if err != nil {
+		return false, Value{}, err
+	}
+	done = doneVal.Bool()
+	doneVal.free()
+	if done {
+		return true, Value{}, nil
+	}
+	val, err = result.Get("value")
+	return false, val, err
+}
+
+// iteratorReturn closes an iterator that was not run to completion, if it
+// has a return method. Errors are ignored, as for an early exit from a
+// for...of loop. Caller must hold the mutex.
+func (c *Context) iteratorReturn(it Value, async bool) {
+	ret, err := it.Get("return")
+	if err != nil {
+		return
+	}
+	defer ret.free()
+	if !ret.IsFunction() {
+		return
+	}
+	result, err := ret.Call(it)
+	if err != nil {
+		return
+	}
+	if async {
+		if settled, err := c.await(result); err == nil {
+			settled.free()
+		}
+	}
+	result.free()
+}
+
+// await waits for v to settle by running pending jobs, and returns its
+// fulfillment value. Values that are not promises are returned as they are,
+// after one turn of the job queue. Caller must hold the mutex.
+func (c *Context) await(v Value) (Value, error) {
+	settle, err := c.helper("settle", settleJS)
+	if err != nil {
+		return Value{}, err
+	}
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	state, err := settle.Call(this, v)
+	if err != nil {
+		return Value{}, err
+	}
+	defer state.free()
+
+	for {
+		settled, err := state.Get("settled")
+		if err != nil {
+			return Value{}, err
+		}
+		done := settled.Bool()
+		settled.free()
+		if done {
+			break
+		}
+		ran, err := c.runtime.executePendingJob()
+		if err != nil {
+			return Value{}, err
+		}
+		if !ran {
+			return Value{}, ErrUnsettled
+		}
+	}
+
+	if state.Has("reason") {
+		reason, err := state.Get("reason")
+		if err != nil {
+			return Value{}, err
+		}
+		defer reason.free()
+		return Value{}, c.newJSError(reason.ptr)
+	}
+	return state.Get("value")
+}
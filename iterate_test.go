@@ -0,0 +1,98 @@
+package quickjs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIterate(t *testing.T) {
+	ctx := newTestContext(t)
+
+	val, err := ctx.Eval("new Set(['a', 'b', 'c'])")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	var got []string
+	if err := val.Iterate(func(v Value) error {
+		got = append(got, v.String())
+		return nil
+	}); err != nil {
+		t.Fatalf("Iterate error = %v", err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Iterate = %v, want %v", got, want)
+	}
+
+	// Stopping early returns the error and closes the generator
+	gen, err := ctx.Eval("var closed = false; (function*() { try { yield 1; yield 2; } finally { closed = true; } })()")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	stop := errors.New("stop")
+	if err := gen.Iterate(func(Value) error { return stop }); err != stop {
+		t.Errorf("Iterate error = %v, want %v", err, stop)
+	}
+	closed, _ := ctx.GetGlobal("closed")
+	if !closed.Bool() {
+		t.Errorf("generator should be closed after an early stop")
+	}
+
+	num := ctx.Int32(1)
+	if err := num.Iterate(func(Value) error { return nil }); err == nil {
+		t.Errorf("Iterate over a number should fail")
+	}
+}
+
+func TestIterateAsync(t *testing.T) {
+	ctx := newTestContext(t)
+
+	gen, err := ctx.Eval(`(async function*() {
+		yield 1;
+		yield await Promise.resolve(2);
+		yield new Promise((resolve) => queueMicrotask(() => resolve(3)));
+	})()`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	var got []int32
+	if err := gen.IterateAsync(func(v Value) error {
+		n, err := v.Int32()
+		got = append(got, n)
+		return err
+	}); err != nil {
+		t.Fatalf("IterateAsync error = %v", err)
+	}
+	if want := []int32{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IterateAsync = %v, want %v", got, want)
+	}
+
+	failing, err := ctx.Eval("(async function*() { yield 1; throw new RangeError('bad'); })()")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	err = failing.IterateAsync(func(Value) error { return nil })
+	var jsErr *JSError
+	if !errors.As(err, &jsErr) || jsErr.Name != "RangeError" {
+		t.Errorf("IterateAsync error = %v, want a RangeError", err)
+	}
+
+	// Sync iterables work too, with their values awaited
+	arr, _ := ctx.Eval("[Promise.resolve('x'), 'y']")
+	var strs []string
+	if err := arr.IterateAsync(func(v Value) error {
+		strs = append(strs, v.String())
+		return nil
+	}); err != nil {
+		t.Fatalf("IterateAsync error = %v", err)
+	}
+	if want := []string{"x", "y"}; !reflect.DeepEqual(strs, want) {
+		t.Errorf("IterateAsync = %v, want %v", strs, want)
+	}
+
+	// A promise nothing will ever resolve is reported instead of hanging
+	never, _ := ctx.Eval("({ [Symbol.asyncIterator]() { return { next: () => new Promise(() => {}) }; } })")
+	if err := never.IterateAsync(func(Value) error { return nil }); err != ErrUnsettled {
+		t.Errorf("IterateAsync error = %v, want %v", err, ErrUnsettled)
+	}
+}
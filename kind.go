@@ -0,0 +1,117 @@
+package quickjs
+
+// Kind classifies a JavaScript value, as reported by KindsOf and Value.Kind.
+// It follows typeof, except that null and arrays have kinds of their own.
+type Kind int
+
+const (
+	KindUndefined Kind = iota
+	KindNull
+	KindBool
+	KindNumber
+	KindBigInt
+	KindString
+	KindSymbol
+	KindObject // any object other than an array or function
+	KindArray
+	KindFunction
+)
+
+var kindNames = [...]string{
+	KindUndefined: "undefined",
+	KindNull:      "null",
+	KindBool:      "boolean",
+	KindNumber:    "number",
+	KindBigInt:    "bigint",
+	KindString:    "string",
+	KindSymbol:    "symbol",
+	KindObject:    "object",
+	KindArray:     "array",
+	KindFunction:  "function",
+}
+
+// String returns the name of the kind: its typeof string, or "null" or
+// "array".
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// Kind returns the kind of the value. To classify several values, such as
+// the arguments of a Go function, KindsOf is faster than calling Kind or the
+// Is methods on each.
+func (v Value) Kind() Kind {
+	return KindsOf([]Value{v})[0]
+}
+
+// KindsOf returns the kind of each value, classifying all the values of a
+// context in a single call into the engine. The zero Value, and values whose
+// kind cannot be determined, for example because their context is closed,
+// report KindUndefined.
+func KindsOf(vals []Value) []Kind {
+	kinds := make([]Kind, len(vals))
+	var ctx *Context
+	var others []int // indexes of values from other contexts than ctx
+	var same []Value
+	for i, v := range vals {
+		switch {
+		case v.ctx == nil:
+		case ctx == nil || v.ctx == ctx:
+			ctx = v.ctx
+			same = append(same, v)
+		default:
+			others = append(others, i)
+		}
+	}
+	if ctx == nil {
+		return kinds
+	}
+
+	codes := ctx.kindCodes(same)
+	for i, j := 0, 0; i < len(vals) && j < len(codes); i++ {
+		if vals[i].ctx == ctx {
+			kinds[i] = Kind(codes[j] - '0')
+			j++
+		}
+	}
+	for _, i := range others {
+		kinds[i] = vals[i].Kind()
+	}
+	return kinds
+}
+
+// kindCodes returns the kinds of vals as a string of digits, one per value,
+// or the empty string on failure. It calls the bridge directly, since the
+// cost of the reentrant locking done by Call and String would outweigh the
+// work.
+func (c *Context) kindCodes(vals []Value) string {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	kinds, err := c.helper("kinds", kindsJS)
+	if err != nil {
+		return ""
+	}
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+
+	argPtrs := make([]uint32, len(vals))
+	for i, v := range vals {
+		argPtrs[i] = v.ptr
+	}
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	resultPtr, err := b.Call(goCtx, c.ctxPtr, kinds.ptr, this.ptr, argPtrs)
+	if err != nil {
+		return ""
+	}
+	result, err := c.checkException(resultPtr)
+	if err != nil {
+		return ""
+	}
+	defer result.freeUnlocked()
+	codes, _ := b.ToString(goCtx, c.ctxPtr, result.ptr)
+	return codes
+}
@@ -0,0 +1,87 @@
+package quickjs
+
+import "testing"
+
+func TestKindsOf(t *testing.T) {
+	ctx := newTestContext(t)
+
+	vals, err := ctx.Eval(`[undefined, null, true, 1.5, 10n, "s", Symbol("x"), {}, [1], () => 1, new Date(), new Proxy([], {})]`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	var args []Value
+	for i := range vals.Len() {
+		v, _ := vals.GetIdx(i)
+		args = append(args, v)
+	}
+	args = append(args, Value{})
+
+	want := []Kind{
+		KindUndefined, KindNull, KindBool, KindNumber, KindBigInt, KindString,
+		KindSymbol, KindObject, KindArray, KindFunction, KindObject, KindArray,
+		KindUndefined,
+	}
+	got := KindsOf(args)
+	if len(got) != len(want) {
+		t.Fatalf("KindsOf returned %d kinds, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("KindsOf()[%d] = %v, want %v", i, got[i], want[i])
+		}
+		if k := args[i].Kind(); k != want[i] {
+			t.Errorf("args[%d].Kind() = %v, want %v", i, k, want[i])
+		}
+	}
+
+	// Values from another context are classified too
+	other, err := ctx.runtime.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer other.Close()
+	mixed := KindsOf([]Value{ctx.Int32(1), other.String("s"), ctx.Null()})
+	if mixed[0] != KindNumber || mixed[1] != KindString || mixed[2] != KindNull {
+		t.Errorf("KindsOf(mixed contexts) = %v", mixed)
+	}
+
+	if s := KindFunction.String(); s != "function" {
+		t.Errorf("KindFunction.String() = %q", s)
+	}
+}
+
+// benchmarkArgs returns the arguments of sumAll(1, ..., 20).
+func benchmarkArgs(ctx *Context) []Value {
+	args := make([]Value, 20)
+	for i := range args {
+		args[i] = ctx.Int32(int32(i + 1))
+	}
+	return args
+}
+
+func BenchmarkKindsOf(b *testing.B) {
+	ctx := newTestContext(b)
+	args := benchmarkArgs(ctx)
+
+	for b.Loop() {
+		for _, k := range KindsOf(args) {
+			if k != KindNumber {
+				b.Fatal("not a number")
+			}
+		}
+	}
+}
+
+// BenchmarkIsNumberEach benchmarks checking the same arguments one by one
+func BenchmarkIsNumberEach(b *testing.B) {
+	ctx := newTestContext(b)
+	args := benchmarkArgs(ctx)
+
+	for b.Loop() {
+		for _, arg := range args {
+			if !arg.IsNumber() {
+				b.Fatal("not a number")
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package quickjs
+
+import "fmt"
+
+// SetModuleLoader sets the function that supplies the source of the modules
+// that modules import, so that
+//
+//	import { helper } from "./lib/util.js";
+//
+// in a module evaluated with EvalModule loads lib/util.js from Go. It is
+// called with the module's name, which is the specifier as written for a
+// bare specifier such as "lodash", and a path resolved against the
+// importing module's name for one starting with "./" or "../", as the
+// engine resolves it: "./lib/util.js" imported by "src/main.js" is
+// "src/lib/util.js". An error returned by the loader fails the import. Pass
+// nil to remove the loader.
+//
+// The engine calls the loader the first time a module is imported, whether
+// by an import declaration or a dynamic import(), so each module is loaded
+// once per context and modules may import each other in a cycle. Sources
+// are passed through the source transform like those given to EvalModule.
+//
+// The loader runs while the runtime is locked; it must not use the runtime
+// itself.
+func (r *Runtime) SetModuleLoader(fn func(name string) (string, error)) error {
+	r.lock()
+	defer r.unlock()
+	r.moduleLoader = fn
+	if fn == nil {
+		return r.bridge.SetModuleLoader(r.goCtx, r.rtPtr, 0)
+	}
+	if r.moduleLoaderID == 0 {
+		r.moduleLoaderID = r.bridge.RegisterGoFunc(r.loadModule)
+	}
+	return r.bridge.SetModuleLoader(r.goCtx, r.rtPtr, r.moduleLoaderID)
+}
+
+// loadModule is the callback through which the engine calls the module
+// loader, with the name of the module to load. It returns the module's
+// source, or throws the loader's error. It runs while the mutex is held.
+func (r *Runtime) loadModule(ctxPtr uint32, args []uint32) uint32 {
+	var c *Context
+	for ctx := range r.contexts {
+		if ctx.ctxPtr == ctxPtr {
+			c = ctx
+			break
+		}
+	}
+	if c == nil || len(args) != 1 {
+		exc, _ := r.bridge.ThrowError(r.goCtx, ctxPtr, "module loader called without a context")
+		return exc
+	}
+
+	nameVal := c.value(args[0])
+	name := nameVal.String()
+	nameVal.freeUnlocked()
+
+	src, err := r.moduleLoader(name)
+	if err != nil {
+		err = fmt.Errorf("failed to load module %q: %w", name, err)
+	} else {
+		src, err = r.transformSource(name, src)
+	}
+	var result Value
+	if err != nil {
+		result = c.throwGoError(err)
+	} else {
+		result = c.String(src)
+	}
+	// The engine takes over the result's reference
+	r.handOver(result)
+	return result.ptr
+}
@@ -0,0 +1,298 @@
+package quickjs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// nativeModuleGlobal is the global through which a native module's exports
+// are handed to the module that re-exports them. It is deleted as soon as
+// the module has read it.
+const nativeModuleGlobal = "__quickjsNativeModule"
+
+// moduleCompletionGlobal is the global EvalModuleValue stores a module's
+// completion value in. It is deleted once the value has been read.
+const moduleCompletionGlobal = "__quickjsModuleCompletion"
+
+// nativeModule is an ES module whose exports are values provided by Go.
+type nativeModule struct {
+	name    string
+	names   []string // sorted export names
+	exports map[string]Value
+}
+
+// AddNativeModule registers an ES module whose exports are the given values,
+// typically functions created with Context.Function, so that scripts can
+// import them:
+//
+//	add := ctx.Function("add", addFunc)
+//	rt.AddNativeModule("host:math", map[string]quickjs.Value{"add": add})
+//	ctx.EvalModule(`import { add } from "host:math"; add(1, 2)`, "main.js")
+//
+// The module is available in every context of the runtime, including ones
+// created later. An export named "default" is the module's default export.
+// The values are kept alive by the runtime, and registering the same module
+// name twice is an error.
+func (r *Runtime) AddNativeModule(name string, exports map[string]Value) error {
+	r.lock()
+	defer r.unlock()
+
+	if name == "" {
+		return errors.New("module name must not be empty")
+	}
+	if slices.ContainsFunc(r.nativeModules, func(m nativeModule) bool { return m.name == name }) {
+		return fmt.Errorf("module %q already registered", name)
+	}
+
+	m := nativeModule{name: name, exports: make(map[string]Value, len(exports))}
+	for exportName, val := range exports {
+		if !isIdentifier(exportName) {
+			return fmt.Errorf("invalid export name %q", exportName)
+		}
+		if val.ctx == nil || val.ctx.runtime != r {
+			return fmt.Errorf("export %q does not belong to this runtime", exportName)
+		}
+		m.names = append(m.names, exportName)
+	}
+	slices.Sort(m.names)
+	for _, exportName := range m.names {
+		val := exports[exportName]
+		ptr, err := r.bridge.DupValue(r.goCtx, val.ctx.ctxPtr, val.ptr)
+		if err != nil {
+			return err
+		}
+		m.exports[exportName] = Value{ctx: val.ctx, ptr: ptr}
+	}
+
+	for c := range r.contexts {
+		if err := c.installNativeModule(m); err != nil {
+			return fmt.Errorf("failed to install module %q: %w", name, err)
+		}
+	}
+	r.nativeModules = append(r.nativeModules, m)
+	return nil
+}
+
+// installNativeModule evaluates a module that re-exports m's values, leaving
+// it in the context's module registry under m's name so later imports
+// resolve to it. Caller must hold the mutex.
+func (c *Context) installNativeModule(m nativeModule) error {
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+
+	objPtr, err := b.NewObject(goCtx, c.ctxPtr)
+	if err != nil {
+		return err
+	}
+	defer b.FreeValue(goCtx, c.ctxPtr, objPtr)
+	for _, name := range m.names {
+		if err := b.SetProperty(goCtx, c.ctxPtr, objPtr, name, m.exports[name].ptr); err != nil {
+			return err
+		}
+	}
+	if err := c.SetGlobal(nativeModuleGlobal, Value{ctx: c, ptr: objPtr}); err != nil {
+		return err
+	}
+
+	var src strings.Builder
+	fmt.Fprintf(&src, "const exports = globalThis.%s;\ndelete globalThis.%[1]s;\n", nativeModuleGlobal)
+	specifiers := make([]string, len(m.names))
+	for i, name := range m.names {
+		fmt.Fprintf(&src, "const e%d = exports.%s;\n", i, name)
+		specifiers[i] = fmt.Sprintf("e%d as %s", i, name)
+	}
+	fmt.Fprintf(&src, "export { %s };\n", strings.Join(specifiers, ", "))
+
+	result, err := c.evalModule(src.String(), m.name)
+	if err != nil {
+		return err
+	}
+	result.free()
+	return nil
+}
+
+// SetSourceTransform sets a function that rewrites every script and module
+// source passed to Eval, EvalFile and EvalModule before it is compiled, for
+// example to strip TypeScript type annotations. It is called with the
+// filename and the source, and returns the source to compile. If it returns
+// an error, nothing is evaluated and the error is returned. Pass nil to
+// remove the transform.
+//
+// The transform runs while the runtime is locked; it must not use the
+// runtime itself.
+func (r *Runtime) SetSourceTransform(fn func(name, source string) (string, error)) {
+	r.lock()
+	defer r.unlock()
+	r.sourceTransform = fn
+}
+
+// transformSource applies the source transform, if any, and counts the
+// evaluation for Stats. It is called for every source a caller passes to an
+// Eval method. Caller must hold the mutex.
+func (r *Runtime) transformSource(name, source string) (string, error) {
+	r.evalCount++
+	return r.applySourceTransform(name, source)
+}
+
+// applySourceTransform applies the source transform, if any. Caller must
+// hold the mutex.
+func (r *Runtime) applySourceTransform(name, source string) (string, error) {
+	if r.sourceTransform == nil {
+		return source, nil
+	}
+	out, err := r.sourceTransform(name, source)
+	if err != nil {
+		return "", fmt.Errorf("source transform failed for %s: %w", name, err)
+	}
+	return out, nil
+}
+
+// EvalTransformed evaluates a script like EvalFile, but requires a source
+// transform to be set with SetSourceTransform and returns an error if there
+// is none. Use it for code that is not plain JavaScript, such as TypeScript
+// or JSX, so it is never run untransformed. The quickjs package does not
+// include a transpiler; the transform must supply one, for example by
+// calling esbuild's Go API.
+func (c *Context) EvalTransformed(code, filename string) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	if c.runtime.sourceTransform == nil {
+		return Value{}, fmt.Errorf("no source transform set to evaluate %s", filename)
+	}
+	code, err := c.runtime.transformSource(filename, code)
+	if err != nil {
+		return Value{}, err
+	}
+	return c.evalFile(code, filename)
+}
+
+// EvalModuleValue evaluates code as an ES module like EvalModule, but
+// returns the value of its last statement, as a REPL would, instead of the
+// module's evaluation promise. Top-level await is waited for by running
+// pending jobs, and a rejection is returned as a *JSError. If the last
+// statement is not an expression statement, the result is undefined.
+//
+// Modules have no completion value of their own, so the last expression
+// statement is rewritten to store its value before the module is compiled.
+func (c *Context) EvalModuleValue(code, filename string) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	code, err := c.runtime.transformSource(filename, code)
+	if err != nil {
+		return Value{}, err
+	}
+	result, err := c.evalModule(c.captureCompletion(code), filename)
+	if err != nil {
+		return Value{}, err
+	}
+	defer result.free()
+
+	settled, err := c.await(result)
+	completion, takeErr := c.takeGlobal(moduleCompletionGlobal)
+	if err != nil {
+		completion.free()
+		return Value{}, err
+	}
+	settled.free()
+	return completion, takeErr
+}
+
+// statementKeywords are the keywords that start a statement that is not an
+// expression, so a last statement starting with one is left alone.
+var statementKeywords = []string{
+	"break", "class", "const", "continue", "debugger", "do", "export", "for",
+	"function", "if", "import", "let", "return", "switch", "throw", "try",
+	"var", "while", "with",
+}
+
+// captureCompletion rewrites a module so that it stores the value of its
+// last statement in moduleCompletionGlobal, if that statement is an
+// expression. The last statement is found in one pass over the module's
+// top-level tokens: it starts after the last semicolon or closing brace,
+// or at the last line break where automatic semicolon insertion ends a
+// statement, that is, between a token that can end an expression and one
+// that cannot continue it. If that statement does not compile as an
+// expression, code is returned unchanged. Caller must hold the mutex.
+func (c *Context) captureCompletion(code string) string {
+	body := strings.TrimRightFunc(code, func(r rune) bool {
+		return r == ';' || unicode.IsSpace(r)
+	})
+	start := -1
+	var first, prev jsToken
+	scanJS(body, func(t jsToken) {
+		if start < 0 || t.depth == 0 && prev.depth == 0 && endsStatement(body, prev, t) {
+			start, first = t.start, t
+		}
+		prev = t
+	})
+	if start < 0 || slices.Contains(statementKeywords, first.word) {
+		return code
+	}
+	tail := body[start:]
+	// An async arrow function allows top-level await in the expression.
+	// Compiling the tail inside brackets as well as parentheses rejects
+	// tails that only compile by closing the wrapper early.
+	if c.compiles("(async () => (\n"+tail+"\n))") != nil || c.compiles("(async () => [\n"+tail+"\n])") != nil {
+		return code
+	}
+	// The assignment starts on a new line after the rest of the module,
+	// which it cannot continue, so the statements before it are unchanged
+	return fmt.Sprintf("%s\nglobalThis.%s = (\n%s\n);\n", body[:start], moduleCompletionGlobal, tail)
+}
+
+// endsStatement reports whether a top-level statement ends between the
+// tokens prev and next: after a semicolon, or after a closing brace or at a
+// line break when prev can end an expression and next cannot continue it.
+// "++" and "--" start a new statement after a line break, as the operand
+// of a postfix operator must be on the same line.
+func endsStatement(code string, prev, next jsToken) bool {
+	p, n := code[prev.start], code[next.start]
+	switch {
+	case p == ';':
+		return true
+	case p == '}' && prev.end == prev.start+1:
+	case !next.newline:
+		return false
+	case p == '/' && prev.end > prev.start+1: // a regular expression
+	case regexAllowed(code[prev.end-1], prev.word):
+		return false
+	}
+	if next.newline && (strings.HasPrefix(code[next.start:], "++") || strings.HasPrefix(code[next.start:], "--")) {
+		return true
+	}
+	return !strings.ContainsRune(".,([+-*/%&|^<>=?:`", rune(n)) && next.word != "in" && next.word != "instanceof"
+}
+
+// isIdentifierPart reports whether s[i] continues an identifier.
+func isIdentifierPart(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	b := s[i]
+	return b == '_' || b == '$' || b >= 0x80 ||
+		'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+// takeGlobal returns the named global and deletes it. Caller must hold the
+// mutex.
+func (c *Context) takeGlobal(name string) (Value, error) {
+	global, err := c.Global()
+	if err != nil {
+		return Value{}, err
+	}
+	defer global.free()
+	val, err := global.Get(name)
+	if err != nil {
+		return Value{}, err
+	}
+	if err := global.Delete(name); err != nil {
+		val.free()
+		return Value{}, err
+	}
+	return val, nil
+}
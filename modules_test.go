@@ -0,0 +1,346 @@
+package quickjs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAddNativeModule(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	add := ctx.Function("add", func(ctx *Context, this Value, args []Value) Value {
+		a, _ := args[0].Int32()
+		b, _ := args[1].Int32()
+		return ctx.Int32(a + b)
+	})
+	err := rt.AddNativeModule("host:math", map[string]Value{
+		"add":     add,
+		"default": ctx.String("math"),
+	})
+	if err != nil {
+		t.Fatalf("AddNativeModule error = %v", err)
+	}
+
+	_, err = ctx.EvalModule(`
+		import name, { add } from "host:math";
+		globalThis.result = name + ":" + add(2, 3);
+	`, "main.js")
+	if err != nil {
+		t.Fatalf("EvalModule error = %v", err)
+	}
+	result, _ := ctx.GetGlobal("result")
+	if result.String() != "math:5" {
+		t.Errorf("result = %q, want %q", result.String(), "math:5")
+	}
+	if hidden, _ := ctx.Eval("typeof " + nativeModuleGlobal); hidden.String() != "undefined" {
+		t.Errorf("%s should not remain on the global object", nativeModuleGlobal)
+	}
+
+	// Contexts created later can import the module too
+	other, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer other.Close()
+	if _, err := other.EvalModule(`import { add } from "host:math"; globalThis.sum = add(1, 1);`, "other.js"); err != nil {
+		t.Fatalf("EvalModule error = %v", err)
+	}
+	sum, _ := other.GetGlobal("sum")
+	if n, _ := sum.Int32(); n != 2 {
+		t.Errorf("sum = %d, want 2", n)
+	}
+
+	if err := rt.AddNativeModule("host:math", nil); err == nil {
+		t.Errorf("registering a module twice should fail")
+	}
+	if err := rt.AddNativeModule("host:bad", map[string]Value{"not valid": add}); err == nil {
+		t.Errorf("an invalid export name should fail")
+	}
+}
+
+func TestSetSourceTransform(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	literal := regexp.MustCompile(`"[^"]*"`)
+	var names []string
+	rt.SetSourceTransform(func(name, source string) (string, error) {
+		names = append(names, name)
+		return literal.ReplaceAllStringFunc(source, strings.ToUpper), nil
+	})
+
+	val, err := ctx.Eval(`"hello" + ' world'`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if val.String() != "HELLO world" {
+		t.Errorf("Eval = %q, want %q", val.String(), "HELLO world")
+	}
+	if _, err := ctx.EvalModule(`globalThis.greeting = "hi";`, "greet.js"); err != nil {
+		t.Fatalf("EvalModule error = %v", err)
+	}
+	greeting, _ := ctx.GetGlobal("greeting")
+	if greeting.String() != "HI" {
+		t.Errorf("greeting = %q, want %q", greeting.String(), "HI")
+	}
+	if want := []string{"<eval>", "greet.js"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("transform called with %v, want %v", names, want)
+	}
+
+	errTransform := errors.New("unsupported syntax")
+	rt.SetSourceTransform(func(name, source string) (string, error) {
+		return "", errTransform
+	})
+	_, err = ctx.Eval("globalThis.ran = true")
+	if !errors.Is(err, errTransform) {
+		t.Errorf("Eval error = %v, want %v", err, errTransform)
+	}
+
+	rt.SetSourceTransform(nil)
+	if ran, _ := ctx.Eval("typeof ran"); ran.String() != "undefined" {
+		t.Errorf("source should not run when the transform fails")
+	}
+	if val, err := ctx.Eval(`"plain"`); err != nil || val.String() != "plain" {
+		t.Errorf("Eval without transform = %v, %v", val, err)
+	}
+}
+
+func TestSetModuleLoader(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	files := map[string]string{
+		"src/lib/math.js": `
+			// import "./not-imported.js"
+			const s = "import 'neither.js'";
+			export * from "../../shared/base.js";
+			export const double = (n) => n * 2;
+		`,
+		"shared/base.js": `globalThis.loads = (globalThis.loads || 0) + 1; export const base = 10;`,
+		"lodash":         `export default { add: (a, b) => a + b };`,
+	}
+	var loaded []string
+	err := rt.SetModuleLoader(func(name string) (string, error) {
+		loaded = append(loaded, name)
+		src, ok := files[name]
+		if !ok {
+			return "", fmt.Errorf("no such file")
+		}
+		return src, nil
+	})
+	if err != nil {
+		t.Fatalf("SetModuleLoader error = %v", err)
+	}
+
+	_, err = ctx.EvalModule(`
+		import { double, base } from "./lib/math.js";
+		import _ from 'lodash';
+		import "../shared/base.js";
+		globalThis.result = _.add(double(base), 1);
+	`, "src/main.js")
+	if err != nil {
+		t.Fatalf("EvalModule error = %v", err)
+	}
+	if got, _ := ctx.Eval("result"); got.String() != "21" {
+		t.Errorf("result = %s, want 21", got.String())
+	}
+	if got, _ := ctx.Eval("loads"); got.String() != "1" {
+		t.Errorf("base.js evaluated %s times, want 1", got.String())
+	}
+	if want := []string{"src/lib/math.js", "shared/base.js", "lodash"}; !reflect.DeepEqual(loaded, want) {
+		t.Errorf("loader called with %v, want %v", loaded, want)
+	}
+
+	// Loaded modules are not loaded again by later modules
+	loaded = nil
+	if _, err := ctx.EvalModule(`import { base } from "shared/base.js";`, "other.js"); err != nil {
+		t.Fatalf("EvalModule error = %v", err)
+	}
+	if len(loaded) != 0 {
+		t.Errorf("loader called with %v for a loaded module", loaded)
+	}
+
+	_, err = ctx.EvalModule(`import { x } from "./missing.js";`, "main.js")
+	if err == nil || !strings.Contains(err.Error(), `"missing.js"`) || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("EvalModule error = %v, want the loader's error", err)
+	}
+
+	if err := rt.SetModuleLoader(nil); err != nil {
+		t.Fatalf("SetModuleLoader(nil) error = %v", err)
+	}
+	if _, err := ctx.EvalModule(`import "./unknown.js";`, "main.js"); err == nil {
+		t.Error("EvalModule should fail to import without a loader")
+	}
+}
+
+func TestModuleLoaderDynamicImport(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	var loaded []string
+	err := rt.SetModuleLoader(func(name string) (string, error) {
+		loaded = append(loaded, name)
+		if name != "lib/lazy.js" {
+			return "", fmt.Errorf("no such file")
+		}
+		return `export const value = 42;`, nil
+	})
+	if err != nil {
+		t.Fatalf("SetModuleLoader error = %v", err)
+	}
+
+	_, err = ctx.EvalModule(`
+		import("./lazy.js").then((m) => { globalThis.lazy = m.value; });
+		import("./missing.js").catch((e) => { globalThis.failure = e.message; });
+	`, "lib/main.js")
+	if err != nil {
+		t.Fatalf("EvalModule error = %v", err)
+	}
+	if len(loaded) != 0 {
+		t.Errorf("loader called with %v before the jobs ran", loaded)
+	}
+	if _, err := rt.ExecutePendingJobs(); err != nil {
+		t.Fatalf("ExecutePendingJobs error = %v", err)
+	}
+
+	if got, _ := ctx.Eval("lazy"); got.String() != "42" {
+		t.Errorf("lazy = %s, want 42", got.String())
+	}
+	failure, _ := ctx.Eval("failure")
+	if !strings.Contains(failure.String(), `"lib/missing.js"`) || !strings.Contains(failure.String(), "no such file") {
+		t.Errorf("failed import rejected with %q, want the loader's error", failure.String())
+	}
+	if want := []string{"lib/lazy.js", "lib/missing.js"}; !reflect.DeepEqual(loaded, want) {
+		t.Errorf("loader called with %v, want %v", loaded, want)
+	}
+}
+
+func TestModuleLoaderCycle(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	files := map[string]string{
+		"even.js": `
+			import { isOdd } from "./odd.js";
+			export const isEven = (n) => n === 0 || isOdd(n - 1);
+		`,
+		"odd.js": `
+			import { isEven } from "./even.js";
+			export const isOdd = (n) => n !== 0 && isEven(n - 1);
+		`,
+	}
+	var loaded []string
+	err := rt.SetModuleLoader(func(name string) (string, error) {
+		loaded = append(loaded, name)
+		return files[name], nil
+	})
+	if err != nil {
+		t.Fatalf("SetModuleLoader error = %v", err)
+	}
+
+	_, err = ctx.EvalModule(`
+		import { isEven } from "./even.js";
+		globalThis.result = [isEven(10), isEven(7)].join();
+	`, "main.js")
+	if err != nil {
+		t.Fatalf("EvalModule error = %v", err)
+	}
+	if got, _ := ctx.Eval("result"); got.String() != "true,false" {
+		t.Errorf("result = %s, want true,false", got.String())
+	}
+	if want := []string{"even.js", "odd.js"}; !reflect.DeepEqual(loaded, want) {
+		t.Errorf("loader called with %v, want %v", loaded, want)
+	}
+}
+
+func TestEvalTransformed(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	if _, err := ctx.EvalTransformed("1 + 1", "app.ts"); err == nil {
+		t.Errorf("EvalTransformed without a transform should fail")
+	}
+
+	// A stand-in for a TypeScript transpiler that strips one annotation
+	var names []string
+	rt.SetSourceTransform(func(name, source string) (string, error) {
+		names = append(names, name)
+		return strings.ReplaceAll(source, ": number", ""), nil
+	})
+
+	val, err := ctx.EvalTransformed("function double(n: number) { return n * 2 } double(21)", "app.ts")
+	if err != nil {
+		t.Fatalf("EvalTransformed error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 42 {
+		t.Errorf("EvalTransformed = %d, want 42", n)
+	}
+	if want := []string{"app.ts"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("transform called with %v, want %v", names, want)
+	}
+}
+
+func TestEvalModuleValue(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"const x = 40;\nx + 2", "42"},
+		{"const x = 40;\nx + 2;\n", "42"},
+		{"const words = ['a', 'b']\nwords\n  .map(w => w.toUpperCase())\n  .join('-')", "A-B"},
+		{"let n = 1\nn\n++n", "2"},
+		{"const v = await Promise.resolve(7); v * 6 // answer", "42"},
+		{"function f() { return 'called' }\nf()", "called"},
+		{"export const answer = 42;\nanswer", "42"},
+		{"const a = 1;", "undefined"},
+		{"if (true) { 1 }", "undefined"},
+		{"let i = 0\ni\n--i", "-1"},
+		{"const f = () => {\n  return 3\n}\nf() // done", "3"},
+		{"let x = 1\nx = x ? {\n  v: 5\n} : 2\nx.v", "5"},
+		// A long module is scanned once, not compiled once per line
+		{strings.Repeat("globalThis.n = (globalThis.n || 0) + 1\n", 10000) + "n", "10000"},
+	}
+	for i, tt := range tests {
+		val, err := ctx.EvalModuleValue(tt.code, fmt.Sprintf("value%d.js", i))
+		if err != nil {
+			t.Fatalf("EvalModuleValue(%q) error = %v", tt.code, err)
+		}
+		if got := val.String(); got != tt.want {
+			t.Errorf("EvalModuleValue(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+		val.free()
+	}
+
+	// Imports work, and the global used to pass the value is removed
+	rt := ctx.runtime
+	if err := rt.AddNativeModule("host:num", map[string]Value{"six": ctx.Int32(6)}); err != nil {
+		t.Fatalf("AddNativeModule error = %v", err)
+	}
+	val, err := ctx.EvalModuleValue(`import { six } from "host:num"; six * 7`, "imports.js")
+	if err != nil {
+		t.Fatalf("EvalModuleValue with import error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 42 {
+		t.Errorf("EvalModuleValue with import = %d, want 42", n)
+	}
+	if leaked, _ := ctx.Eval("typeof " + moduleCompletionGlobal); leaked.String() != "undefined" {
+		t.Errorf("completion global left behind")
+	}
+
+	if _, err := ctx.EvalModuleValue("throw new Error('boom'); 1", "throws.js"); err == nil {
+		t.Error("EvalModuleValue should return the thrown error")
+	}
+	if _, err := ctx.EvalModuleValue("await Promise.reject(new Error('no'))", "rejects.js"); err == nil {
+		t.Error("EvalModuleValue should return the rejection")
+	}
+	if _, err := ctx.EvalModuleValue("1 +", "syntax.js"); err == nil {
+		t.Error("EvalModuleValue should return syntax errors")
+	}
+}
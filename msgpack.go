@@ -0,0 +1,233 @@
+package quickjs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// errMsgpackCyclic is returned when encoding an object graph that contains a
+// cycle.
+var errMsgpackCyclic = errors.New("cannot encode cyclic object as MessagePack")
+
+// MarshalMsgpack encodes the value as MessagePack. null and undefined become
+// nil, booleans and strings map directly, numbers become integers when they
+// are integral and floats otherwise, BigInts become integers, ArrayBuffers
+// become bin, arrays become arrays, and other objects become maps of their
+// own enumerable string keys, as Object.keys lists them. Functions and
+// symbols cannot be encoded, nor can BigInts outside the int64 range or
+// objects that contain themselves.
+func (v Value) MarshalMsgpack() ([]byte, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	e := msgpackEncoder{ctx: v.ctx}
+	defer e.arrayBuffer.free()
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// msgpackEncoder implements MarshalMsgpack. Like decoder, it tracks the
+// objects on the path from the root to detect cycles.
+type msgpackEncoder struct {
+	ctx         *Context
+	buf         []byte
+	ancestors   []Value
+	arrayBuffer Value // the ArrayBuffer constructor, once needed
+}
+
+func (e *msgpackEncoder) encode(v Value) error {
+	switch {
+	case v.IsUndefined(), v.IsNull():
+		e.buf = append(e.buf, 0xc0)
+	case v.IsBool():
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case v.IsNumber():
+		if isInt, i, f := v.Number(); isInt && !(f == 0 && math.Signbit(f)) {
+			e.int(i)
+		} else {
+			e.buf = append(e.buf, 0xcb)
+			e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(f))
+		}
+	case v.IsBigInt():
+		n, err := v.BigIntBig()
+		if err != nil {
+			return err
+		}
+		if !n.IsInt64() {
+			return fmt.Errorf("BigInt %s does not fit in a MessagePack integer", n)
+		}
+		e.int(n.Int64())
+	case v.IsString():
+		e.str(v.String())
+	case v.IsFunction(), v.IsSymbol():
+		return fmt.Errorf("cannot encode JavaScript %s as MessagePack", v.Typeof())
+	case v.IsArray():
+		return e.array(v)
+	case v.IsObject():
+		isBuffer, err := e.isArrayBuffer(v)
+		if err != nil {
+			return err
+		}
+		if isBuffer {
+			data, err := e.ctx.runtime.bridge.GetArrayBuffer(e.ctx.runtime.goCtx, e.ctx.ctxPtr, v.ptr)
+			if err != nil {
+				return err
+			}
+			e.bin(data)
+			return nil
+		}
+		return e.object(v)
+	default:
+		return fmt.Errorf("cannot encode JavaScript %s as MessagePack", v.Typeof())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) enter(v Value) error {
+	if isAncestor(e.ancestors, v) {
+		return errMsgpackCyclic
+	}
+	e.ancestors = append(e.ancestors, v)
+	return nil
+}
+
+func (e *msgpackEncoder) leave() {
+	e.ancestors = e.ancestors[:len(e.ancestors)-1]
+}
+
+func (e *msgpackEncoder) array(v Value) error {
+	if err := e.enter(v); err != nil {
+		return err
+	}
+	defer e.leave()
+
+	n := v.Len()
+	e.header(n, 0x90, 15, 0xdc, 0xdd)
+	for i := range n {
+		elem, err := v.GetIdx(i)
+		if err != nil {
+			return err
+		}
+		err = e.encode(elem)
+		elem.free()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) object(v Value) error {
+	if err := e.enter(v); err != nil {
+		return err
+	}
+	defer e.leave()
+
+	keys, err := v.ownKeys()
+	if err != nil {
+		return err
+	}
+	e.header(len(keys), 0x80, 15, 0xde, 0xdf)
+	for _, key := range keys {
+		e.str(key)
+		prop, err := v.Get(key)
+		if err != nil {
+			return err
+		}
+		err = e.encode(prop)
+		prop.free()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isArrayBuffer reports whether v is an ArrayBuffer.
+func (e *msgpackEncoder) isArrayBuffer(v Value) (bool, error) {
+	if e.arrayBuffer.ctx == nil {
+		ctor, err := e.ctx.GetGlobal("ArrayBuffer")
+		if err != nil {
+			return false, err
+		}
+		e.arrayBuffer = ctor
+	}
+	return v.Instanceof(e.arrayBuffer), nil
+}
+
+// int appends i in the smallest integer format that holds it.
+func (e *msgpackEncoder) int(i int64) {
+	switch {
+	case i >= 0 && i <= 127, i < 0 && i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(i))
+	case i >= 0:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(i))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(i))
+	case i >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(i))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) str(s string) {
+	if len(s) <= 31 {
+		e.buf = append(e.buf, 0xa0|byte(len(s)))
+	} else if len(s) <= math.MaxUint8 {
+		e.buf = append(e.buf, 0xd9, byte(len(s)))
+	} else {
+		e.header(len(s), 0, -1, 0xda, 0xdb)
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) bin(data []byte) {
+	if len(data) <= math.MaxUint8 {
+		e.buf = append(e.buf, 0xc4, byte(len(data)))
+	} else {
+		e.header(len(data), 0, -1, 0xc5, 0xc6)
+	}
+	e.buf = append(e.buf, data...)
+}
+
+// header appends a length prefix: fix|n if n is at most fixMax, else a 16-
+// or 32-bit length after the given marker byte. A fixMax of -1 means the
+// type has no fix form.
+func (e *msgpackEncoder) header(n int, fix byte, fixMax int, marker16, marker32 byte) {
+	switch {
+	case n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, marker16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, marker32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
@@ -0,0 +1,240 @@
+package quickjs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// decodeMsgpack decodes the MessagePack produced by MarshalMsgpack into the
+// types encoding/json uses for an any: integers and floats both decode as
+// float64. bin decodes as []byte.
+func decodeMsgpack(data []byte) (any, error) {
+	r := bytes.NewReader(data)
+	v, err := decodeMsgpackValue(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes", r.Len())
+	}
+	return v, nil
+}
+
+func decodeMsgpackValue(r *bytes.Reader) (any, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	readUint := func(size int) (uint64, error) {
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, err
+		}
+		var n uint64
+		for _, c := range buf {
+			n = n<<8 | uint64(c)
+		}
+		return n, nil
+	}
+	sized := func(n uint64, err error) ([]byte, error) {
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		_, err = io.ReadFull(r, buf)
+		return buf, err
+	}
+	seq := func(n uint64, m bool) (any, error) {
+		if !m {
+			arr := []any{}
+			for range n {
+				v, err := decodeMsgpackValue(r)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			return arr, nil
+		}
+		obj := map[string]any{}
+		for range n {
+			k, err := decodeMsgpackValue(r)
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeMsgpackValue(r)
+			if err != nil {
+				return nil, err
+			}
+			obj[k.(string)] = v
+		}
+		return obj, nil
+	}
+
+	switch {
+	case b <= 0x7f:
+		return float64(b), nil
+	case b >= 0xe0:
+		return float64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		s, err := sized(uint64(b&0x1f), nil)
+		return string(s), err
+	case b&0xf0 == 0x90:
+		return seq(uint64(b&0x0f), false)
+	case b&0xf0 == 0x80:
+		return seq(uint64(b&0x0f), true)
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readUint(1 << (b - 0xcc))
+		return float64(n), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := readUint(size)
+		shift := 64 - 8*size
+		return float64(int64(n<<shift) >> shift), err
+	case 0xcb:
+		n, err := readUint(8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb:
+		s, err := sized(readUint(1 << (b - 0xd9)))
+		return string(s), err
+	case 0xc4, 0xc5, 0xc6:
+		return sized(readUint(1 << (b - 0xc4)))
+	case 0xdc, 0xdd:
+		n, err := readUint(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return seq(n, false)
+	case 0xde, 0xdf:
+		n, err := readUint(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return seq(n, true)
+	}
+	return nil, fmt.Errorf("unexpected MessagePack byte 0x%02x", b)
+}
+
+func TestMarshalMsgpack(t *testing.T) {
+	ctx := newTestContext(t)
+
+	long := `"` + string(bytes.Repeat([]byte("x"), 300)) + `"`
+	tests := []string{
+		"null",
+		"true",
+		"false",
+		"0",
+		"-1",
+		"-33",
+		"200",
+		"-200",
+		"70000",
+		"-70000",
+		"5000000000",
+		"-5000000000",
+		"1.5",
+		"-0.25",
+		"''",
+		"'hello'",
+		"'café'",
+		long,
+		"[]",
+		"[1, 'two', [3], {four: 4}]",
+		"Array.from({length: 20}, (_, i) => i)",
+		"({})",
+		"({name: 'John', age: 30, tags: ['a', 'b'], nested: {ok: true, none: null}})",
+		"Object.fromEntries(Array.from({length: 20}, (_, i) => ['k' + i, i]))",
+	}
+
+	for _, code := range tests {
+		val, err := ctx.Eval("(" + code + ")")
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", code, err)
+		}
+		data, err := val.MarshalMsgpack()
+		if err != nil {
+			t.Fatalf("MarshalMsgpack(%s) error = %v", code, err)
+		}
+		got, err := decodeMsgpack(data)
+		if err != nil {
+			t.Fatalf("decodeMsgpack(%s) error = %v", code, err)
+		}
+
+		jsonStr, err := val.JSONStringify()
+		if err != nil {
+			t.Fatalf("JSONStringify(%s) error = %v", code, err)
+		}
+		var want any
+		if err := json.Unmarshal([]byte(jsonStr), &want); err != nil {
+			t.Fatalf("json.Unmarshal(%s) error = %v", jsonStr, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("MarshalMsgpack(%s) decodes to %#v, want %#v", code, got, want)
+		}
+	}
+}
+
+func TestMarshalMsgpackTypes(t *testing.T) {
+	ctx := newTestContext(t)
+
+	encode := func(code string) ([]byte, error) {
+		val, err := ctx.Eval("(" + code + ")")
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", code, err)
+		}
+		return val.MarshalMsgpack()
+	}
+
+	// Integers use the smallest format
+	data, err := encode("[7, -5, 255, 65535, 2**32]")
+	if err != nil {
+		t.Fatalf("MarshalMsgpack error = %v", err)
+	}
+	want := []byte{0x95, 0x07, 0xfb, 0xcc, 0xff, 0xcd, 0xff, 0xff, 0xcf}
+	want = binary.BigEndian.AppendUint64(want, 1<<32)
+	if !bytes.Equal(data, want) {
+		t.Errorf("MarshalMsgpack = % x, want % x", data, want)
+	}
+
+	data, err = encode("new Uint8Array([1, 2, 3]).buffer")
+	if err != nil {
+		t.Fatalf("MarshalMsgpack(ArrayBuffer) error = %v", err)
+	}
+	if want := []byte{0xc4, 3, 1, 2, 3}; !bytes.Equal(data, want) {
+		t.Errorf("MarshalMsgpack(ArrayBuffer) = % x, want % x", data, want)
+	}
+
+	data, err = encode("({n: 123n, u: undefined})")
+	if err != nil {
+		t.Fatalf("MarshalMsgpack(BigInt) error = %v", err)
+	}
+	got, _ := decodeMsgpack(data)
+	if want := map[string]any{"n": float64(123), "u": nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MarshalMsgpack(BigInt) decodes to %#v, want %#v", got, want)
+	}
+
+	for _, code := range []string{
+		"() => 1",
+		"Symbol('s')",
+		"2n ** 64n",
+		"(() => { const o = {}; o.self = o; return o })()",
+	} {
+		if _, err := encode(code); err == nil {
+			t.Errorf("MarshalMsgpack(%s) should fail", code)
+		}
+	}
+}
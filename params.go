@@ -0,0 +1,215 @@
+package quickjs
+
+import (
+	"errors"
+	"strings"
+)
+
+// ParameterNames returns the names of the parameters a function declares,
+// in order, read from its source text as Function.prototype.toString
+// returns it. It suits generating schemas for functions exposed over RPC.
+//
+// A default value is dropped, so "b = 1" is reported as "b", and a rest
+// parameter is reported by its name without the dots. A parameter that
+// destructures its argument has no name; it is reported by its pattern as
+// written, such as "{x, y}". The source of native and bound functions has no
+// parameter list, and classes declare theirs in a constructor, so for those
+// ParameterNames returns an error.
+func (v Value) ParameterNames() ([]string, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	if !v.IsFunction() {
+		return nil, errors.New("value is not a function")
+	}
+	functionSource, err := v.ctx.helper("functionSource", functionSourceJS)
+	if err != nil {
+		return nil, err
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	src, err := functionSource.Call(this, v)
+	if err != nil {
+		return nil, err
+	}
+	defer src.free()
+	return parameterNames(src.String())
+}
+
+// parameterNames extracts the parameter names from the source of a
+// function, skipping strings, template literals, comments and regular
+// expressions in default values.
+func parameterNames(src string) ([]string, error) {
+	i := skipSpace(src, 0)
+	for {
+		word := identifierAt(src, i)
+		if word == "" {
+			break
+		}
+		j := skipSpace(src, i+len(word))
+		if strings.HasPrefix(src[j:], "=>") {
+			return []string{word}, nil // a => ...
+		}
+		if word == "class" {
+			return nil, errors.New("parameters of a class are not available")
+		}
+		if word != "async" {
+			break
+		}
+		i = j
+	}
+
+	// The parameter list is the first parenthesis outside a computed
+	// method name
+	open := -1
+	for depth := 0; i < len(src) && open < 0; {
+		switch b := src[i]; {
+		case b == '\'' || b == '"':
+			i = skipQuoted(src, i)
+			continue
+		case b == '[':
+			depth++
+		case b == ']':
+			depth--
+		case b == '(' && depth == 0:
+			open = i
+		}
+		i++
+	}
+	if open < 0 {
+		return nil, errors.New("function has no parameter list")
+	}
+
+	names := []string{}
+	first, eq := -1, -1 // start and default value of the current parameter
+	addParam := func(end int) {
+		if first < 0 {
+			return // trailing comma
+		}
+		if eq >= 0 {
+			end = eq
+		}
+		param := strings.TrimSpace(src[first:end])
+		if rest, ok := strings.CutPrefix(param, "..."); ok {
+			param = strings.TrimSpace(rest)
+		}
+		if name := identifierAt(param, 0); name != "" {
+			param = name
+		}
+		names = append(names, param)
+		first, eq = -1, -1
+	}
+
+	depth := 0
+	var templates []int // depth at each ${ that opened a template substitution
+	prev := byte(0)     // last significant character
+	prevWord := ""      // last word, if prev ends one
+	for i = open; i < len(src); {
+		b := src[i]
+		switch {
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			i++
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			i = skipPast(src, i+2, "\n")
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			i = skipPast(src, i+2, "*/")
+			continue
+		}
+		if depth == 1 && first < 0 && b != ',' && b != ')' {
+			first = i
+		}
+		switch {
+		case b == '\'' || b == '"':
+			i = skipQuoted(src, i)
+		case b == '`':
+			i = skipTemplate(src, i+1)
+			if strings.HasSuffix(src[:i], "${") {
+				depth++
+				templates = append(templates, depth)
+			}
+		case b == '/' && regexAllowed(prev, prevWord):
+			i = skipRegexp(src, i)
+		case b == '(' || b == '[' || b == '{':
+			depth++
+			i++
+		case b == '}' && len(templates) > 0 && templates[len(templates)-1] == depth:
+			templates = templates[:len(templates)-1]
+			depth--
+			i = skipTemplate(src, i+1)
+			if strings.HasSuffix(src[:i], "${") {
+				depth++
+				templates = append(templates, depth)
+			}
+		case b == ')' && depth == 1:
+			addParam(i)
+			if isNativeBody(src[i+1:]) {
+				return nil, errors.New("parameters of a native or bound function are not available")
+			}
+			return names, nil
+		case b == ')' || b == ']' || b == '}':
+			depth--
+			i++
+		case b == ',' && depth == 1:
+			addParam(i)
+			i++
+		case b == '=' && depth == 1 && eq < 0:
+			eq = i
+			i++
+		case isIdentifierPart(src, i):
+			start := i
+			for isIdentifierPart(src, i) {
+				i++
+			}
+			prev, prevWord = src[i-1], src[start:i]
+			continue
+		default:
+			i++
+		}
+		prev, prevWord = src[i-1], ""
+	}
+	return nil, errors.New("unterminated parameter list")
+}
+
+// identifierAt returns the identifier or keyword starting at position i of
+// s, or the empty string.
+func identifierAt(s string, i int) string {
+	if i >= len(s) || '0' <= s[i] && s[i] <= '9' {
+		return ""
+	}
+	j := i
+	for isIdentifierPart(s, j) {
+		j++
+	}
+	return s[i:j]
+}
+
+// skipSpace returns the index of the first character at or after i that is
+// not whitespace or part of a comment.
+func skipSpace(s string, i int) int {
+	for i < len(s) {
+		switch {
+		case s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			i = skipPast(s, i+2, "\n")
+		case strings.HasPrefix(s[i:], "/*"):
+			i = skipPast(s, i+2, "*/")
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// isNativeBody reports whether the source after a parameter list is the
+// placeholder body the engine shows for native and bound functions.
+func isNativeBody(s string) bool {
+	s = strings.TrimSpace(s)
+	body, ok := strings.CutPrefix(s, "{")
+	return ok && strings.HasPrefix(strings.TrimSpace(body), "[native code]")
+}
@@ -0,0 +1,57 @@
+package quickjs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParameterNames(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want []string
+	}{
+		{"function(a, b, c){}", []string{"a", "b", "c"}},
+		{"function named() {}", []string{}},
+		{"async function* gen(x, ...rest) {}", []string{"x", "rest"}},
+		{"(a, b = 1) => a + b", []string{"a", "b"}},
+		{"x => x", []string{"x"}},
+		{"async x => x", []string{"x"}},
+		{"({ method(p, q,) {} }).method", []string{"p", "q"}},
+		{"({ ['computed' + (1)](n) {} })['computed1']", []string{"n"}},
+		{"function(/* first */ a, b /* second */) {}", []string{"a", "b"}},
+		{"function({x, y}, [z] = [], w = ')') {}", []string{"{x, y}", "[z]", "w"}},
+		{"function(a = `(${f(1, 2)}, )`, b = /[),]/g, c = (1, 2)) {}", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		fn, err := ctx.Eval("(" + tt.code + ")")
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.code, err)
+		}
+		got, err := fn.ParameterNames()
+		if err != nil {
+			t.Errorf("ParameterNames(%s) error = %v", tt.code, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParameterNames(%s) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+
+	// toString methods of the function's own do not matter
+	fn, _ := ctx.Eval("(() => { const f = function(a) {}; f.toString = () => 'function(b) {}'; return f })()")
+	if got, _ := fn.ParameterNames(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("ParameterNames with toString = %q, want [a]", got)
+	}
+
+	for _, code := range []string{"Math.max", "(function(a) {}).bind(null)", "(class A { constructor(x) {} })", "({})"} {
+		val, err := ctx.Eval(code)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", code, err)
+		}
+		if _, err := val.ParameterNames(); err == nil {
+			t.Errorf("ParameterNames(%s) should fail", code)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package quickjs
+
+// PinnedValue is a reference to a value that keeps it alive, whatever else
+// refers to it, until Unpin is called. It is made by Value.Pin.
+type PinnedValue struct {
+	id  uint64
+	val Value
+}
+
+// Pin takes a reference to the value and adds it to the runtime's set of
+// pinned values, so the garbage collector never collects the value until
+// the returned PinnedValue is unpinned, or its context is closed. It suits
+// values the host holds on to for a long time, such as callbacks registered
+// by JavaScript, making the reference explicit. A value that cannot be
+// pinned, such as the zero Value or one whose context is closed, gives a
+// PinnedValue whose Value is the zero Value.
+func (v Value) Pin() PinnedValue {
+	if v.ctx == nil || v.freed() {
+		return PinnedValue{}
+	}
+	r := v.ctx.runtime
+	r.lock()
+	defer r.unlock()
+	if v.ctx.closed || r.closed {
+		return PinnedValue{}
+	}
+
+	ptr, err := r.bridge.DupValue(r.goCtx, v.ctx.ctxPtr, v.ptr)
+	if err != nil || ptr == 0 {
+		return PinnedValue{}
+	}
+	if r.pinned == nil {
+		r.pinned = make(map[uint64]Value)
+	}
+	r.lastPinID++
+	p := PinnedValue{id: r.lastPinID, val: v.ctx.value(ptr)}
+	r.pinned[p.id] = p.val
+	return p
+}
+
+// Value returns the pinned value. It stays valid until Unpin is called.
+func (p PinnedValue) Value() Value {
+	return p.val
+}
+
+// Unpin releases the reference taken by Pin, so the value can be collected
+// once nothing else refers to it. Unpinning more than once, or a copy of a
+// PinnedValue that was already unpinned, does nothing.
+func (p PinnedValue) Unpin() {
+	if p.val.ctx == nil {
+		return
+	}
+	r := p.val.ctx.runtime
+	r.lock()
+	defer r.unlock()
+	if _, ok := r.pinned[p.id]; !ok {
+		return
+	}
+	delete(r.pinned, p.id)
+	if !r.closed {
+		p.val.free()
+	}
+}
+
+// unpinContext releases the pinned values of a context that is being
+// closed. Caller must hold the mutex.
+func (r *Runtime) unpinContext(c *Context) {
+	for id, val := range r.pinned {
+		if val.ctx == c {
+			delete(r.pinned, id)
+			val.free()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package quickjs
+
+import "testing"
+
+func TestPin(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	// JavaScript keeps only a weak reference, so the Go side decides
+	// whether the object lives
+	val, err := ctx.Eval("var obj = {name: 'pinned'}; globalThis.ref = new WeakRef(obj); obj")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	pinned := val.Pin()
+	val.free()
+	if _, err := ctx.Eval("obj = undefined"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	alive := func() bool {
+		t.Helper()
+		if err := rt.RunGC(); err != nil {
+			t.Fatalf("RunGC error = %v", err)
+		}
+		result, err := ctx.Eval("ref.deref() !== undefined")
+		if err != nil {
+			t.Fatalf("Eval error = %v", err)
+		}
+		return result.Bool()
+	}
+
+	for i := range 20 {
+		if _, err := ctx.Eval("Array.from({length: 1000}, (_, i) => ({i}))"); err != nil {
+			t.Fatalf("Eval error = %v", err)
+		}
+		if !alive() {
+			t.Fatalf("pinned value collected after %d GC cycles", i+1)
+		}
+	}
+	name, err := pinned.Value().Get("name")
+	if err != nil || name.String() != "pinned" {
+		t.Errorf("pinned name = %v, %v, want pinned", name, err)
+	}
+
+	pinned.Unpin()
+	pinned.Unpin()
+	if alive() {
+		t.Error("unpinned value was not collected")
+	}
+
+	// Closing the context releases its pins
+	other, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	obj, _ := other.Eval("({})")
+	leftover := obj.Pin()
+	if err := other.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	if len(rt.pinned) != 0 {
+		t.Errorf("%d values still pinned after Close", len(rt.pinned))
+	}
+	leftover.Unpin()
+
+	if p := (Value{}).Pin(); p.Value().ctx != nil {
+		t.Error("Pin of the zero Value should give the zero Value")
+	}
+}
@@ -0,0 +1,55 @@
+package quickjs
+
+// Await waits for the value, a promise, to settle by running pending jobs,
+// and returns its fulfillment value, or its rejection reason as a *JSError.
+// A value that is not a promise is returned as it is, as await does in
+// JavaScript. If the promise is still pending once no jobs are left, Await
+// returns ErrUnsettled: nothing queued could settle it, so it would wait
+// forever. A job that throws is handled as described for
+// ExecutePendingJobs.
+func (v Value) Await() (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
+	}
+	r := v.ctx.runtime
+	r.lock()
+	defer r.unlock()
+	return r.withDeadline(func() (Value, error) {
+		return v.ctx.await(v)
+	})
+}
+
+// Resolve returns a promise fulfilled with v, like Promise.resolve. If v is
+// itself a promise, the result follows it instead.
+func (c *Context) Resolve(v Value) (Value, error) {
+	if err := checkFreed(v); err != nil {
+		return Value{}, err
+	}
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	resolved, err := c.helper("resolved", resolvedJS)
+	if err != nil {
+		return Value{}, err
+	}
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	return resolved.Call(this, v)
+}
+
+// Reject returns a promise rejected with reason, like Promise.reject.
+func (c *Context) Reject(reason Value) (Value, error) {
+	if err := checkFreed(reason); err != nil {
+		return Value{}, err
+	}
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	rejected, err := c.helper("rejected", rejectedJS)
+	if err != nil {
+		return Value{}, err
+	}
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	return rejected.Call(this, reason)
+}
@@ -0,0 +1,72 @@
+package quickjs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAwait(t *testing.T) {
+	ctx := newTestContext(t)
+
+	p, err := ctx.Eval("(async () => { await null; return 6 * 7; })()")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if !p.IsPromise() {
+		t.Fatal("async function should return a promise")
+	}
+	val, err := p.Await()
+	if err != nil {
+		t.Fatalf("Await error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 42 {
+		t.Errorf("Await = %d, want 42", n)
+	}
+
+	p, _ = ctx.Eval("Promise.reject(new RangeError('nope'))")
+	var jsErr *JSError
+	if _, err := p.Await(); !errors.As(err, &jsErr) || jsErr.Name != "RangeError" || jsErr.Message != "nope" {
+		t.Errorf("Await of a rejected promise error = %v, want RangeError: nope", err)
+	}
+
+	p, _ = ctx.Eval("new Promise(() => {})")
+	if _, err := p.Await(); !errors.Is(err, ErrUnsettled) {
+		t.Errorf("Await of a pending promise error = %v, want ErrUnsettled", err)
+	}
+
+	plain := ctx.String("plain")
+	if val, err := plain.Await(); err != nil || val.String() != "plain" {
+		t.Errorf("Await of a string = %v, %v; want plain", val, err)
+	}
+}
+
+func TestResolveReject(t *testing.T) {
+	ctx := newTestContext(t)
+
+	p, err := ctx.Resolve(ctx.Int64(7))
+	if err != nil {
+		t.Fatalf("Resolve error = %v", err)
+	}
+	if !p.IsPromise() {
+		t.Fatal("Resolve should return a promise")
+	}
+	if val, err := p.Await(); err != nil || val.String() != "7" {
+		t.Errorf("Await of Resolve(7) = %v, %v; want 7", val, err)
+	}
+
+	reason, _ := ctx.Eval("new TypeError('bad')")
+	p, err = ctx.Reject(reason)
+	if err != nil {
+		t.Fatalf("Reject error = %v", err)
+	}
+	if err := ctx.SetGlobal("rejected", p); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+	val, _, err := ctx.EvalAsyncStats("rejected.catch((e) => e.message + ' caught')")
+	if err != nil {
+		t.Fatalf("EvalAsyncStats error = %v", err)
+	}
+	if got := val.String(); got != "bad caught" {
+		t.Errorf("caught = %q, want %q", got, "bad caught")
+	}
+}
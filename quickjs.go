@@ -28,10 +28,24 @@ package quickjs
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"math"
+	"math/big"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
 
 	"github.com/Gaurav-Gosain/quickjs/internal/bridge"
 )
@@ -44,6 +58,9 @@ const (
 	EvalGlobal EvalFlag = 0
 	// EvalModule evaluates code as an ES6 module.
 	EvalModule EvalFlag = 1 << 0
+
+	// evalCompileOnly compiles code without running it.
+	evalCompileOnly EvalFlag = 1 << 5
 )
 
 // Runtime represents a JavaScript runtime instance.
@@ -57,6 +74,75 @@ type Runtime struct {
 	mu      sync.Mutex
 	logFunc func(msg string)
 
+	// Console output limit set with SetMaxLogLength, and the state of the
+	// line being written
+	maxLogLength int
+	logLineLen   int
+	logLineCut   bool
+
+	// noConsole leaves console and print out of new contexts
+	noConsole bool
+
+	// maxDepth limits the nesting of values Marshal, Unmarshal and Inspect
+	// convert (see Config.MaxDepth)
+	maxDepth int
+
+	// uncaughtHandler receives exceptions thrown by pending jobs
+	uncaughtHandler func(err error)
+
+	// Open contexts, limited by SetMaxContexts
+	contexts    map[*Context]struct{}
+	maxContexts int // 0 means unlimited
+
+	// nativeModules are installed into every context, in registration order
+	nativeModules []nativeModule
+
+	// atoms caches the atoms created by Context.Atom, by name
+	atoms map[string]uint32
+
+	// pinned holds the references kept by Value.Pin, by pin ID
+	pinned    map[uint64]Value
+	lastPinID uint64
+
+	// Values whose references are released the next time the mutex is
+	// taken, see releasePending
+	finalizers bool // set finalizers on new values, see Config.Finalizers
+	pendingMu  sync.Mutex
+	pending    []pendingRelease
+	hasPending atomic.Bool
+
+	// Timers scheduled with setTimeout and setInterval, run by RunEventLoop,
+	// and AsyncFunction calls, which it waits for
+	timersEnabled bool // install the timer functions, see Config.Timers
+	timers        map[int64]*timer
+	lastTimerID   int64
+	asyncCalls    int           // AsyncFunction calls still running
+	loopWake      chan struct{} // wakes RunEventLoop when a timer is added or a call ends
+
+	// sourceTransform rewrites script and module sources before compilation
+	sourceTransform func(name, source string) (string, error)
+
+	// moduleLoader supplies the sources of imported modules, and
+	// moduleLoaderID is the ID of the callback the engine calls it through;
+	// see SetModuleLoader
+	moduleLoader   func(name string) (string, error)
+	moduleLoaderID uint32
+
+	// Counters reported by Stats
+	evalCount uint64
+	gcCount   uint64
+
+	// onClose callbacks run by Close, in registration order
+	onClose []func()
+	closed  bool
+	closing atomic.Bool // Close is waiting for the mutex, see lockInterrupting
+
+	// Interrupt support, see watchContext
+	watched    []context.Context // contexts of in-progress calls, innermost last
+	deadline   time.Time         // deadline set with SetDeadline, or zero
+	inDeadline bool              // a call is running under the deadline
+	metering   bool              // EvalWithFuel is metering fuel
+
 	// For reentrant callback support: track which goroutine holds the lock
 	lockHolder uintptr    // goroutine ID of current lock holder (0 if unlocked)
 	lockDepth  int32      // recursion depth
@@ -83,6 +169,10 @@ func (r *Runtime) lock() {
 	r.lockHolder = gid
 	r.lockDepth = 1
 	r.lockMu.Unlock()
+
+	if r.hasPending.Load() {
+		r.releasePending()
+	}
 }
 
 // unlock releases the runtime mutex.
@@ -112,14 +202,80 @@ func getGoroutineID() uintptr {
 	return id
 }
 
+// Config holds runtime options for NewRuntimeWithConfig. The zero value gives
+// the same runtime as NewRuntime.
+type Config struct {
+	// Context is used for all calls into the WebAssembly module. It defaults
+	// to context.Background().
+	Context context.Context
+
+	// NoConsole leaves console and print out of new contexts, so scripts
+	// have no way to produce host output.
+	NoConsole bool
+
+	// StackSize is the size in bytes of the native stack the engine runs
+	// on. It defaults to DefaultStackSize; a larger stack allows deeper
+	// recursion. The stack is allocated outside the runtime's heap, so it
+	// does not count towards its memory limit. The runtime's stack limit
+	// (see SetMaxStackSize) is set to three quarters of it, leaving room for
+	// native code that does not check the limit, so runaway recursion
+	// throws a RangeError and leaves the runtime usable. wazero's own limit
+	// on call depth still applies; a call that reaches it fails with a
+	// "stack overflow" error.
+	StackSize uint32
+
+	// Clock, if set, is the clock the engine reads the time from, for
+	// Date and performance.now(). A clock that returns a fixed time makes
+	// time-dependent scripts reproducible. Without one, the engine sees
+	// wazero's fake clock, which starts at 2022-01-01T00:00:00Z and
+	// advances by a millisecond on every read. SetNow overrides Date in a
+	// single context instead.
+	Clock func() time.Time
+
+	// MaxDepth limits how deeply nested the values converted by Marshal,
+	// Unmarshal and Inspect may be, counting arrays, objects, maps and
+	// structs, so that a pathological or self-referencing value gives an
+	// error instead of exhausting the Go stack. It defaults to
+	// DefaultMaxDepth.
+	MaxDepth int
+
+	// Finalizers makes values reclaim their references once the Go garbage
+	// collector finds them unreachable, for programs that do not call
+	// Value.Free. The references are released the next time the runtime is
+	// used, on the goroutine using it.
+	Finalizers bool
+
+	// Timers installs setTimeout, setInterval, clearTimeout and
+	// clearInterval in new contexts. Their callbacks run when
+	// Runtime.RunEventLoop is called; nothing runs them otherwise.
+	Timers bool
+}
+
+// DefaultMaxDepth is the nesting limit of a runtime whose Config leaves
+// MaxDepth unset.
+const DefaultMaxDepth = 1000
+
+// DefaultStackSize is the native stack size of a runtime whose Config
+// leaves StackSize unset.
+const DefaultStackSize = 1 << 20
+
 // NewRuntime creates a new JavaScript runtime with default settings.
 func NewRuntime() (*Runtime, error) {
-	return NewRuntimeWithContext(context.Background())
+	return NewRuntimeWithConfig(Config{})
 }
 
 // NewRuntimeWithContext creates a new JavaScript runtime with the given context.
 func NewRuntimeWithContext(ctx context.Context) (*Runtime, error) {
-	b, err := bridge.New(ctx)
+	return NewRuntimeWithConfig(Config{Context: ctx})
+}
+
+// NewRuntimeWithConfig creates a new JavaScript runtime with the given options.
+func NewRuntimeWithConfig(cfg Config) (*Runtime, error) {
+	ctx := cfg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	b, err := bridge.New(ctx, cfg.Clock)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize QuickJS bridge: %w", err)
 	}
@@ -131,30 +287,114 @@ func NewRuntimeWithContext(ctx context.Context) (*Runtime, error) {
 		return nil, fmt.Errorf("failed to create QuickJS runtime: %w", err)
 	}
 
+	stackSize := cfg.StackSize
+	if stackSize == 0 {
+		stackSize = DefaultStackSize
+	}
+	if err := setStackSize(ctx, b, rtPtr, stackSize); err != nil {
+		b.FreeRuntime(ctx, rtPtr)
+		b.Close(ctx)
+		return nil, fmt.Errorf("failed to set stack size: %w", err)
+	}
+
+	if err := b.SetupInterruptHandler(ctx, rtPtr); err != nil {
+		b.FreeRuntime(ctx, rtPtr)
+		b.Close(ctx)
+		return nil, fmt.Errorf("failed to set up interrupt handler: %w", err)
+	}
+
+	maxDepth := cfg.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
 	return &Runtime{
-		bridge:  b,
-		rtPtr:   rtPtr,
-		goCtx:   ctx,
-		logFunc: func(msg string) { fmt.Print(msg) },
+		bridge:        b,
+		rtPtr:         rtPtr,
+		goCtx:         ctx,
+		logFunc:       func(msg string) { fmt.Print(msg) },
+		noConsole:     cfg.NoConsole,
+		maxDepth:      maxDepth,
+		finalizers:    cfg.Finalizers,
+		timersEnabled: cfg.Timers,
+		loopWake:      make(chan struct{}, 1),
 	}, nil
 }
 
-// Close releases all resources associated with the runtime.
+// setStackSize gives a new runtime a stack of the given size and sets the
+// engine's stack limit to match.
+func setStackSize(ctx context.Context, b *bridge.Bridge, rtPtr, size uint32) error {
+	if size < bridge.LinkedStackSize {
+		return fmt.Errorf("stack size %d is below the minimum of %d", size, bridge.LinkedStackSize)
+	}
+	if err := b.SetStackSize(ctx, rtPtr, size); err != nil {
+		return err
+	}
+	return b.SetMaxStackSize(ctx, rtPtr, size/4*3)
+}
+
+// Close releases all resources associated with the runtime. It first runs
+// the callbacks registered with OnClose. Calling Close again does nothing.
+//
+// Close may be called while another goroutine is using the runtime. Any
+// JavaScript running then is aborted, its caller gets ErrClosed, and Close
+// waits for the call to return before freeing anything. Evaluations and
+// calls made afterwards return ErrClosed as well. Close may not be called
+// from a Go callback.
 func (r *Runtime) Close() error {
-	r.lock()
+	r.lockInterrupting()
 	defer r.unlock()
+	if r.closed {
+		return nil
+	}
+	if r.lockDepth > 1 {
+		return errors.New("cannot close runtime from inside a call")
+	}
+	for _, fn := range r.onClose {
+		fn()
+	}
+	r.onClose = nil
+	r.closed = true
 	if err := r.bridge.FreeRuntime(r.goCtx, r.rtPtr); err != nil {
 		return err
 	}
 	return r.bridge.Close(r.goCtx)
 }
 
+// OnClose registers fn to be called when the runtime is closed, before any
+// of its resources are freed, for example to flush buffered console output.
+// Callbacks run in the order they were registered, on the goroutine calling
+// Close, and may still use the runtime and its contexts. Each runs at most
+// once.
+func (r *Runtime) OnClose(fn func()) {
+	r.lock()
+	defer r.unlock()
+	r.onClose = append(r.onClose, fn)
+}
+
 // SetLogFunc sets the function called for console.log output from JavaScript.
 func (r *Runtime) SetLogFunc(fn func(msg string)) {
 	r.lock()
 	defer r.unlock()
 	r.logFunc = fn
-	r.bridge.SetLogFunc(fn)
+	r.installLogFunc()
+}
+
+// ErrClosed is returned by evaluations and calls made on a closed runtime,
+// and by those running when Close is called, which aborts them.
+var ErrClosed = errors.New("runtime is closed")
+
+// ErrTooManyContexts is returned by NewContext when the runtime already has
+// as many open contexts as allowed by SetMaxContexts.
+var ErrTooManyContexts = errors.New("maximum number of contexts reached")
+
+// SetMaxContexts limits how many contexts may be open on the runtime at
+// once. Once the limit is reached, NewContext returns ErrTooManyContexts
+// until a context is closed. A limit of 0 or less removes the limit.
+func (r *Runtime) SetMaxContexts(n int) {
+	r.lock()
+	defer r.unlock()
+	r.maxContexts = max(n, 0)
 }
 
 // NewContext creates a new JavaScript execution context.
@@ -162,37 +402,164 @@ func (r *Runtime) NewContext() (*Context, error) {
 	r.lock()
 	defer r.unlock()
 
+	if r.closed {
+		return nil, ErrClosed
+	}
+	if r.maxContexts > 0 && len(r.contexts) >= r.maxContexts {
+		return nil, ErrTooManyContexts
+	}
+
 	ctxPtr, err := r.bridge.NewContext(r.goCtx, r.rtPtr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create JavaScript context: %w", err)
 	}
 
 	// Add console.log support
-	if err := r.bridge.AddConsole(r.goCtx, ctxPtr); err != nil {
-		_ = r.bridge.FreeContext(r.goCtx, ctxPtr)
-		return nil, fmt.Errorf("failed to add console support: %w", err)
+	if !r.noConsole {
+		if err := r.bridge.AddConsole(r.goCtx, ctxPtr); err != nil {
+			_ = r.bridge.FreeContext(r.goCtx, ctxPtr)
+			return nil, fmt.Errorf("failed to add console support: %w", err)
+		}
 	}
 
-	return &Context{
+	c := &Context{
 		runtime: r,
 		ctxPtr:  ctxPtr,
-	}, nil
+	}
+	if !r.noConsole {
+		c.printName = "print"
+	}
+	if r.timersEnabled {
+		if err := c.installTimers(); err != nil {
+			_ = r.bridge.FreeContext(r.goCtx, ctxPtr)
+			return nil, fmt.Errorf("failed to add timers: %w", err)
+		}
+	}
+	for _, m := range r.nativeModules {
+		if err := c.installNativeModule(m); err != nil {
+			_ = r.bridge.FreeContext(r.goCtx, ctxPtr)
+			return nil, fmt.Errorf("failed to install module %q: %w", m.name, err)
+		}
+	}
+
+	if r.contexts == nil {
+		r.contexts = make(map[*Context]struct{})
+	}
+	r.contexts[c] = struct{}{}
+	return c, nil
 }
 
 // RunGC triggers garbage collection.
 func (r *Runtime) RunGC() error {
 	r.lock()
 	defer r.unlock()
+	r.gcCount++
 	return r.bridge.RunGC(r.goCtx, r.rtPtr)
 }
 
 // ExecutePendingJobs executes pending promise jobs.
 // Returns the number of jobs executed, or an error.
+//
+// If a job throws, for example a callback passed to queueMicrotask, the
+// exception is passed to the handler set with SetUncaughtExceptionHandler
+// and the remaining jobs still run. Without a handler, ExecutePendingJobs
+// stops and returns the exception as a *JSError.
 func (r *Runtime) ExecutePendingJobs() (int, error) {
 	r.lock()
 	defer r.unlock()
-	n, err := r.bridge.ExecutePendingJobs(r.goCtx, r.rtPtr)
-	return int(n), err
+
+	n := 0
+	_, err := r.withDeadline(func() (Value, error) {
+		for {
+			ran, err := r.executePendingJob()
+			if ran {
+				n++
+			}
+			if err != nil || !ran {
+				return Value{}, err
+			}
+		}
+	})
+	return n, err
+}
+
+// runUntilPoll is how long RunUntil waits before checking again when there
+// are no jobs to run.
+const runUntilPoll = time.Millisecond
+
+// RunUntil runs pending jobs until cond returns true, for example when a Go
+// function called from JavaScript has set a flag. cond is checked before
+// each round of jobs, without the runtime locked, so it may use the runtime.
+// When no jobs are pending, RunUntil unlocks the runtime and waits briefly
+// before checking again, so other goroutines can queue work meanwhile.
+//
+// If cond is still false after timeout, RunUntil returns an error wrapping
+// context.DeadlineExceeded. A timeout of zero or less means no limit. A job
+// that throws is handled as described for ExecutePendingJobs.
+func (r *Runtime) RunUntil(cond func() bool, timeout time.Duration) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for !cond() {
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return fmt.Errorf("condition not met within %v: %w", timeout, context.DeadlineExceeded)
+		}
+		n, err := r.ExecutePendingJobs()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			time.Sleep(runUntilPoll)
+		}
+	}
+	return nil
+}
+
+// executePendingJob runs a single pending job and reports whether there was
+// one. A job that throws is handled as described for ExecutePendingJobs.
+// Caller must hold the mutex.
+func (r *Runtime) executePendingJob() (bool, error) {
+	ret, jobCtxPtr, err := r.bridge.ExecutePendingJob(r.goCtx, r.rtPtr)
+	if err != nil {
+		return false, err
+	}
+	if ret >= 0 {
+		return ret > 0, nil
+	}
+
+	jobErr := r.jobException(jobCtxPtr)
+	if r.uncaughtHandler == nil {
+		return true, jobErr
+	}
+	r.uncaughtHandler(jobErr)
+	return true, nil
+}
+
+// jobException takes the pending exception from the context a failed job
+// ran in. Caller must hold the mutex.
+func (r *Runtime) jobException(ctxPtr uint32) error {
+	excPtr, err := r.bridge.GetException(r.goCtx, ctxPtr)
+	if err != nil {
+		return err
+	}
+	defer r.bridge.FreeValue(r.goCtx, ctxPtr, excPtr)
+	c := &Context{runtime: r, ctxPtr: ctxPtr}
+	return c.newJSError(excPtr)
+}
+
+// SetUncaughtExceptionHandler sets a function called with the error when a
+// job run by ExecutePendingJobs throws, much like window.onerror in a
+// browser. Pass nil to remove the handler, which makes ExecutePendingJobs
+// return such errors instead.
+//
+// The handler runs while the runtime is locked, on the goroutine that called
+// ExecutePendingJobs; it may use the runtime but must not block on another
+// goroutine that does.
+func (r *Runtime) SetUncaughtExceptionHandler(fn func(err error)) {
+	r.lock()
+	defer r.unlock()
+	r.uncaughtHandler = fn
 }
 
 // SetMemoryLimit sets the memory limit for the runtime in bytes.
@@ -202,7 +569,97 @@ func (r *Runtime) SetMemoryLimit(limit uint32) error {
 	return r.bridge.SetMemoryLimit(r.goCtx, r.rtPtr, limit)
 }
 
-// SetMaxStackSize sets the maximum stack size for the runtime.
+// MemoryUsage reports the memory held by a runtime, as computed by QuickJS.
+// Sizes are in bytes.
+type MemoryUsage struct {
+	MallocSize        int64 // Heap bytes allocated by the runtime
+	MallocLimit       int64 // Limit set by SetMemoryLimit
+	MemoryUsedSize    int64 // Bytes used by the objects counted below
+	MallocCount       int64 // Live heap allocations
+	MemoryUsedCount   int64 // Live objects of all kinds
+	AtomCount         int64
+	AtomSize          int64
+	StringCount       int64
+	StringSize        int64
+	ObjectCount       int64
+	ObjectSize        int64
+	PropertyCount     int64
+	PropertySize      int64
+	ShapeCount        int64
+	ShapeSize         int64
+	FunctionCount     int64 // Compiled JavaScript functions
+	FunctionSize      int64
+	FunctionCodeSize  int64 // Bytecode
+	LineTableCount    int64 // Line number tables for stack traces
+	LineTableSize     int64
+	CFunctionCount    int64 // Native functions, including Go functions
+	ArrayCount        int64
+	FastArrayCount    int64 // Arrays stored densely
+	FastArrayElements int64
+	BinaryObjectCount int64 // ArrayBuffers and typed arrays
+	BinaryObjectSize  int64
+}
+
+// MemoryUsage returns a snapshot of the memory held by the runtime and all
+// its contexts. Closing a context frees its globals and collects the garbage
+// they leave, so comparing MemoryUsage before and after shows whether
+// anything was retained.
+func (r *Runtime) MemoryUsage() (MemoryUsage, error) {
+	r.lock()
+	defer r.unlock()
+
+	f, err := r.bridge.ComputeMemoryUsage(r.goCtx, r.rtPtr)
+	if err != nil {
+		return MemoryUsage{}, err
+	}
+	return MemoryUsage{
+		MallocSize: f[0], MallocLimit: f[1], MemoryUsedSize: f[2],
+		MallocCount: f[3], MemoryUsedCount: f[4],
+		AtomCount: f[5], AtomSize: f[6],
+		StringCount: f[7], StringSize: f[8],
+		ObjectCount: f[9], ObjectSize: f[10],
+		PropertyCount: f[11], PropertySize: f[12],
+		ShapeCount: f[13], ShapeSize: f[14],
+		FunctionCount: f[15], FunctionSize: f[16], FunctionCodeSize: f[17],
+		LineTableCount: f[18], LineTableSize: f[19],
+		CFunctionCount: f[20], ArrayCount: f[21],
+		FastArrayCount: f[22], FastArrayElements: f[23],
+		BinaryObjectCount: f[24], BinaryObjectSize: f[25],
+	}, nil
+}
+
+// RuntimeStats summarizes a runtime's state for monitoring.
+type RuntimeStats struct {
+	Memory      MemoryUsage // Zero if it could not be computed
+	Contexts    int         // Open contexts
+	JobsPending bool        // Promise jobs are waiting for ExecutePendingJobs
+	Evals       uint64      // Scripts and modules evaluated, in all contexts
+	GCs         uint64      // Calls to RunGC
+}
+
+// Stats returns a snapshot of the runtime's memory usage, open contexts,
+// pending jobs, and how many evaluations and garbage collections it has run.
+// Evals counts the scripts and modules passed to the Eval methods, not code
+// the package runs internally. GCs counts explicit RunGC calls; collections
+// QuickJS starts by itself are not reported.
+func (r *Runtime) Stats() RuntimeStats {
+	r.lock()
+	defer r.unlock()
+
+	stats := RuntimeStats{
+		Contexts: len(r.contexts),
+		Evals:    r.evalCount,
+		GCs:      r.gcCount,
+	}
+	stats.Memory, _ = r.MemoryUsage()
+	stats.JobsPending, _ = r.bridge.IsJobPending(r.goCtx, r.rtPtr)
+	return stats
+}
+
+// SetMaxStackSize sets the maximum stack size for the runtime. Above the
+// limit, calls throw a RangeError. It should stay below the native stack
+// size (see Config.StackSize), so that the limit is reached before the
+// stack overflows.
 func (r *Runtime) SetMaxStackSize(size uint32) error {
 	r.lock()
 	defer r.unlock()
@@ -213,13 +670,47 @@ func (r *Runtime) SetMaxStackSize(size uint32) error {
 type Context struct {
 	runtime *Runtime
 	ctxPtr  uint32
+
+	// now overrides Date.now once installed by SetNow
+	now          func() float64
+	nowInstalled bool
+
+	// helpers caches the JavaScript functions built by helper
+	helpers map[string]Value
+
+	// printName is the global the print function is installed as, empty if
+	// there is none (see SetPrintName)
+	printName string
+
+	closed bool
 }
 
 // Close releases all resources associated with the context.
+// Closing a context more than once has no effect.
 func (c *Context) Close() error {
 	c.runtime.lock()
 	defer c.runtime.unlock()
-	return c.runtime.bridge.FreeContext(c.runtime.goCtx, c.ctxPtr)
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.runtime.closed {
+		// Its memory went with the runtime's
+		return nil
+	}
+	delete(c.runtime.contexts, c)
+	for _, fn := range c.helpers {
+		fn.free()
+	}
+	c.helpers = nil
+	c.runtime.unpinContext(c)
+	c.runtime.clearTimers(c)
+	if err := c.runtime.bridge.FreeContext(c.runtime.goCtx, c.ctxPtr); err != nil {
+		return err
+	}
+	// The globals often form cycles (globalThis.globalThis, prototypes), which
+	// only the cycle collector frees
+	return c.runtime.bridge.RunGC(c.runtime.goCtx, c.runtime.rtPtr)
 }
 
 // Eval evaluates JavaScript code and returns the result.
@@ -227,197 +718,760 @@ func (c *Context) Eval(code string) (Value, error) {
 	return c.EvalFile(code, "<eval>")
 }
 
-// EvalFile evaluates JavaScript code with a specified filename for error messages.
-func (c *Context) EvalFile(code, filename string) (Value, error) {
+// EvalResult evaluates JavaScript code like Eval, and also reports whether
+// the code threw. If it did, the error is the *JSError for the thrown value,
+// even when that value converts to an empty or undefined-looking error. If
+// threw is false and the error is non-nil, the code was never run, for
+// example because the source transform failed. A script that completes
+// without producing a value, such as the empty string, returns undefined
+// with threw false.
+func (c *Context) EvalResult(code string) (result Value, threw bool, err error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
 
-	valPtr, err := c.runtime.bridge.Eval(c.runtime.goCtx, c.ctxPtr, code, filename, int32(EvalGlobal))
+	code, err = c.runtime.transformSource("<eval>", code)
 	if err != nil {
-		return Value{}, err
+		return Value{}, false, err
 	}
+	result, err = c.runtime.withDeadline(func() (Value, error) {
+		valPtr, err := c.runtime.bridge.Eval(c.runtime.goCtx, c.ctxPtr, code, "<eval>", int32(EvalGlobal))
+		if err != nil {
+			return Value{}, err
+		}
+		threw, _ = c.runtime.bridge.IsException(c.runtime.goCtx, valPtr)
+		return c.checkException(valPtr)
+	})
+	return result, threw, err
+}
+
+// EvalAsyncStats evaluates JavaScript code, then runs pending jobs until
+// none are left, and returns the result together with the number of jobs
+// that ran, such as promise reactions and async function continuations. If
+// the result is a promise, its fulfillment value is returned instead, or
+// its rejection as an error. A job that throws is handled as described for
+// ExecutePendingJobs.
+func (c *Context) EvalAsyncStats(code string) (Value, int, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
 
-	return c.checkException(valPtr)
+	result, err := c.EvalFile(code, "<eval>")
+	if err != nil {
+		return Value{}, 0, err
+	}
+	n, err := c.runtime.ExecutePendingJobs()
+	if err != nil {
+		result.free()
+		return Value{}, n, err
+	}
+	if !result.IsPromise() {
+		return result, n, nil
+	}
+	defer result.free()
+	settled, err := c.await(result)
+	return settled, n, err
 }
 
-// EvalModule evaluates JavaScript code as an ES6 module.
-func (c *Context) EvalModule(code, filename string) (Value, error) {
+// RunMain runs a script as a program: it evaluates code, which must define
+// a global function named main, then calls main with args as an array of
+// strings and returns its result. Pending jobs are run after the call, and
+// if main returns a promise, as an async main does, RunMain waits for it
+// and returns its value, or its rejection as the error.
+func (c *Context) RunMain(code string, args []string) (Value, error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
 
-	valPtr, err := c.runtime.bridge.EvalModule(c.runtime.goCtx, c.ctxPtr, code, filename)
+	result, err := c.EvalFile(code, "<eval>")
 	if err != nil {
 		return Value{}, err
 	}
+	result.free()
 
-	return c.checkException(valPtr)
+	callMain, err := c.helper("callMain", callMainJS)
+	if err != nil {
+		return Value{}, err
+	}
+	argv := c.StringArray(args)
+	defer argv.free()
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err = callMain.Call(this, argv)
+	if err != nil {
+		return Value{}, err
+	}
+	if _, err := c.runtime.ExecutePendingJobs(); err != nil {
+		result.free()
+		return Value{}, err
+	}
+	if !result.IsPromise() {
+		return result, nil
+	}
+	defer result.free()
+	return c.await(result)
 }
 
-// checkException checks if the value is an exception and returns an error if so.
-// Caller must hold the mutex.
-func (c *Context) checkException(valPtr uint32) (Value, error) {
-	isExc, _ := c.runtime.bridge.IsException(c.runtime.goCtx, valPtr)
-	if isExc {
-		// Get the actual exception
-		excPtr, _ := c.runtime.bridge.GetException(c.runtime.goCtx, c.ctxPtr)
-		errMsg, _ := c.runtime.bridge.GetErrorMessage(c.runtime.goCtx, c.ctxPtr, excPtr)
-		if errMsg == "" {
-			errMsg = "JavaScript exception"
-		}
-		_ = c.runtime.bridge.FreeValue(c.runtime.goCtx, c.ctxPtr, excPtr)
-		return Value{}, errors.New(errMsg)
-	}
-	return Value{ctx: c, ptr: valPtr}, nil
+// ExecReport describes the resources an evaluation used, as returned by
+// EvalWithReport. Memory sizes are heap bytes, as in MemoryUsage.MallocSize.
+type ExecReport struct {
+	Duration   time.Duration // Wall-clock time, including the jobs
+	Jobs       int           // Pending jobs run, as counted by EvalAsyncStats
+	GCs        uint64        // RunGC calls made meanwhile, as counted by Stats
+	Memory     int64         // Heap size when the evaluation finished
+	PeakMemory int64         // Largest heap size sampled; see EvalWithReport
 }
 
-// Global returns the global object.
-func (c *Context) Global() (Value, error) {
+// EvalWithReport evaluates JavaScript code like EvalAsyncStats, running the
+// jobs it queues and settling a promise result, and reports the time and
+// memory it took, for per-request monitoring or billing. The report is
+// filled in even if the evaluation fails.
+//
+// The engine does not track its peak memory use, so PeakMemory is the
+// largest heap size seen at the points where the report samples it: before
+// the code runs, after the script, after its jobs, and at the end. Memory
+// allocated and freed again between two samples is not seen. Like Stats,
+// GCs only counts explicit RunGC calls, made for example by Go functions
+// the code calls, not the collections the engine runs by itself.
+func (c *Context) EvalWithReport(code string) (Value, ExecReport, error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
 
-	valPtr, err := c.runtime.bridge.GetGlobalObject(c.runtime.goCtx, c.ctxPtr)
+	var report ExecReport
+	sample := func() {
+		if m, err := c.runtime.MemoryUsage(); err == nil {
+			report.Memory = m.MallocSize
+			report.PeakMemory = max(report.PeakMemory, m.MallocSize)
+		}
+	}
+	gcs := c.runtime.gcCount
+	start := time.Now()
+	finish := func() {
+		report.Duration = time.Since(start)
+		report.GCs = c.runtime.gcCount - gcs
+		sample()
+	}
+	sample()
+
+	result, err := c.EvalFile(code, "<eval>")
 	if err != nil {
-		return Value{}, err
+		finish()
+		return Value{}, report, err
 	}
-	return Value{ctx: c, ptr: valPtr}, nil
+	sample()
+	report.Jobs, err = c.runtime.ExecutePendingJobs()
+	if err != nil {
+		result.free()
+		finish()
+		return Value{}, report, err
+	}
+	if result.IsPromise() {
+		sample()
+		defer result.free()
+		result, err = c.await(result)
+	}
+	finish()
+	return result, report, err
 }
 
-// ============================================================================
-// Value Creation
-// ============================================================================
-
-// Undefined returns the JavaScript undefined value.
-func (c *Context) Undefined() Value {
+// EvalCollect evaluates JavaScript code like Eval, discarding its result,
+// and returns the globals it defined or assigned: every property of the
+// global object that is new, or holds a different value, when the code
+// stops. They are collected whether the code completes, throws or is
+// interrupted, for example by the deadline set with SetDeadline, so the
+// state an interrupted script reached can be inspected. The error is the
+// one Eval would return.
+//
+// Only data properties of the global object are compared, by identity, so
+// changes inside an existing object are not seen, and neither are top-level
+// let, const and class declarations, which do not create properties. The
+// values are new references owned by the caller. If the code never ran, as
+// when the source transform fails, or the runtime was closed, the map is
+// nil.
+func (c *Context) EvalCollect(code string) (globals map[string]Value, err error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
-	return c.undefinedUnlocked()
-}
 
-// undefinedUnlocked returns undefined without acquiring lock (for callback use).
-func (c *Context) undefinedUnlocked() Value {
-	ptr, _ := c.runtime.bridge.NewUndefined(c.runtime.goCtx)
-	return Value{ctx: c, ptr: ptr}
-}
+	code, err = c.runtime.transformSource("<eval>", code)
+	if err != nil {
+		return nil, err
+	}
+	globalsSnapshot, err := c.helper("globalsSnapshot", globalsSnapshotJS)
+	if err != nil {
+		return nil, err
+	}
+	globalsChanged, err := c.helper("globalsChanged", globalsChangedJS)
+	if err != nil {
+		return nil, err
+	}
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	snapshot, err := globalsSnapshot.Call(this)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.free()
 
-// Null returns the JavaScript null value.
-func (c *Context) Null() Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewNull(c.runtime.goCtx)
-	return Value{ctx: c, ptr: ptr}
-}
+	result, evalErr := c.evalFile(code, "<eval>")
+	if evalErr == nil {
+		result.free()
+	} else if errors.Is(evalErr, ErrClosed) {
+		return nil, evalErr
+	}
 
-// Bool creates a new JavaScript boolean.
-func (c *Context) Bool(v bool) Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewBool(c.runtime.goCtx, v)
-	return Value{ctx: c, ptr: ptr}
+	// Collect even if the deadline has passed; the work is bounded by the
+	// number of globals and runs no getters
+	inDeadline := c.runtime.inDeadline
+	c.runtime.inDeadline = true
+	defer func() { c.runtime.inDeadline = inDeadline }()
+	changed, err := globalsChanged.Call(this, snapshot)
+	if err != nil {
+		if evalErr != nil {
+			return nil, evalErr
+		}
+		return nil, err
+	}
+	defer changed.free()
+	globals, err = changed.MapToGo()
+	if evalErr != nil {
+		return globals, evalErr
+	}
+	return globals, err
 }
 
-// Int32 creates a new JavaScript integer from an int32.
-func (c *Context) Int32(v int32) Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewInt32(c.runtime.goCtx, v)
-	return Value{ctx: c, ptr: ptr}
+// EvalDeferJobs evaluates JavaScript code and returns its result without
+// running any of the jobs it queued: promise reactions, async function
+// continuations and queueMicrotask callbacks stay pending until the
+// embedder runs them with ExecutePendingJobs. A promise result is returned
+// as is, usually still pending. Eval and EvalFile behave the same way; this
+// method exists to make the choice explicit where it matters, as opposed to
+// EvalAsyncStats, which runs the jobs before returning.
+func (c *Context) EvalDeferJobs(code string) (Value, error) {
+	return c.EvalFile(code, "<eval>")
 }
 
-// Int64 creates a new JavaScript integer from an int64.
-func (c *Context) Int64(v int64) Value {
+// EvalBool evaluates JavaScript code and returns whether its result is
+// truthy, as !!result would be, for feature flags and guard expressions. It
+// only fails if the evaluation does.
+func (c *Context) EvalBool(code string) (bool, error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewInt64(c.runtime.goCtx, c.ctxPtr, v)
-	return Value{ctx: c, ptr: ptr}
-}
 
-// Float64 creates a new JavaScript number from a float64.
-func (c *Context) Float64(v float64) Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewFloat64(c.runtime.goCtx, v)
-	return Value{ctx: c, ptr: ptr}
+	result, err := c.EvalFile(code, "<eval>")
+	if err != nil {
+		return false, err
+	}
+	defer result.free()
+	return result.Bool(), nil
 }
 
-// String creates a new JavaScript string.
-func (c *Context) String(s string) Value {
+// EvalJSON evaluates JavaScript code and returns its result serialized by
+// JSON.stringify, ready to be written as a JSON response. It fails if the
+// evaluation does, and with ErrNotJSONSerializable if IsJSONSerializable
+// rejects the result, so that nothing is silently dropped or replaced:
+// undefined, a function, NaN or a Date anywhere in it gives an error, as do
+// BigInts and cyclic objects.
+func (c *Context) EvalJSON(code string) ([]byte, error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewString(c.runtime.goCtx, c.ctxPtr, s)
-	return Value{ctx: c, ptr: ptr}
-}
 
-// Object creates a new JavaScript object.
-func (c *Context) Object() Value {
+	result, err := c.EvalFile(code, "<eval>")
+	if err != nil {
+		return nil, err
+	}
+	defer result.free()
+	if !result.IsJSONSerializable() {
+		return nil, ErrNotJSONSerializable
+	}
+	out, err := c.runtime.bridge.JSONStringify(c.runtime.goCtx, c.ctxPtr, result.ptr)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// EvalExpression evaluates JavaScript code as a single expression rather
+// than as a script, by wrapping it in parentheses. This settles the cases
+// where a script reads differently: "{a: 1}" is an object literal instead
+// of a block containing a labeled statement, and "function () {}" is a
+// function expression instead of an invalid declaration. Trailing
+// semicolons are ignored. Code that is not an expression, such as a
+// declaration or several statements, fails with a SyntaxError without
+// running.
+func (c *Context) EvalExpression(code string) (Value, error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewObject(c.runtime.goCtx, c.ctxPtr)
-	return Value{ctx: c, ptr: ptr}
-}
 
-// Array creates a new JavaScript array.
-func (c *Context) Array() Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewArray(c.runtime.goCtx, c.ctxPtr)
-	return Value{ctx: c, ptr: ptr}
+	code = strings.TrimRight(code, " \t\r\n;")
+	// Code such as "1); f(); (2" closes the parentheses early and runs as
+	// several statements. It cannot also close brackets, so code that
+	// compiles inside both is a single expression.
+	bracketed, err := c.runtime.transformSource("<eval>", "["+code+"\n]")
+	if err != nil {
+		return Value{}, err
+	}
+	if err := c.compiles(bracketed); err != nil {
+		return Value{}, err
+	}
+	return c.EvalFile("("+code+"\n)", "<eval>")
 }
 
-// BigInt creates a new JavaScript BigInt from an int64.
-func (c *Context) BigInt(v int64) Value {
+// EvalFile evaluates JavaScript code with a specified filename for error messages.
+func (c *Context) EvalFile(code, filename string) (Value, error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewBigInt64(c.runtime.goCtx, c.ctxPtr, v)
-	return Value{ctx: c, ptr: ptr}
-}
 
-// Date creates a new JavaScript Date from Unix milliseconds.
-func (c *Context) Date(epochMs float64) Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewDate(c.runtime.goCtx, c.ctxPtr, epochMs)
-	return Value{ctx: c, ptr: ptr}
+	code, err := c.runtime.transformSource(filename, code)
+	if err != nil {
+		return Value{}, err
+	}
+	return c.evalFile(code, filename)
 }
 
-// ArrayBuffer creates a new JavaScript ArrayBuffer with the given data.
-func (c *Context) ArrayBuffer(data []byte) Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.NewArrayBuffer(c.runtime.goCtx, c.ctxPtr, data)
-	return Value{ctx: c, ptr: ptr}
+// evalFile evaluates a script without applying the source transform.
+// Caller must hold the mutex.
+func (c *Context) evalFile(code, filename string) (Value, error) {
+	return c.runtime.withDeadline(func() (Value, error) {
+		valPtr, err := c.runtime.bridge.Eval(c.runtime.goCtx, c.ctxPtr, code, filename, int32(EvalGlobal))
+		if err != nil {
+			return Value{}, err
+		}
+
+		return c.checkException(valPtr)
+	})
 }
 
-// ParseJSON parses a JSON string and returns the result.
-func (c *Context) ParseJSON(json string) (Value, error) {
+// EvalModule evaluates JavaScript code as an ES6 module.
+func (c *Context) EvalModule(code, filename string) (Value, error) {
 	c.runtime.lock()
 	defer c.runtime.unlock()
 
-	valPtr, err := c.runtime.bridge.JSONParse(c.runtime.goCtx, c.ctxPtr, json)
+	code, err := c.runtime.transformSource(filename, code)
 	if err != nil {
 		return Value{}, err
 	}
-	return c.checkException(valPtr)
+	return c.evalModule(code, filename)
+}
+
+// evalModule evaluates a module without applying the source transform.
+// Caller must hold the mutex.
+func (c *Context) evalModule(code, filename string) (Value, error) {
+	return c.runtime.withDeadline(func() (Value, error) {
+		valPtr, err := c.runtime.bridge.EvalModule(c.runtime.goCtx, c.ctxPtr, code, filename)
+		if err != nil {
+			return Value{}, err
+		}
+
+		return c.checkException(valPtr)
+	})
+}
+
+// CompileFunction compiles body as the body of a function taking the given
+// parameters and returns the resulting function value.
+//
+// It behaves like the JavaScript Function constructor: the function is created
+// in global scope and does not close over any local variables. The engine's
+// Function constructor compiles the parameters and body joined into a single
+// source string, so a body such as "}); sideEffect(); (function() {" would
+// close the function early and run code while being compiled. CompileFunction
+// rejects such input instead: each parameter name must be a plain identifier,
+// and the body must parse on its own as a complete function body before
+// anything is evaluated.
+func (c *Context) CompileFunction(paramNames []string, body string) (Value, error) {
+	for _, name := range paramNames {
+		if !isIdentifier(name) {
+			return Value{}, fmt.Errorf("invalid parameter name %q", name)
+		}
+	}
+
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	if err := c.checkFunctionBody(paramNames, body); err != nil {
+		return Value{}, err
+	}
+
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+
+	globalPtr, err := b.GetGlobalObject(goCtx, c.ctxPtr)
+	if err != nil {
+		return Value{}, err
+	}
+	defer b.FreeValue(goCtx, c.ctxPtr, globalPtr)
+
+	ctorPtr, err := b.GetProperty(goCtx, c.ctxPtr, globalPtr, "Function")
+	if err != nil {
+		return Value{}, err
+	}
+	defer b.FreeValue(goCtx, c.ctxPtr, ctorPtr)
+
+	sources := make([]string, 0, len(paramNames)+1)
+	sources = append(sources, paramNames...)
+	sources = append(sources, body)
+
+	argPtrs := make([]uint32, 0, len(sources))
+	defer func() {
+		for _, ptr := range argPtrs {
+			_ = b.FreeValue(goCtx, c.ctxPtr, ptr)
+		}
+	}()
+	for _, s := range sources {
+		ptr, err := b.NewString(goCtx, c.ctxPtr, s)
+		if err != nil {
+			return Value{}, err
+		}
+		argPtrs = append(argPtrs, ptr)
+	}
+
+	resultPtr, err := b.CallConstructor(goCtx, c.ctxPtr, ctorPtr, argPtrs)
+	if err != nil {
+		return Value{}, err
+	}
+	return c.checkException(resultPtr)
+}
+
+// errFunctionBody is returned by CompileFunction for a body that ends the
+// function early.
+var errFunctionBody = errors.New("function body must not close the function")
+
+// checkFunctionBody compiles, without running, body as the body of a function
+// wrapped in a labeled block, with a break to that label after it. A break
+// only compiles inside the function that declares its label, so if body
+// closes the function and opens another, the break ends up outside the label
+// and the source fails to compile. The label has a random name, so the body
+// cannot declare it again. Caller must hold the mutex.
+func (c *Context) checkFunctionBody(paramNames []string, body string) error {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	label := "body_" + hex.EncodeToString(nonce[:])
+	params := strings.Join(paramNames, ",")
+
+	labeled := fmt.Sprintf("(function anonymous(%s\n) {\n%s: {\n%s\n;break %[2]s;\n}\n})", params, label, body)
+	if c.compiles(labeled) == nil {
+		return nil
+	}
+	// Report syntax errors in the body itself as they are
+	if err := c.compiles(fmt.Sprintf("(function anonymous(%s\n) {\n%s\n})", params, body)); err != nil {
+		return err
+	}
+	return errFunctionBody
+}
+
+// compiles compiles code as a script without running it, and returns the
+// syntax error, if any. Caller must hold the mutex.
+func (c *Context) compiles(code string) error {
+	valPtr, err := c.runtime.bridge.Eval(c.runtime.goCtx, c.ctxPtr, code, "<function>", int32(evalCompileOnly))
+	if err != nil {
+		return err
+	}
+	val, err := c.checkException(valPtr)
+	if err != nil {
+		return err
+	}
+	val.free()
+	return nil
+}
+
+// isIdentifier reports whether s is a valid JavaScript identifier name.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || r == '$' || unicode.IsLetter(r):
+		case i > 0 && (unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// checkException checks if the value is an exception and returns a *JSError if so.
+// Caller must hold the mutex.
+func (c *Context) checkException(valPtr uint32) (Value, error) {
+	isExc, _ := c.runtime.bridge.IsException(c.runtime.goCtx, valPtr)
+	if isExc {
+		// The exception marker still takes a slot
+		_ = c.runtime.bridge.FreeValue(c.runtime.goCtx, c.ctxPtr, valPtr)
+		// Get the actual exception
+		excPtr, _ := c.runtime.bridge.GetException(c.runtime.goCtx, c.ctxPtr)
+		jsErr := c.newJSError(excPtr)
+		_ = c.runtime.bridge.FreeValue(c.runtime.goCtx, c.ctxPtr, excPtr)
+		return Value{}, jsErr
+	}
+	return c.value(valPtr), nil
+}
+
+// Global returns the global object.
+func (c *Context) Global() (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	valPtr, err := c.runtime.bridge.GetGlobalObject(c.runtime.goCtx, c.ctxPtr)
+	if err != nil {
+		return Value{}, err
+	}
+	return c.value(valPtr), nil
+}
+
+// ============================================================================
+// Value Creation
+// ============================================================================
+
+// Undefined returns the JavaScript undefined value.
+func (c *Context) Undefined() Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	return c.undefinedUnlocked()
+}
+
+// undefinedUnlocked returns undefined without acquiring lock (for callback use).
+func (c *Context) undefinedUnlocked() Value {
+	ptr, _ := c.runtime.bridge.NewUndefined(c.runtime.goCtx)
+	return c.value(ptr)
+}
+
+// Null returns the JavaScript null value.
+func (c *Context) Null() Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewNull(c.runtime.goCtx)
+	return c.value(ptr)
+}
+
+// Bool creates a new JavaScript boolean.
+func (c *Context) Bool(v bool) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewBool(c.runtime.goCtx, v)
+	return c.value(ptr)
+}
+
+// Int32 creates a new JavaScript integer from an int32.
+func (c *Context) Int32(v int32) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewInt32(c.runtime.goCtx, v)
+	return c.value(ptr)
+}
+
+// Int64 creates a new JavaScript integer from an int64.
+func (c *Context) Int64(v int64) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewInt64(c.runtime.goCtx, c.ctxPtr, v)
+	return c.value(ptr)
+}
+
+// Float64 creates a new JavaScript number from a float64.
+func (c *Context) Float64(v float64) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewFloat64(c.runtime.goCtx, v)
+	return c.value(ptr)
+}
+
+// String creates a new JavaScript string.
+func (c *Context) String(s string) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewString(c.runtime.goCtx, c.ctxPtr, s)
+	return c.value(ptr)
+}
+
+// Object creates a new JavaScript object.
+func (c *Context) Object() Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewObject(c.runtime.goCtx, c.ctxPtr)
+	return c.value(ptr)
+}
+
+// Array creates a new JavaScript array.
+func (c *Context) Array() Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewArray(c.runtime.goCtx, c.ctxPtr)
+	return c.value(ptr)
+}
+
+// StringArray creates a new JavaScript array holding the given strings.
+// It is equivalent to Marshal(ss) but builds the array in a single locked
+// pass without reflection.
+func (c *Context) StringArray(ss []string) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+
+	arrPtr, _ := b.NewArray(goCtx, c.ctxPtr)
+	for i, s := range ss {
+		strPtr, err := b.NewString(goCtx, c.ctxPtr, s)
+		if err != nil {
+			break
+		}
+		_ = b.SetPropertyUint32(goCtx, c.ctxPtr, arrPtr, uint32(i), strPtr)
+		_ = b.FreeValue(goCtx, c.ctxPtr, strPtr)
+	}
+	return c.value(arrPtr)
+}
+
+// ArrayFrom creates a new JavaScript array holding the given values, in a
+// single locked pass rather than one SetIdx call per element. The values
+// remain valid and owned by the caller.
+func (c *Context) ArrayFrom(vals []Value) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	b := c.runtime.bridge
+	goCtx := c.runtime.goCtx
+
+	arrPtr, _ := b.NewArray(goCtx, c.ctxPtr)
+	for i, val := range vals {
+		_ = b.SetPropertyUint32(goCtx, c.ctxPtr, arrPtr, uint32(i), val.ptr)
+	}
+	return c.value(arrPtr)
+}
+
+// BigInt creates a new JavaScript BigInt from an int64.
+func (c *Context) BigInt(v int64) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewBigInt64(c.runtime.goCtx, c.ctxPtr, v)
+	return c.value(ptr)
+}
+
+// Date creates a new JavaScript Date from Unix milliseconds.
+func (c *Context) Date(epochMs float64) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewDate(c.runtime.goCtx, c.ctxPtr, epochMs)
+	return c.value(ptr)
+}
+
+// ArrayBuffer creates a new JavaScript ArrayBuffer with the given data.
+func (c *Context) ArrayBuffer(data []byte) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.NewArrayBuffer(c.runtime.goCtx, c.ctxPtr, data)
+	return c.value(ptr)
+}
+
+// ParseJSON parses a JSON string and returns the result.
+func (c *Context) ParseJSON(json string) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	valPtr, err := c.runtime.bridge.JSONParse(c.runtime.goCtx, c.ctxPtr, json)
+	if err != nil {
+		return Value{}, err
+	}
+	return c.checkException(valPtr)
+}
+
+// ValueFromJSONBytes parses JSON like ParseJSON, but takes the document as
+// bytes, such as the output of encoding/json, and copies them into the
+// runtime directly instead of going through a string. The input is fully
+// validated; invalid JSON returns a SyntaxError.
+func (c *Context) ValueFromJSONBytes(b []byte) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	valPtr, err := c.runtime.bridge.JSONParseBytes(c.runtime.goCtx, c.ctxPtr, b)
+	if err != nil {
+		return Value{}, err
+	}
+	return c.checkException(valPtr)
 }
 
 // ============================================================================
 // Go Function Binding
 // ============================================================================
 
-// GoFunc is the signature for Go functions callable from JavaScript.
+// GoFunc is the signature for Go functions callable from JavaScript. The
+// returned Value is handed over to JavaScript and cannot be used
+// afterwards; returning a value that has been freed, or handed over by an
+// earlier call, throws a TypeError.
 type GoFunc func(ctx *Context, this Value, args []Value) Value
 
+// FunctionWithError creates a new JavaScript function that calls the given
+// Go function, like Function, for Go functions that can fail: when fn
+// returns a non-nil error, the call throws a JavaScript Error with the
+// error's message, which JavaScript can catch with try/catch, and the
+// returned Value is freed. Otherwise the Value is the call's result.
+func (c *Context) FunctionWithError(name string, fn func(ctx *Context, this Value, args []Value) (Value, error)) Value {
+	return c.Function(name, func(ctx *Context, this Value, args []Value) Value {
+		result, err := fn(ctx, this, args)
+		if err != nil {
+			result.Free()
+			return ctx.throwGoError(err)
+		}
+		return result
+	})
+}
+
+// throwGoError throws a JavaScript Error with the message of err, for
+// returning from a GoFunc. Caller must hold the mutex.
+func (c *Context) throwGoError(err error) Value {
+	exc, newErr := c.newGoError(err)
+	if newErr != nil {
+		return c.ThrowError(err.Error())
+	}
+	defer exc.free()
+	return c.Throw(exc)
+}
+
+// newGoError returns a JavaScript Error with the message of err. Caller
+// must hold the mutex.
+func (c *Context) newGoError(err error) (Value, error) {
+	newError, helperErr := c.helper("newError", newErrorJS)
+	if helperErr != nil {
+		return Value{}, helperErr
+	}
+	msg := c.String(err.Error())
+	defer msg.free()
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	return newError.Call(this, msg)
+}
+
+// errNewFunction is returned when a Go function cannot be wrapped as a
+// JavaScript function.
+var errNewFunction = errors.New("failed to create callback function")
+
 // Function creates a new JavaScript function that calls the given Go function.
 func (c *Context) Function(name string, fn GoFunc) Value {
+	val, _ := c.newFunction(name, fn)
+	return val
+}
+
+// newFunction creates a Go-backed function like Function and also returns
+// the callback ID, so short-lived functions can be unregistered when done.
+// The ID is 0 if the function could not be created.
+func (c *Context) newFunction(name string, fn GoFunc) (Value, uint32) {
 	// Create wrapper that handles the bridge callback
 	// Note: This callback runs while the mutex is already held by Eval,
 	// so we must use unlocked methods here.
 	bridgeFn := func(ctxPtr uint32, argPtrs []uint32) uint32 {
 		args := make([]Value, len(argPtrs))
 		for i, ptr := range argPtrs {
-			args[i] = Value{ctx: c, ptr: ptr}
+			args[i] = c.value(ptr)
 		}
 
-		result := fn(c, c.undefinedUnlocked(), args)
+		this := c.undefinedUnlocked()
+		result := fn(c, this, args)
+		if result.ptr != this.ptr {
+			this.free()
+		}
+		if result.freed() {
+			// Its slot may already hold another value
+			result = c.ThrowTypeError("Go function returned a freed value")
+		}
+		// The engine takes over the result's reference
+		c.runtime.handOver(result)
 		return result.ptr
 	}
 
@@ -427,395 +1481,1445 @@ func (c *Context) Function(name string, fn GoFunc) Value {
 	c.runtime.lock()
 	defer c.runtime.unlock()
 
-	ptr, err := c.runtime.bridge.NewCFunction(c.runtime.goCtx, c.ctxPtr, funcID, name, -1)
+	ptr, err := c.runtime.bridge.NewCFunction(c.runtime.goCtx, c.ctxPtr, funcID, name, -1)
+	if err != nil {
+		c.runtime.bridge.UnregisterGoFunc(funcID)
+		return c.undefinedUnlocked(), 0
+	}
+
+	return c.value(ptr), funcID
+}
+
+// CallerLocation reports where in JavaScript the running Go function was
+// called from, for use inside a GoFunc: the file name and line of the
+// innermost JavaScript frame below it, skipping native functions such as
+// Array.prototype.forEach that may sit in between. ok is false when no Go
+// function is running, or when no frame below it has a location.
+func (c *Context) CallerLocation() (file string, line int, ok bool) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	ptr, err := c.runtime.bridge.GetStackTrace(c.runtime.goCtx, c.ctxPtr)
+	if err != nil {
+		return "", 0, false
+	}
+	stack, err := c.checkException(ptr)
+	if err != nil {
+		return "", 0, false
+	}
+	defer stack.freeUnlocked()
+	if !stack.IsString() {
+		return "", 0, false
+	}
+	return callerLocation(stack.String())
+}
+
+// callerLocation finds CallerLocation's result in a stack trace, whose
+// first frame is the Go function that called it, which is native. A frame
+// with a location ends in "(file:line:column)", or "(file:line)" for code
+// without column numbers.
+func callerLocation(stack string) (file string, line int, ok bool) {
+	frames := strings.Split(stack, "\n")
+	if !strings.HasSuffix(frames[0], "(native)") {
+		return "", 0, false
+	}
+	for _, frame := range frames[1:] {
+		i := strings.IndexByte(frame, '(')
+		loc, found := strings.CutSuffix(frame, ")")
+		if i < 0 || !found {
+			continue
+		}
+		file, line, ok = cutLineNumber(loc[i+1:])
+		if !ok {
+			continue
+		}
+		if f, n, hasColumn := cutLineNumber(file); hasColumn {
+			file, line = f, n
+		}
+		return file, line, true
+	}
+	return "", 0, false
+}
+
+// cutLineNumber splits the number after the last colon off loc.
+func cutLineNumber(loc string) (before string, n int, ok bool) {
+	i := strings.LastIndexByte(loc, ':')
+	if i < 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(loc[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return loc[:i], n, true
+}
+
+// SetGlobal sets a value on the global object.
+func (c *Context) SetGlobal(name string, val Value) error {
+	if err := checkFreed(val); err != nil {
+		return err
+	}
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	globalPtr, err := c.runtime.bridge.GetGlobalObject(c.runtime.goCtx, c.ctxPtr)
+	if err != nil {
+		return err
+	}
+	return c.runtime.bridge.SetProperty(c.runtime.goCtx, c.ctxPtr, globalPtr, name, val.ptr)
+}
+
+// GetGlobal gets a value from the global object.
+func (c *Context) GetGlobal(name string) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	globalPtr, err := c.runtime.bridge.GetGlobalObject(c.runtime.goCtx, c.ctxPtr)
+	if err != nil {
+		return Value{}, err
+	}
+	valPtr, err := c.runtime.bridge.GetProperty(c.runtime.goCtx, c.ctxPtr, globalPtr, name)
+	if err != nil {
+		return Value{}, err
+	}
+	return c.value(valPtr), nil
+}
+
+// Throw throws v as a JavaScript exception, such as an error built from a
+// class made with RegisterErrorClass. Like ThrowError, it is meant to be
+// returned from a GoFunc.
+func (c *Context) Throw(v Value) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.Throw(c.runtime.goCtx, c.ctxPtr, v.ptr)
+	return c.value(ptr)
+}
+
+// RegisterErrorClass defines a global error class called name that extends
+// the global error class called parent, Error if parent is empty, as
+// "class name extends parent {}" would, and returns its constructor. This
+// lets Go functions throw typed domain errors, built with New and thrown
+// with Throw, that JavaScript can tell apart with instanceof. Instances
+// report name as their name, and the constructor takes the same message and
+// options arguments as Error. parent may be a class registered earlier.
+func (c *Context) RegisterErrorClass(name, parent string) (Value, error) {
+	if name == "" {
+		return Value{}, errors.New("empty error class name")
+	}
+	if parent == "" {
+		parent = "Error"
+	}
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	registerErrorClass, err := c.helper("registerErrorClass", registerErrorClassJS)
+	if err != nil {
+		return Value{}, err
+	}
+	nameVal := c.String(name)
+	defer nameVal.free()
+	parentVal := c.String(parent)
+	defer parentVal.free()
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	return registerErrorClass.Call(this, nameVal, parentVal)
+}
+
+// ThrowError throws a JavaScript error with the given message.
+func (c *Context) ThrowError(msg string) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.ThrowError(c.runtime.goCtx, c.ctxPtr, msg)
+	return c.value(ptr)
+}
+
+// ThrowTypeError throws a JavaScript TypeError with the given message.
+func (c *Context) ThrowTypeError(msg string) Value {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	ptr, _ := c.runtime.bridge.ThrowTypeError(c.runtime.goCtx, c.ctxPtr, msg)
+	return c.value(ptr)
+}
+
+// ============================================================================
+// Value
+// ============================================================================
+
+// Value represents a JavaScript value.
+//
+// A Value returned by the API holds a reference to the JavaScript value,
+// owned by the caller, which keeps it alive. Free releases it; until then
+// the value cannot be collected, and it keeps one of the runtime's 65536
+// value slots. Copies of a Value share the reference, so only one of them
+// needs to be freed. Passing a Value to SetGlobal, Set, Call or another
+// method does not take the reference over: the engine takes its own where
+// it keeps the value, so the caller may free the Value afterwards, or keep
+// using it. The exception is the Value a GoFunc returns, which is handed
+// over to JavaScript, and must not be used afterwards.
+type Value struct {
+	ctx *Context
+	ptr uint32
+	ref *valueRef // shared by copies, nil for internal aliases
+}
+
+// ContextID returns an identifier for the context the value belongs to, for
+// diagnosing values used with the wrong context. Values from the same
+// context report the same ID, and contexts open at the same time have
+// different IDs, though the ID of a closed context may be reused. The zero
+// Value reports 0.
+func (v Value) ContextID() uint32 {
+	if v.ctx == nil {
+		return 0
+	}
+	return v.ctx.ctxPtr
+}
+
+// IsUndefined returns true if the value is undefined.
+func (v Value) IsUndefined() bool {
+	if v.ctx == nil || v.freed() {
+		return true
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsUndefined(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsNull returns true if the value is null.
+func (v Value) IsNull() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsNull(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsNullish returns true if the value is null or undefined, the values the
+// ?? operator replaces.
+func (v Value) IsNullish() bool {
+	if v.ctx == nil || v.freed() {
+		return true
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	if null, _ := v.ctx.runtime.bridge.IsNull(v.ctx.runtime.goCtx, v.ptr); null {
+		return true
+	}
+	result, _ := v.ctx.runtime.bridge.IsUndefined(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsBool returns true if the value is a boolean.
+func (v Value) IsBool() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsBool(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsNumber returns true if the value is a number.
+func (v Value) IsNumber() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsNumber(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsNaN reports whether the value is the number NaN, like Number.isNaN.
+// Values that are not numbers, including strings such as "abc", are not NaN.
+func (v Value) IsNaN() bool {
+	f, ok := v.asNumber()
+	return ok && math.IsNaN(f)
+}
+
+// IsFinite reports whether the value is a finite number, like
+// Number.isFinite. Values that are not numbers are not finite.
+func (v Value) IsFinite() bool {
+	f, ok := v.asNumber()
+	return ok && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// asNumber returns the value as a float64 if it is a number.
+func (v Value) asNumber() (float64, bool) {
+	if v.ctx == nil || v.freed() {
+		return 0, false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	if !v.IsNumber() {
+		return 0, false
+	}
+	f, err := v.ctx.runtime.bridge.ToFloat64(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	return f, err == nil
+}
+
+// IsString returns true if the value is a string.
+func (v Value) IsString() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsString(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsSymbol returns true if the value is a symbol.
+func (v Value) IsSymbol() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsSymbol(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsObject returns true if the value is an object.
+func (v Value) IsObject() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsObject(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsArray returns true if the value is an array.
+func (v Value) IsArray() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsArray(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsFunction returns true if the value is a function.
+func (v Value) IsFunction() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsFunction(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	return result
+}
+
+// IsError returns true if the value is an Error object.
+func (v Value) IsError() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsError(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsBigInt returns true if the value is a BigInt.
+func (v Value) IsBigInt() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsBigInt(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsDate returns true if the value is a Date.
+func (v Value) IsDate() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsDate(v.ctx.runtime.goCtx, v.ptr)
+	return result
+}
+
+// IsPromise returns true if the value is a Promise (has a 'then' method).
+func (v Value) IsPromise() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.IsPromise(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	return result
+}
+
+// IsPlainObject returns true if the value is a plain object, such as one
+// created with {} or Object.create(null): an object whose prototype is
+// Object.prototype or null. Arrays, functions, Dates and class instances
+// are not plain objects.
+func (v Value) IsPlainObject() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	b := v.ctx.runtime.bridge
+	goCtx := v.ctx.runtime.goCtx
+	if isObj, _ := b.IsObject(goCtx, v.ptr); !isObj {
+		return false
+	}
+	if isArr, _ := b.IsArray(goCtx, v.ptr); isArr {
+		return false
+	}
+	if isFunc, _ := b.IsFunction(goCtx, v.ctx.ctxPtr, v.ptr); isFunc {
+		return false
+	}
+
+	isPlain, err := v.ctx.helper("isPlainObject", isPlainObjectJS)
+	if err != nil {
+		return false
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := isPlain.Call(this, v)
+	if err != nil {
+		return false
+	}
+	defer result.free()
+	return result.Bool()
+}
+
+// ============================================================================
+// Value Conversion
+// ============================================================================
+
+// String returns the string representation of the value.
+func (v Value) String() string {
+	if v.ctx == nil || v.freed() {
+		return "undefined"
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	s, _ := v.ctx.runtime.bridge.ToString(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	return s
+}
+
+// StringBytes returns the value converted to a string, like String, as a
+// freshly allocated UTF-8 byte slice. The bytes are copied straight out of
+// the runtime, without building a Go string first, and unlike String the
+// result is never truncated. A conversion that throws, such as for a
+// Symbol, returns the exception as an error.
+func (v Value) StringBytes() ([]byte, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	data, err := v.ctx.runtime.bridge.ToStringBytes(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	if err != nil || data != nil {
+		return data, err
+	}
+	excPtr, err := v.ctx.runtime.bridge.GetException(v.ctx.runtime.goCtx, v.ctx.ctxPtr)
+	if err != nil {
+		return nil, err
+	}
+	defer v.ctx.runtime.bridge.FreeValue(v.ctx.runtime.goCtx, v.ctx.ctxPtr, excPtr)
+	return nil, v.ctx.newJSError(excPtr)
+}
+
+// Bool returns the value as a boolean.
+func (v Value) Bool() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	b, _ := v.ctx.runtime.bridge.ToBool(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	return b
+}
+
+// Int32 returns the value as an int32.
+func (v Value) Int32() (int32, error) {
+	if err := v.usable(); err != nil {
+		return 0, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.bridge.ToInt32(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+}
+
+// Int64 returns the value as an int64.
+func (v Value) Int64() (int64, error) {
+	if err := v.usable(); err != nil {
+		return 0, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.bridge.ToInt64(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+}
+
+// Int32Clamped returns the value as an integer limited to [min, max], for
+// range checks that should saturate rather than fail. The value is
+// converted as Float64 does and truncated towards zero, so, unlike Int32,
+// numbers outside the int32 range clamp instead of wrapping around. NaN,
+// and values that cannot be converted, give min.
+func (v Value) Int32Clamped(min, max int32) int32 {
+	f, err := v.Float64()
+	if err != nil || math.IsNaN(f) {
+		return min
+	}
+	f = math.Trunc(f)
+	if f < float64(min) {
+		return min
+	}
+	if f > float64(max) {
+		return max
+	}
+	return int32(f)
+}
+
+// Float64 returns the value as a float64.
+func (v Value) Float64() (float64, error) {
+	if err := v.usable(); err != nil {
+		return 0, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.bridge.ToFloat64(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+}
+
+// ToNumber converts the value to a number as JavaScript's unary plus does,
+// calling an object's Symbol.toPrimitive or valueOf method if it has one.
+// Unlike Float64, an exception thrown during the conversion, including the
+// TypeError for BigInts and symbols, is returned as a *JSError.
+func (v Value) ToNumber() (float64, error) {
+	if err := v.usable(); err != nil {
+		return 0, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	toNumber, err := v.ctx.helper("toNumber", toNumberJS)
+	if err != nil {
+		return 0, err
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := toNumber.Call(this, v)
+	if err != nil {
+		return 0, err
+	}
+	defer result.free()
+	return result.Float64()
+}
+
+// Duration interprets a number as milliseconds, as setTimeout and most
+// JavaScript APIs do, and returns it as a time.Duration rounded to the
+// nearest nanosecond. Values that are not numbers, NaN, infinities and
+// durations out of time.Duration's range give an error.
+func (v Value) Duration() (time.Duration, error) {
+	if err := v.usable(); err != nil {
+		return 0, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	if !v.IsNumber() {
+		return 0, fmt.Errorf("cannot convert JavaScript %s to a duration", v.Typeof())
+	}
+	ms, err := v.ctx.runtime.bridge.ToFloat64(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	if err != nil {
+		return 0, err
+	}
+	ns := math.Round(ms * float64(time.Millisecond))
+	if math.IsNaN(ns) || ns < math.MinInt64 || ns >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v milliseconds is not a valid duration", ms)
+	}
+	return time.Duration(ns), nil
+}
+
+// DecimalString returns a number formatted exactly as JavaScript's
+// Number.prototype.toString formats it: the shortest decimal that round
+// trips, in exponent form from 1e21 up and below 1e-6, such as
+// "0.30000000000000004" or "1e+21". Go's strconv formats many numbers
+// differently. Values that are not numbers give an error.
+func (v Value) DecimalString() (string, error) {
+	if err := v.usable(); err != nil {
+		return "", err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	if !v.IsNumber() {
+		return "", errors.New("value is not a number")
+	}
+	return v.String(), nil
+}
+
+// BigInt returns the value as an int64 (for BigInt values).
+func (v Value) BigInt() (int64, error) {
+	if err := v.usable(); err != nil {
+		return 0, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.bridge.ToBigInt64(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+}
+
+// BigIntString returns a BigInt value in decimal, with no "n" suffix. Unlike
+// BigInt it is exact for values of any size.
+func (v Value) BigIntString() (string, error) {
+	if err := v.usable(); err != nil {
+		return "", err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	if !v.IsBigInt() {
+		return "", errors.New("value is not a BigInt")
+	}
+	return v.String(), nil
+}
+
+// BigIntBig returns a BigInt value as a *big.Int, exactly.
+func (v Value) BigIntBig() (*big.Int, error) {
+	s, err := v.BigIntString()
+	if err != nil {
+		return nil, err
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid BigInt %q", s)
+	}
+	return n, nil
+}
+
+// Number returns the value as a number in both integer and floating-point
+// form. isInt reports whether the number is integral and fits in an int64,
+// in which case i holds it exactly; otherwise i is 0. JavaScript has a
+// single number type, so 42 and 42.0 are the same value and both report
+// isInt. Non-number values are converted as by the unary + operator.
+func (v Value) Number() (isInt bool, i int64, f float64) {
+	f, err := v.Float64()
+	if err != nil {
+		return false, 0, math.NaN()
+	}
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return true, int64(f), f
+	}
+	return false, 0, f
+}
+
+// JSONStringify returns the JSON representation of the value.
+func (v Value) JSONStringify() (string, error) {
+	if err := v.usable(); err != nil {
+		return "", err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.bridge.JSONStringify(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+}
+
+// IsJSONSerializable reports whether JSON.stringify would represent the
+// value exactly, so that parsing its JSON gives back an equal value. That
+// is the case for null, booleans, strings, finite numbers, and arrays and
+// plain objects made only of those. It is false if the value contains
+// something JSON.stringify would drop, replace with null, or reject: a
+// function, symbol, undefined, array hole, NaN or infinity, BigInt,
+// symbol-keyed property, cycle, or an object with another prototype, such
+// as a Date or Map.
+func (v Value) IsJSONSerializable() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	isJSONSerializable, err := v.ctx.helper("isJSONSerializable", isJSONSerializableJS)
+	if err != nil {
+		return false
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := isJSONSerializable.Call(this, v)
+	if err != nil {
+		return false
+	}
+	defer result.free()
+	return result.Bool()
+}
+
+// ErrNotJSONSerializable is returned by Hash and EvalJSON for a value that
+// IsJSONSerializable rejects.
+var ErrNotJSONSerializable = errors.New("value is not JSON-serializable")
+
+// Hash returns a hash of the value for use as a cache or memoization key.
+// It is the 64-bit FNV-1a hash of the value's JSON with object keys sorted,
+// so equal values hash equally whatever order their properties were added
+// in, in any runtime or process. It only works for values that
+// IsJSONSerializable accepts, so that no two different values share a JSON
+// form: any other value, including one with a function, undefined, Date or
+// NaN anywhere inside it, gives ErrNotJSONSerializable.
+func (v Value) Hash() (uint64, error) {
+	if err := v.usable(); err != nil {
+		return 0, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	if !v.IsJSONSerializable() {
+		return 0, ErrNotJSONSerializable
+	}
+	canonicalJSON, err := v.ctx.helper("canonicalJSON", canonicalJSONJS)
 	if err != nil {
-		c.runtime.bridge.UnregisterGoFunc(funcID)
-		return c.undefinedUnlocked()
+		return 0, err
 	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := canonicalJSON.Call(this, v)
+	if err != nil {
+		return 0, err
+	}
+	defer result.free()
 
-	return Value{ctx: c, ptr: ptr}
+	h := fnv.New64a()
+	io.WriteString(h, result.String())
+	return h.Sum64(), nil
 }
 
-// SetGlobal sets a value on the global object.
-func (c *Context) SetGlobal(name string, val Value) error {
-	c.runtime.lock()
-	defer c.runtime.unlock()
+// ErrDetachedBuffer is returned when reading the data of an ArrayBuffer, or
+// of a typed array viewing one, that has been detached, for example by its
+// transfer method. A detached buffer has no data and a length of 0.
+var ErrDetachedBuffer = errors.New("ArrayBuffer is detached")
 
-	globalPtr, err := c.runtime.bridge.GetGlobalObject(c.runtime.goCtx, c.ctxPtr)
-	if err != nil {
-		return err
+// Bytes returns the value as bytes (for ArrayBuffer values). It returns
+// ErrDetachedBuffer for a detached ArrayBuffer.
+func (v Value) Bytes() ([]byte, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
 	}
-	return c.runtime.bridge.SetProperty(c.runtime.goCtx, c.ctxPtr, globalPtr, name, val.ptr)
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	data, err := v.ctx.runtime.bridge.GetArrayBuffer(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	if err != nil && v.IsDetachedBuffer() {
+		return nil, ErrDetachedBuffer
+	}
+	return data, err
 }
 
-// GetGlobal gets a value from the global object.
-func (c *Context) GetGlobal(name string) (Value, error) {
-	c.runtime.lock()
-	defer c.runtime.unlock()
+// IsDetachedBuffer reports whether the value is an ArrayBuffer that has been
+// detached, which happens when its contents are moved to another buffer by
+// transfer or transferToFixedLength. It is false for any other value.
+func (v Value) IsDetachedBuffer() bool {
+	if v.ctx == nil || v.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
 
-	globalPtr, err := c.runtime.bridge.GetGlobalObject(c.runtime.goCtx, c.ctxPtr)
+	isDetached, err := v.ctx.helper("isDetached", isDetachedJS)
 	if err != nil {
-		return Value{}, err
+		return false
 	}
-	valPtr, err := c.runtime.bridge.GetProperty(c.runtime.goCtx, c.ctxPtr, globalPtr, name)
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := isDetached.Call(this, v)
 	if err != nil {
-		return Value{}, err
+		return false
 	}
-	return Value{ctx: c, ptr: valPtr}, nil
+	defer result.free()
+	return result.Bool()
 }
 
-// ThrowError throws a JavaScript error with the given message.
-func (c *Context) ThrowError(msg string) Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.ThrowError(c.runtime.goCtx, c.ctxPtr, msg)
-	return Value{ctx: c, ptr: ptr}
-}
+// Float32Slice returns the elements of a Float32Array. The elements are
+// read straight from the array's backing buffer, which is much faster than
+// fetching them one by one.
+func (v Value) Float32Slice() ([]float32, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
 
-// ThrowTypeError throws a JavaScript TypeError with the given message.
-func (c *Context) ThrowTypeError(msg string) Value {
-	c.runtime.lock()
-	defer c.runtime.unlock()
-	ptr, _ := c.runtime.bridge.ThrowTypeError(c.runtime.goCtx, c.ctxPtr, msg)
-	return Value{ctx: c, ptr: ptr}
+	data, n, err := v.typedArrayData("Float32Array", 4)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return out, nil
 }
 
-// ============================================================================
-// Value
-// ============================================================================
+// typedArrayData returns a view of the bytes of a typed array of the given
+// kind and element size, along with its length. The view aliases WASM memory
+// and is only valid until the next call into the runtime. Caller must hold
+// the mutex.
+func (v Value) typedArrayData(kind string, elemSize int) ([]byte, int, error) {
+	typedArrayInfo, err := v.ctx.helper("typedArrayInfo", typedArrayInfoJS)
+	if err != nil {
+		return nil, 0, err
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	info, err := typedArrayInfo.Call(this, v)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer info.free()
+	if info.IsNull() {
+		return nil, 0, fmt.Errorf("value is not a %s", kind)
+	}
 
-// Value represents a JavaScript value.
-type Value struct {
-	ctx *Context
-	ptr uint32
+	name, _ := info.GetIdx(0)
+	defer name.free()
+	if name.String() != kind {
+		return nil, 0, fmt.Errorf("value is a %s, not a %s", name.String(), kind)
+	}
+	buffer, _ := info.GetIdx(1)
+	defer buffer.free()
+	offsetVal, _ := info.GetIdx(2)
+	defer offsetVal.free()
+	lengthVal, _ := info.GetIdx(3)
+	defer lengthVal.free()
+	offset, _ := offsetVal.Int64()
+	length, _ := lengthVal.Int64()
+
+	b := v.ctx.runtime.bridge
+	ptr, size, err := b.ArrayBufferData(v.ctx.runtime.goCtx, v.ctx.ctxPtr, buffer.ptr)
+	if err != nil {
+		if buffer.IsDetachedBuffer() {
+			return nil, 0, ErrDetachedBuffer
+		}
+		return nil, 0, err
+	}
+	byteLen := uint32(length) * uint32(elemSize)
+	if uint32(offset)+byteLen > size {
+		return nil, 0, errors.New("typed array is out of bounds of its buffer")
+	}
+	data, ok := b.Memory().Read(ptr+uint32(offset), byteLen)
+	if !ok {
+		return nil, 0, errors.New("failed to read typed array data")
+	}
+	return data, int(length), nil
 }
 
-// IsUndefined returns true if the value is undefined.
-func (v Value) IsUndefined() bool {
-	if v.ctx == nil {
-		return true
+// Typeof returns the JavaScript typeof string for the value.
+func (v Value) Typeof() string {
+	if v.ctx == nil || v.freed() {
+		return "undefined"
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsUndefined(v.ctx.runtime.goCtx, v.ptr)
-	return result
+	s, _ := v.ctx.runtime.bridge.Typeof(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	return s
 }
 
-// IsNull returns true if the value is null.
-func (v Value) IsNull() bool {
-	if v.ctx == nil {
-		return false
+// ============================================================================
+// Object Operations
+// ============================================================================
+
+// Get returns a property value by name.
+func (v Value) Get(prop string) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsNull(v.ctx.runtime.goCtx, v.ptr)
-	return result
+	valPtr, err := v.ctx.runtime.bridge.GetProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, prop)
+	if err != nil {
+		return Value{}, err
+	}
+	return v.ctx.value(valPtr), nil
 }
 
-// IsBool returns true if the value is a boolean.
-func (v Value) IsBool() bool {
-	if v.ctx == nil {
-		return false
+// Set sets a property value by name.
+func (v Value) Set(prop string, val Value) error {
+	if err := v.usable(); err != nil {
+		return err
+	}
+	if err := checkFreed(val); err != nil {
+		return err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsBool(v.ctx.runtime.goCtx, v.ptr)
-	return result
+	return v.ctx.runtime.bridge.SetProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, prop, val.ptr)
 }
 
-// IsNumber returns true if the value is a number.
-func (v Value) IsNumber() bool {
-	if v.ctx == nil {
-		return false
+// GetKey returns the property whose key is given as a value, as obj[key]
+// does in JavaScript, so that it can be a symbol, including well-known
+// symbols such as Symbol.toStringTag. Other keys are converted to property
+// keys as JavaScript converts them.
+func (v Value) GetKey(key Value) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsNumber(v.ctx.runtime.goCtx, v.ptr)
-	return result
-}
 
-// IsString returns true if the value is a string.
-func (v Value) IsString() bool {
-	if v.ctx == nil {
-		return false
+	getKey, err := v.ctx.helper("getKey", getKeyJS)
+	if err != nil {
+		return Value{}, err
 	}
-	v.ctx.runtime.lock()
-	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsString(v.ctx.runtime.goCtx, v.ptr)
-	return result
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	return getKey.Call(this, v, key)
 }
 
-// IsSymbol returns true if the value is a symbol.
-func (v Value) IsSymbol() bool {
-	if v.ctx == nil {
-		return false
+// SetKey sets the property whose key is given as a value, as obj[key] = val
+// does in JavaScript. Assignments that fail, for example to a frozen
+// object, return an error.
+func (v Value) SetKey(key, val Value) error {
+	if err := v.usable(); err != nil {
+		return err
+	}
+	if err := checkFreed(key, val); err != nil {
+		return err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsSymbol(v.ctx.runtime.goCtx, v.ptr)
-	return result
-}
 
-// IsObject returns true if the value is an object.
-func (v Value) IsObject() bool {
-	if v.ctx == nil {
-		return false
+	setKey, err := v.ctx.helper("setKey", setKeyJS)
+	if err != nil {
+		return err
 	}
-	v.ctx.runtime.lock()
-	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsObject(v.ctx.runtime.goCtx, v.ptr)
-	return result
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := setKey.Call(this, v, key, val)
+	if err != nil {
+		return err
+	}
+	result.free()
+	return nil
 }
 
-// IsArray returns true if the value is an array.
-func (v Value) IsArray() bool {
-	if v.ctx == nil {
-		return false
+// SetToStringTag sets the object's Symbol.toStringTag property, which names
+// it in Object.prototype.toString, so that the object reports itself as
+// "[object tag]". Like the tags of built-in objects, the property is
+// read-only and not enumerable, but can be redefined.
+func (v Value) SetToStringTag(tag string) error {
+	if err := v.usable(); err != nil {
+		return err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsArray(v.ctx.runtime.goCtx, v.ptr)
-	return result
-}
 
-// IsFunction returns true if the value is a function.
-func (v Value) IsFunction() bool {
-	if v.ctx == nil {
-		return false
+	if !v.IsObject() {
+		return errors.New("value is not an object")
+	}
+	setToStringTag, err := v.ctx.helper("setToStringTag", setToStringTagJS)
+	if err != nil {
+		return err
+	}
+	tagVal := v.ctx.String(tag)
+	defer tagVal.free()
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := setToStringTag.Call(this, v, tagVal)
+	if err != nil {
+		return err
+	}
+	result.free()
+	return nil
+}
+
+// DefineAccessor defines an accessor property on the object whose getter and
+// setter are Go functions, for computed or reactive properties: reading the
+// property calls get and returns its result, and assigning to it calls set
+// with the new value as its only argument. Either may be nil, leaving the
+// property without a getter, so that it reads as undefined, or without a
+// setter, so that assignments are ignored, or throw in strict mode code.
+// The property is enumerable and configurable, and replaces any existing
+// property of that name.
+func (v Value) DefineAccessor(name string, get, set GoFunc) error {
+	if err := v.usable(); err != nil {
+		return err
+	}
+	if get == nil && set == nil {
+		return errors.New("accessor needs a getter or a setter")
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsFunction(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
-	return result
+
+	if !v.IsObject() {
+		return errors.New("value is not an object")
+	}
+
+	var funcIDs []uint32
+	fail := func(err error) error {
+		for _, id := range funcIDs {
+			v.ctx.runtime.bridge.UnregisterGoFunc(id)
+		}
+		return err
+	}
+	accessor := func(label string, fn GoFunc) (Value, error) {
+		if fn == nil {
+			return v.ctx.undefinedUnlocked(), nil
+		}
+		val, funcID := v.ctx.newFunction(label+" "+name, fn)
+		if funcID == 0 {
+			return Value{}, errNewFunction
+		}
+		funcIDs = append(funcIDs, funcID)
+		return val, nil
+	}
+	getter, err := accessor("get", get)
+	if err != nil {
+		return fail(err)
+	}
+	defer getter.free()
+	setter, err := accessor("set", set)
+	if err != nil {
+		return fail(err)
+	}
+	defer setter.free()
+
+	defineAccessor, err := v.ctx.helper("defineAccessor", defineAccessorJS)
+	if err != nil {
+		return fail(err)
+	}
+	nameVal := v.ctx.String(name)
+	defer nameVal.free()
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := defineAccessor.Call(this, v, nameVal, getter, setter)
+	if err != nil {
+		return fail(err)
+	}
+	result.free()
+	return nil
 }
 
-// IsError returns true if the value is an Error object.
-func (v Value) IsError() bool {
-	if v.ctx == nil {
-		return false
+// GetOrCreateObject returns the object stored in the property, first
+// setting the property to a new empty object if it is undefined or null,
+// which makes it easy to build nested structures step by step. It is an
+// error for the property to hold any other non-object value.
+func (v Value) GetOrCreateObject(prop string) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsError(v.ctx.runtime.goCtx, v.ptr)
-	return result
+
+	existing, err := v.Get(prop)
+	if err != nil {
+		return Value{}, err
+	}
+	if existing.IsObject() {
+		return existing, nil
+	}
+	if !existing.IsUndefined() && !existing.IsNull() {
+		typ := existing.Typeof()
+		existing.free()
+		return Value{}, fmt.Errorf("property %q holds a %s, not an object", prop, typ)
+	}
+
+	obj := v.ctx.Object()
+	if err := v.Set(prop, obj); err != nil {
+		obj.free()
+		return Value{}, err
+	}
+	return obj, nil
 }
 
-// IsBigInt returns true if the value is a BigInt.
-func (v Value) IsBigInt() bool {
-	if v.ctx == nil {
-		return false
+// SetFunctions creates a Go-backed function for each entry of fns, as
+// Context.Function does, and sets it on the object under its name, all
+// under a single lock so no script sees the object half-built. Functions
+// are set in the order of their names. If one cannot be created or set,
+// those already set are deleted again and their callbacks released.
+func (v Value) SetFunctions(fns map[string]GoFunc) error {
+	if err := v.usable(); err != nil {
+		return err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsBigInt(v.ctx.runtime.goCtx, v.ptr)
-	return result
+
+	if !v.IsObject() {
+		return errors.New("value is not an object")
+	}
+
+	var set []string
+	var funcIDs []uint32
+	fail := func(err error) error {
+		for _, name := range set {
+			_ = v.Delete(name)
+		}
+		for _, id := range funcIDs {
+			v.ctx.runtime.bridge.UnregisterGoFunc(id)
+		}
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(fns)) {
+		fn, funcID := v.ctx.newFunction(name, fns[name])
+		if funcID == 0 {
+			return fail(fmt.Errorf("failed to create function %q", name))
+		}
+		funcIDs = append(funcIDs, funcID)
+		err := v.ctx.runtime.bridge.SetProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, name, fn.ptr)
+		fn.free()
+		if err != nil {
+			return fail(fmt.Errorf("failed to set function %q: %w", name, err))
+		}
+		set = append(set, name)
+	}
+	return nil
 }
 
-// IsDate returns true if the value is a Date.
-func (v Value) IsDate() bool {
-	if v.ctx == nil {
+// Has returns true if the object has the given property.
+func (v Value) Has(prop string) bool {
+	if v.ctx == nil || v.freed() {
 		return false
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsDate(v.ctx.runtime.goCtx, v.ptr)
+	result, _ := v.ctx.runtime.bridge.HasProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, prop)
 	return result
 }
 
-// IsPromise returns true if the value is a Promise (has a 'then' method).
-func (v Value) IsPromise() bool {
-	if v.ctx == nil {
+// In reports whether prop in v is true in JavaScript: whether the object
+// has the property, either its own or inherited, with array indexes and
+// proxy has traps handled as the in operator handles them. It is false for
+// values that are not objects, where the operator would throw, and if a has
+// trap throws.
+func (v Value) In(prop string) bool {
+	if v.ctx == nil || v.freed() {
 		return false
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.IsPromise(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
-	return result
+	key := v.ctx.String(prop)
+	defer key.free()
+	return v.InKey(key)
 }
 
-// ============================================================================
-// Value Conversion
-// ============================================================================
-
-// String returns the string representation of the value.
-func (v Value) String() string {
-	if v.ctx == nil {
-		return "undefined"
+// InKey is like In, but takes the property key as a value, as in key in v,
+// so that it can be a symbol. Other keys are converted to property keys as
+// the in operator converts them, so the number 0 finds the element at index 0.
+func (v Value) InKey(key Value) bool {
+	if v.ctx == nil || !v.IsObject() {
+		return false
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	s, _ := v.ctx.runtime.bridge.ToString(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
-	return s
-}
 
-// Bool returns the value as a boolean.
-func (v Value) Bool() bool {
-	if v.ctx == nil {
+	in, err := v.ctx.helper("in", inJS)
+	if err != nil {
+		return false
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := in.Call(this, key, v)
+	if err != nil {
 		return false
 	}
-	v.ctx.runtime.lock()
-	defer v.ctx.runtime.unlock()
-	b, _ := v.ctx.runtime.bridge.ToBool(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
-	return b
+	defer result.free()
+	return result.Bool()
 }
 
-// Int32 returns the value as an int32.
-func (v Value) Int32() (int32, error) {
-	if v.ctx == nil {
-		return 0, errors.New("nil value")
+// Delete deletes a property by name.
+func (v Value) Delete(prop string) error {
+	if err := v.usable(); err != nil {
+		return err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.ToInt32(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	return v.ctx.runtime.bridge.DeleteProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, prop)
 }
 
-// Int64 returns the value as an int64.
-func (v Value) Int64() (int64, error) {
-	if v.ctx == nil {
-		return 0, errors.New("nil value")
+// GetIdx returns an element by index (for arrays).
+func (v Value) GetIdx(idx int) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.ToInt64(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	valPtr, err := v.ctx.runtime.bridge.GetPropertyUint32(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, uint32(idx))
+	if err != nil {
+		return Value{}, err
+	}
+	return v.ctx.value(valPtr), nil
 }
 
-// Float64 returns the value as a float64.
-func (v Value) Float64() (float64, error) {
-	if v.ctx == nil {
-		return 0, errors.New("nil value")
+// SetIdx sets an element by index (for arrays).
+func (v Value) SetIdx(idx int, val Value) error {
+	if err := v.usable(); err != nil {
+		return err
+	}
+	if err := checkFreed(val); err != nil {
+		return err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.ToFloat64(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	return v.ctx.runtime.bridge.SetPropertyUint32(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, uint32(idx), val.ptr)
 }
 
-// BigInt returns the value as an int64 (for BigInt values).
-func (v Value) BigInt() (int64, error) {
-	if v.ctx == nil {
-		return 0, errors.New("nil value")
+// Len returns the length property of the value (for arrays/strings).
+func (v Value) Len() int {
+	if v.ctx == nil || v.freed() {
+		return 0
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.ToBigInt64(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+	lenPtr, err := v.ctx.runtime.bridge.GetProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, "length")
+	if err != nil {
+		return 0
+	}
+	defer v.ctx.runtime.bridge.FreeValue(v.ctx.runtime.goCtx, v.ctx.ctxPtr, lenPtr)
+	n, _ := v.ctx.runtime.bridge.ToInt32(v.ctx.runtime.goCtx, v.ctx.ctxPtr, lenPtr)
+	return int(n)
 }
 
-// JSONStringify returns the JSON representation of the value.
-func (v Value) JSONStringify() (string, error) {
-	if v.ctx == nil {
-		return "", errors.New("nil value")
+// ============================================================================
+// Array Operations
+// ============================================================================
+
+// ToSlice returns the elements of an array as a Go slice.
+func (v Value) ToSlice() ([]Value, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.JSONStringify(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+
+	if !v.IsArray() {
+		return nil, errors.New("value is not an array")
+	}
+	n := v.Len()
+	elems := make([]Value, n)
+	for i := range n {
+		elem, err := v.GetIdx(i)
+		if err != nil {
+			return nil, err
+		}
+		elems[i] = elem
+	}
+	return elems, nil
 }
 
-// Bytes returns the value as bytes (for ArrayBuffer values).
-func (v Value) Bytes() ([]byte, error) {
-	if v.ctx == nil {
-		return nil, errors.New("nil value")
+// StringSlice returns the elements of an array converted to strings, the
+// counterpart of Context.StringArray. Elements that are not strings are
+// converted as String does, so null becomes "null" and 1.5 becomes "1.5". A
+// conversion that throws, such as for a Symbol, returns the exception as an
+// error.
+func (v Value) StringSlice() ([]string, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.GetArrayBuffer(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
+
+	if !v.IsArray() {
+		return nil, errors.New("value is not an array")
+	}
+	out := make([]string, v.Len())
+	for i := range out {
+		elem, err := v.GetIdx(i)
+		if err != nil {
+			return nil, err
+		}
+		data, err := elem.StringBytes()
+		elem.free()
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		out[i] = string(data)
+	}
+	return out, nil
 }
 
-// Typeof returns the JavaScript typeof string for the value.
-func (v Value) Typeof() string {
-	if v.ctx == nil {
-		return "undefined"
+// Push appends values to the end of the array and returns its new length,
+// like Array.prototype.push.
+func (v Value) Push(vals ...Value) (int, error) {
+	return v.callLength("push", vals...)
+}
+
+// Pop removes and returns the last element of the array, or undefined if it
+// is empty, like Array.prototype.pop.
+func (v Value) Pop() (Value, error) {
+	return v.CallMethod("pop")
+}
+
+// Shift removes and returns the first element of the array, or undefined if
+// it is empty, like Array.prototype.shift.
+func (v Value) Shift() (Value, error) {
+	return v.CallMethod("shift")
+}
+
+// Unshift inserts values at the start of the array and returns its new
+// length, like Array.prototype.unshift.
+func (v Value) Unshift(vals ...Value) (int, error) {
+	return v.callLength("unshift", vals...)
+}
+
+// Slice returns a new array holding the elements from start up to but not
+// including end, like Array.prototype.slice. Negative indices count back
+// from the end of the array. Use SliceFrom to slice to the end of the array.
+func (v Value) Slice(start, end int) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	s, _ := v.ctx.runtime.bridge.Typeof(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr)
-	return s
-}
 
-// ============================================================================
-// Object Operations
-// ============================================================================
+	startVal := v.ctx.Int64(int64(start))
+	defer startVal.free()
+	endVal := v.ctx.Int64(int64(end))
+	defer endVal.free()
+	return v.CallMethod("slice", startVal, endVal)
+}
 
-// Get returns a property value by name.
-func (v Value) Get(prop string) (Value, error) {
-	if v.ctx == nil {
-		return Value{}, errors.New("nil value")
+// SetLength sets the length of the array to n. A smaller length removes the
+// elements past the end; a larger one adds holes, which read as undefined.
+func (v Value) SetLength(n int) error {
+	if err := v.usable(); err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("invalid array length %d", n)
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	valPtr, err := v.ctx.runtime.bridge.GetProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, prop)
+	if !v.IsArray() {
+		return errors.New("value is not an array")
+	}
+
+	setLength, err := v.ctx.helper("setLength", setLengthJS)
 	if err != nil {
-		return Value{}, err
+		return err
+	}
+	length := v.ctx.Int64(int64(n))
+	defer length.free()
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := setLength.Call(this, v, length)
+	if err != nil {
+		return err
 	}
-	return Value{ctx: v.ctx, ptr: valPtr}, nil
+	result.free()
+	return nil
 }
 
-// Set sets a property value by name.
-func (v Value) Set(prop string, val Value) error {
-	if v.ctx == nil {
-		return errors.New("nil value")
+// SliceFrom returns a new array holding the elements from start to the end of
+// the array, like Array.prototype.slice with the end omitted. A negative
+// start counts back from the end of the array.
+func (v Value) SliceFrom(start int) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.SetProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, prop, val.ptr)
+
+	startVal := v.ctx.Int64(int64(start))
+	defer startVal.free()
+	return v.CallMethod("slice", startVal)
 }
 
-// Has returns true if the object has the given property.
-func (v Value) Has(prop string) bool {
-	if v.ctx == nil {
-		return false
+// Map calls fn on each element of the array and returns a new array of the
+// results, like Array.prototype.map. fn receives the element, its index and
+// the array.
+func (v Value) Map(fn GoFunc) (Value, error) {
+	return v.callWithGoFunc("map", fn)
+}
+
+// Filter returns a new array holding the elements for which fn returns a
+// truthy value, like Array.prototype.filter. fn receives the element, its
+// index and the array.
+func (v Value) Filter(fn GoFunc) (Value, error) {
+	return v.callWithGoFunc("filter", fn)
+}
+
+// Reduce folds the array into a single value, like Array.prototype.reduce
+// with an initial value. fn receives the accumulator, the element, its index
+// and the array, and returns the next accumulator.
+func (v Value) Reduce(fn GoFunc, initial Value) (Value, error) {
+	return v.callWithGoFunc("reduce", fn, initial)
+}
+
+// callWithGoFunc calls an array method with fn as its callback argument,
+// followed by args. The callback is released once the call returns.
+func (v Value) callWithGoFunc(method string, fn GoFunc, args ...Value) (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	result, _ := v.ctx.runtime.bridge.HasProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, prop)
-	return result
+
+	callback, funcID := v.ctx.newFunction(method+"Callback", fn)
+	if funcID == 0 {
+		return Value{}, errNewFunction
+	}
+	defer v.ctx.runtime.bridge.UnregisterGoFunc(funcID)
+	defer callback.free()
+
+	return v.CallMethod(method, append([]Value{callback}, args...)...)
 }
 
-// Delete deletes a property by name.
-func (v Value) Delete(prop string) error {
-	if v.ctx == nil {
-		return errors.New("nil value")
+// callLength calls an array method that returns the array's new length.
+func (v Value) callLength(method string, vals ...Value) (int, error) {
+	result, err := v.CallMethod(method, vals...)
+	if err != nil {
+		return 0, err
 	}
-	v.ctx.runtime.lock()
-	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.DeleteProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, prop)
+	defer result.free()
+	n, err := result.Int64()
+	return int(n), err
 }
 
-// GetIdx returns an element by index (for arrays).
-func (v Value) GetIdx(idx int) (Value, error) {
-	if v.ctx == nil {
-		return Value{}, errors.New("nil value")
+// Clone returns a deep copy of the value, similar to structuredClone.
+// Objects, arrays, Dates, RegExps, Maps, Sets, ArrayBuffers and typed arrays
+// are copied recursively, shared and cyclic references are preserved in the
+// copy, and primitives are returned as is. Cloning a function or symbol
+// fails with a TypeError.
+func (v Value) Clone() (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	valPtr, err := v.ctx.runtime.bridge.GetPropertyUint32(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, uint32(idx))
+
+	clone, err := v.ctx.helper("clone", cloneJS)
 	if err != nil {
 		return Value{}, err
 	}
-	return Value{ctx: v.ctx, ptr: valPtr}, nil
-}
-
-// SetIdx sets an element by index (for arrays).
-func (v Value) SetIdx(idx int, val Value) error {
-	if v.ctx == nil {
-		return errors.New("nil value")
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	return clone.Call(this, v)
+}
+
+// ReadOnlyView returns a Proxy for the object that allows reading it but not
+// changing it, for sharing host state with untrusted code. Setting,
+// defining or deleting a property, changing the prototype and preventing
+// extensions all fail, throwing a TypeError in strict mode code. Objects and
+// functions read through the view, including call results, are wrapped in
+// read-only views themselves, except for prototypes and frozen properties.
+// The view does not stop the original object from changing. Objects whose
+// methods rely on internal slots, such as Maps and Dates, cannot be used
+// through a view.
+func (v Value) ReadOnlyView() (Value, error) {
+	if err := v.usable(); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
-	return v.ctx.runtime.bridge.SetPropertyUint32(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, uint32(idx), val.ptr)
-}
 
-// Len returns the length property of the value (for arrays/strings).
-func (v Value) Len() int {
-	if v.ctx == nil {
-		return 0
+	if !v.IsObject() {
+		return Value{}, errors.New("value is not an object")
 	}
-	v.ctx.runtime.lock()
-	defer v.ctx.runtime.unlock()
-	lenPtr, err := v.ctx.runtime.bridge.GetProperty(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, "length")
+	readOnlyView, err := v.ctx.helper("readOnlyView", readOnlyViewJS)
 	if err != nil {
-		return 0
+		return Value{}, err
 	}
-	n, _ := v.ctx.runtime.bridge.ToInt32(v.ctx.runtime.goCtx, v.ctx.ctxPtr, lenPtr)
-	return int(n)
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	return readOnlyView.Call(this, v)
 }
 
 // ============================================================================
@@ -824,8 +2928,11 @@ func (v Value) Len() int {
 
 // Call calls the value as a function with the given arguments.
 func (v Value) Call(this Value, args ...Value) (Value, error) {
-	if v.ctx == nil {
-		return Value{}, errors.New("nil value")
+	if err := v.usable(); err != nil {
+		return Value{}, err
+	}
+	if err := checkFreed(append([]Value{this}, args...)...); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
@@ -835,18 +2942,23 @@ func (v Value) Call(this Value, args ...Value) (Value, error) {
 		argPtrs[i] = arg.ptr
 	}
 
-	resultPtr, err := v.ctx.runtime.bridge.Call(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, this.ptr, argPtrs)
-	if err != nil {
-		return Value{}, err
-	}
+	return v.ctx.runtime.withDeadline(func() (Value, error) {
+		resultPtr, err := v.ctx.runtime.bridge.Call(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, this.ptr, argPtrs)
+		if err != nil {
+			return Value{}, err
+		}
 
-	return v.ctx.checkException(resultPtr)
+		return v.ctx.checkException(resultPtr)
+	})
 }
 
 // CallMethod calls a method on the value with the given arguments.
 func (v Value) CallMethod(method string, args ...Value) (Value, error) {
-	if v.ctx == nil {
-		return Value{}, errors.New("nil value")
+	if err := v.usable(); err != nil {
+		return Value{}, err
+	}
+	if err := checkFreed(args...); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
@@ -856,18 +2968,97 @@ func (v Value) CallMethod(method string, args ...Value) (Value, error) {
 		argPtrs[i] = arg.ptr
 	}
 
-	resultPtr, err := v.ctx.runtime.bridge.Invoke(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, method, argPtrs)
+	return v.ctx.runtime.withDeadline(func() (Value, error) {
+		resultPtr, err := v.ctx.runtime.bridge.Invoke(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, method, argPtrs)
+		if err != nil {
+			return Value{}, err
+		}
+
+		return v.ctx.checkException(resultPtr)
+	})
+}
+
+// Name returns the name of a function, its name property, or the empty
+// string for an anonymous function or one whose name is not a string.
+func (v Value) Name() (string, error) {
+	if err := v.usable(); err != nil {
+		return "", err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	if !v.IsFunction() {
+		return "", errors.New("value is not a function")
+	}
+	name, err := v.Get("name")
+	if err != nil {
+		return "", err
+	}
+	defer name.free()
+	if !name.IsString() {
+		return "", nil
+	}
+	return name.String(), nil
+}
+
+// SetName sets the name of a function, as reported by Name, stack traces
+// and Inspect. The name property is read-only to assignment, so SetName
+// redefines it, keeping it non-writable.
+func (v Value) SetName(name string) error {
+	if err := v.usable(); err != nil {
+		return err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	if !v.IsFunction() {
+		return errors.New("value is not a function")
+	}
+	setName, err := v.ctx.helper("setName", setNameJS)
+	if err != nil {
+		return err
+	}
+	nameVal := v.ctx.String(name)
+	defer nameVal.free()
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := setName.Call(this, v, nameVal)
 	if err != nil {
+		return err
+	}
+	result.free()
+	return nil
+}
+
+// CallSpread calls the value as a function with the elements of argsArray as
+// its arguments, like fn(...argsArray) in JavaScript. argsArray may be any
+// iterable, but is usually an array built in JavaScript.
+func (v Value) CallSpread(this Value, argsArray Value) (Value, error) {
+	if err := v.usable(); err != nil {
 		return Value{}, err
 	}
+	if err := checkFreed(this, argsArray); err != nil {
+		return Value{}, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
 
-	return v.ctx.checkException(resultPtr)
+	callSpread, err := v.ctx.helper("callSpread", callSpreadJS)
+	if err != nil {
+		return Value{}, err
+	}
+	undefined := v.ctx.undefinedUnlocked()
+	defer undefined.freeUnlocked()
+	return callSpread.Call(undefined, v, this, argsArray)
 }
 
 // New calls the value as a constructor with the given arguments.
 func (v Value) New(args ...Value) (Value, error) {
-	if v.ctx == nil {
-		return Value{}, errors.New("nil value")
+	if err := v.usable(); err != nil {
+		return Value{}, err
+	}
+	if err := checkFreed(args...); err != nil {
+		return Value{}, err
 	}
 	v.ctx.runtime.lock()
 	defer v.ctx.runtime.unlock()
@@ -877,17 +3068,19 @@ func (v Value) New(args ...Value) (Value, error) {
 		argPtrs[i] = arg.ptr
 	}
 
-	resultPtr, err := v.ctx.runtime.bridge.CallConstructor(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, argPtrs)
-	if err != nil {
-		return Value{}, err
-	}
+	return v.ctx.runtime.withDeadline(func() (Value, error) {
+		resultPtr, err := v.ctx.runtime.bridge.CallConstructor(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, argPtrs)
+		if err != nil {
+			return Value{}, err
+		}
 
-	return v.ctx.checkException(resultPtr)
+		return v.ctx.checkException(resultPtr)
+	})
 }
 
 // Instanceof returns true if the value is an instance of the given constructor.
 func (v Value) Instanceof(ctor Value) bool {
-	if v.ctx == nil {
+	if v.ctx == nil || v.freed() {
 		return false
 	}
 	v.ctx.runtime.lock()
@@ -895,3 +3088,113 @@ func (v Value) Instanceof(ctor Value) bool {
 	result, _ := v.ctx.runtime.bridge.Instanceof(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, ctor.ptr)
 	return result
 }
+
+// StrictEquals reports whether v and other are equal under the JavaScript
+// === operator: objects are equal only if they are the same object, strings
+// by content, NaN is not equal to itself and 0 equals -0.
+func (v Value) StrictEquals(other Value) bool {
+	if v.ctx == nil || other.ctx == nil {
+		return v.ctx == other.ctx
+	}
+	if v.freed() || other.freed() {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	strictEquals, err := v.ctx.helper("strictEquals", strictEqualsJS)
+	if err != nil {
+		return false
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := strictEquals.Call(this, v, other)
+	if err != nil {
+		return false
+	}
+	defer result.free()
+	return result.Bool()
+}
+
+// sameObject reports whether v and other are the same object. It compares the
+// values' raw representation, so it is only meaningful for objects.
+func (v Value) sameObject(other Value) bool {
+	if v.ctx == nil || other.ctx == nil {
+		return false
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	result, _ := v.ctx.runtime.bridge.StrictEq(v.ctx.runtime.goCtx, v.ptr, other.ptr)
+	return result
+}
+
+// free releases the value's slot. It is used for temporaries that never
+// escape to the caller.
+func (v Value) free() {
+	v.Free()
+}
+
+// AllKeys returns the names of all string-keyed properties reachable from
+// the value, own or inherited, enumerable or not, each listed once. Own
+// properties come first, followed by those of each prototype in turn, which
+// suits completing member names in a REPL. Primitives are looked up as their
+// wrapper objects, so a string lists its indices and the String methods.
+func (v Value) AllKeys() ([]string, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	allKeys, err := v.ctx.helper("allKeys", allKeysJS)
+	if err != nil {
+		return nil, err
+	}
+	this := v.ctx.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := allKeys.Call(this, v)
+	if err != nil {
+		return nil, err
+	}
+	defer result.free()
+
+	keys := make([]string, result.Len())
+	for i := range keys {
+		key, err := result.GetIdx(i)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key.String()
+		key.free()
+	}
+	return keys, nil
+}
+
+// PropertyNames returns the names of the object's own enumerable
+// string-keyed properties, in the same order as Object.keys: array indices
+// such as "0" and "1" first, in ascending order, then the other names in
+// the order they were added. Symbol-keyed properties are left out. It fails
+// if the value is not an object.
+func (v Value) PropertyNames() ([]string, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+
+	if !v.IsObject() {
+		return nil, errors.New("value is not an object")
+	}
+	return v.ownKeys()
+}
+
+// ownKeys returns the value's own enumerable string keys, in the same order
+// as Object.keys.
+func (v Value) ownKeys() ([]string, error) {
+	if err := v.usable(); err != nil {
+		return nil, err
+	}
+	v.ctx.runtime.lock()
+	defer v.ctx.runtime.unlock()
+	return v.ctx.runtime.bridge.GetOwnPropertyNames(v.ctx.runtime.goCtx, v.ctx.ctxPtr, v.ptr, bridge.GPNStringMask|bridge.GPNEnumOnly)
+}
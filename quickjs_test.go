@@ -1,12 +1,39 @@
 package quickjs
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
+// newTestContext creates a runtime and context that are closed when the
+// test or benchmark finishes.
+func newTestContext(tb testing.TB) *Context {
+	tb.Helper()
+	rt, err := NewRuntime()
+	if err != nil {
+		tb.Fatalf("NewRuntime() error = %v", err)
+	}
+	tb.Cleanup(func() { rt.Close() })
+
+	ctx, err := rt.NewContext()
+	if err != nil {
+		tb.Fatalf("NewContext() error = %v", err)
+	}
+	tb.Cleanup(func() { ctx.Close() })
+	return ctx
+}
+
 func TestNewRuntime(t *testing.T) {
 	rt, err := NewRuntime()
 	if err != nil {
@@ -15,6 +42,42 @@ func TestNewRuntime(t *testing.T) {
 	rt.Close()
 }
 
+func TestOnClose(t *testing.T) {
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	if _, err := ctx.Eval("var buffered = ['a', 'b']"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	// Callbacks run in order, while the runtime is still usable
+	var calls []string
+	rt.OnClose(func() {
+		val, err := ctx.Eval("buffered.join(',')")
+		if err != nil {
+			t.Errorf("Eval in OnClose error = %v", err)
+			return
+		}
+		calls = append(calls, "flush "+val.String())
+	})
+	rt.OnClose(func() { calls = append(calls, "second") })
+
+	if err := rt.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := rt.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+	if want := []string{"flush a,b", "second"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("OnClose callbacks = %v, want %v", calls, want)
+	}
+}
+
 func TestNewContext(t *testing.T) {
 	rt, err := NewRuntime()
 	if err != nil {
@@ -78,6 +141,159 @@ func TestMultipleContexts(t *testing.T) {
 	}
 }
 
+func TestValueContextID(t *testing.T) {
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer rt.Close()
+
+	ctx1, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx1.Close()
+	ctx2, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx2.Close()
+
+	a := ctx1.Int32(1)
+	b, _ := ctx1.Eval("({})")
+	c := ctx2.Int32(1)
+	if a.ContextID() == 0 {
+		t.Error("ContextID should not be 0 for a context's value")
+	}
+	if a.ContextID() != b.ContextID() {
+		t.Errorf("values from one context report IDs %d and %d", a.ContextID(), b.ContextID())
+	}
+	if a.ContextID() == c.ContextID() {
+		t.Errorf("values from two contexts both report ID %d", a.ContextID())
+	}
+	if id := (Value{}).ContextID(); id != 0 {
+		t.Errorf("zero Value ContextID = %d, want 0", id)
+	}
+}
+
+func TestMaxContexts(t *testing.T) {
+	ctx1 := newTestContext(t)
+	rt := ctx1.runtime
+	rt.SetMaxContexts(2)
+
+	ctx2, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx2.Close()
+
+	if _, err := rt.NewContext(); !errors.Is(err, ErrTooManyContexts) {
+		t.Fatalf("third NewContext() error = %v, want ErrTooManyContexts", err)
+	}
+
+	// Closing a context frees up a slot; closing it twice does not free two
+	ctx1.Close()
+	ctx1.Close()
+
+	ctx3, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() after Close error = %v", err)
+	}
+	defer ctx3.Close()
+
+	if _, err := rt.NewContext(); !errors.Is(err, ErrTooManyContexts) {
+		t.Errorf("NewContext() over the limit error = %v, want ErrTooManyContexts", err)
+	}
+}
+
+func TestMemoryUsageAfterClose(t *testing.T) {
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer rt.Close()
+
+	rt.RunGC()
+	before, err := rt.MemoryUsage()
+	if err != nil {
+		t.Fatalf("MemoryUsage error = %v", err)
+	}
+
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	if _, err := ctx.Eval("globalThis.big = Array.from({length: 100000}, (_, i) => ({i, s: 'item' + i})); undefined"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	during, _ := rt.MemoryUsage()
+	if during.MemoryUsedSize < before.MemoryUsedSize+1<<20 {
+		t.Fatalf("MemoryUsedSize = %d with a large global, want well above %d", during.MemoryUsedSize, before.MemoryUsedSize)
+	}
+	if during.ObjectCount < 100000 {
+		t.Errorf("ObjectCount = %d, want at least 100000", during.ObjectCount)
+	}
+
+	ctx.Close()
+	rt.RunGC()
+	after, _ := rt.MemoryUsage()
+	if after.ObjectCount > before.ObjectCount || after.MallocCount > before.MallocCount {
+		t.Errorf("after Close: %d objects, %d allocations; want back to %d objects, %d allocations",
+			after.ObjectCount, after.MallocCount, before.ObjectCount, before.MallocCount)
+	}
+}
+
+func TestStats(t *testing.T) {
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer rt.Close()
+
+	if s := rt.Stats(); s.Contexts != 0 || s.Evals != 0 || s.GCs != 0 || s.JobsPending {
+		t.Errorf("Stats of a new runtime = %+v", s)
+	}
+
+	ctx1, _ := rt.NewContext()
+	defer ctx1.Close()
+	ctx2, _ := rt.NewContext()
+	defer ctx2.Close()
+
+	ctx1.Eval("1 + 1")
+	ctx1.Eval("throw new Error('counted too')")
+	ctx2.EvalModule("export const x = 1", "stats.js")
+	ctx2.Eval("Promise.resolve().then(() => {})")
+	rt.RunGC()
+
+	s := rt.Stats()
+	if s.Contexts != 2 {
+		t.Errorf("Contexts = %d, want 2", s.Contexts)
+	}
+	if s.Evals != 4 {
+		t.Errorf("Evals = %d, want 4", s.Evals)
+	}
+	if s.GCs != 1 {
+		t.Errorf("GCs = %d, want 1", s.GCs)
+	}
+	if !s.JobsPending {
+		t.Error("JobsPending = false with a pending promise job")
+	}
+	if s.Memory.ObjectCount == 0 {
+		t.Error("Memory.ObjectCount = 0")
+	}
+
+	// Internal evaluations are not counted
+	if _, err := ctx1.Transformer("(x) => x"); err != nil {
+		t.Fatalf("Transformer error = %v", err)
+	}
+	rt.ExecutePendingJobs()
+	ctx2.Close()
+	s = rt.Stats()
+	if s.Contexts != 1 || s.Evals != 4 || s.JobsPending {
+		t.Errorf("Stats = %+v, want 1 context, 4 evals and no pending jobs", s)
+	}
+}
+
 // ============================================================================
 // Basic JavaScript Evaluation
 // ============================================================================
@@ -941,6 +1157,186 @@ func TestValueTypes(t *testing.T) {
 	}
 }
 
+func TestValueNumber(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code  string
+		isInt bool
+		i     int64
+		f     float64
+	}{
+		{"42", true, 42, 42},
+		{"42.5", false, 0, 42.5},
+		{"42.0", true, 42, 42},
+		{"-7", true, -7, -7},
+		{"2 ** 53", true, 1 << 53, 1 << 53},
+		{"1e300", false, 0, 1e300},
+		{"Infinity", false, 0, math.Inf(1)},
+	}
+
+	for _, tt := range tests {
+		val, err := ctx.Eval(tt.code)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.code, err)
+		}
+		isInt, i, f := val.Number()
+		if isInt != tt.isInt || i != tt.i || f != tt.f {
+			t.Errorf("Number(%s) = (%v, %d, %v), want (%v, %d, %v)", tt.code, isInt, i, f, tt.isInt, tt.i, tt.f)
+		}
+	}
+
+	val, _ := ctx.Eval("NaN")
+	if isInt, _, f := val.Number(); isInt || !math.IsNaN(f) {
+		t.Errorf("Number(NaN) = (%v, _, %v), want (false, _, NaN)", isInt, f)
+	}
+}
+
+func TestIsPlainObject(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"({})", true},
+		{"({a: 1})", true},
+		{"Object.create(null)", true},
+		{"[]", false},
+		{"(function() {})", false},
+		{"new Date()", false},
+		{"new (class Point {})()", false},
+		{"new Map()", false},
+		{"null", false},
+		{"'str'", false},
+	}
+
+	for _, tt := range tests {
+		val, err := ctx.Eval(tt.code)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.code, err)
+		}
+		if got := val.IsPlainObject(); got != tt.want {
+			t.Errorf("IsPlainObject(%s) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestIsNullish(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"null", true},
+		{"undefined", true},
+		{"0", false},
+		{"''", false},
+		{"false", false},
+		{"NaN", false},
+		{"({})", false},
+	}
+
+	for _, tt := range tests {
+		val, err := ctx.Eval(tt.code)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.code, err)
+		}
+		if got := val.IsNullish(); got != tt.want {
+			t.Errorf("IsNullish(%s) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestFloat32Slice(t *testing.T) {
+	ctx := newTestContext(t)
+
+	arr, err := ctx.Eval("Float32Array.from({length: 10000}, (_, i) => i / 3 - 1000)")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	got, err := arr.Float32Slice()
+	if err != nil {
+		t.Fatalf("Float32Slice error = %v", err)
+	}
+	if len(got) != 10000 {
+		t.Fatalf("len(Float32Slice()) = %d, want 10000", len(got))
+	}
+	for i, f := range got {
+		if want := float32(float64(i)/3 - 1000); f != want {
+			t.Fatalf("Float32Slice()[%d] = %v, want %v", i, f, want)
+		}
+	}
+
+	// A view into part of a larger buffer only covers that part
+	sub, err := ctx.Eval("new Float32Array([1.5, 2.5, 3.5, 4.5]).subarray(1, 3)")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, _ := sub.Float32Slice(); !reflect.DeepEqual(got, []float32{2.5, 3.5}) {
+		t.Errorf("Float32Slice() of subarray = %v, want [2.5 3.5]", got)
+	}
+
+	for _, code := range []string{"new Float64Array(2)", "[1, 2]", "new ArrayBuffer(8)"} {
+		val, err := ctx.Eval(code)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", code, err)
+		}
+		if _, err := val.Float32Slice(); err == nil {
+			t.Errorf("Float32Slice() on %s should fail", code)
+		}
+	}
+}
+
+func TestDetachedBuffer(t *testing.T) {
+	ctx := newTestContext(t)
+
+	buf, err := ctx.Eval("globalThis.buf = new Uint8Array([1, 2, 3, 4]).buffer; buf")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if buf.IsDetachedBuffer() {
+		t.Error("a fresh buffer should not be detached")
+	}
+
+	moved, err := ctx.Eval("globalThis.view = new Float32Array(buf); buf.transfer()")
+	if err != nil {
+		t.Fatalf("transfer error = %v", err)
+	}
+	if !buf.IsDetachedBuffer() {
+		t.Error("a transferred buffer should be detached")
+	}
+	if moved.IsDetachedBuffer() {
+		t.Error("the new buffer should not be detached")
+	}
+	if data, err := buf.Bytes(); !errors.Is(err, ErrDetachedBuffer) {
+		t.Errorf("Bytes() = %v, %v, want ErrDetachedBuffer", data, err)
+	}
+	if data, err := moved.Bytes(); err != nil || !reflect.DeepEqual(data, []byte{1, 2, 3, 4}) {
+		t.Errorf("Bytes() of the new buffer = %v, %v, want [1 2 3 4]", data, err)
+	}
+	view, _ := ctx.GetGlobal("view")
+	if _, err := view.Float32Slice(); !errors.Is(err, ErrDetachedBuffer) {
+		t.Errorf("Float32Slice() error = %v, want ErrDetachedBuffer", err)
+	}
+
+	// The failed reads leave no exception behind
+	if result, err := ctx.Eval("buf.detached && buf.byteLength === 0"); err != nil || !result.Bool() {
+		t.Errorf("Eval after a failed read = %v, %v", result, err)
+	}
+
+	for _, code := range []string{"[1]", "new Uint8Array(2)", "null"} {
+		val, _ := ctx.Eval(code)
+		if val.IsDetachedBuffer() {
+			t.Errorf("IsDetachedBuffer(%s) = true, want false", code)
+		}
+	}
+	if _, err := ctx.Object().Bytes(); errors.Is(err, ErrDetachedBuffer) {
+		t.Error("Bytes() of an object should not report a detached buffer")
+	}
+}
+
 // ============================================================================
 // Value Creation
 // ============================================================================
@@ -995,6 +1391,102 @@ func TestValueCreation(t *testing.T) {
 	}
 }
 
+func TestStringArray(t *testing.T) {
+	ctx := newTestContext(t)
+
+	ss := make([]string, 10000)
+	for i := range ss {
+		ss[i] = fmt.Sprintf("item-%d", i)
+	}
+
+	arr := ctx.StringArray(ss)
+	if !arr.IsArray() {
+		t.Fatalf("StringArray should return an array")
+	}
+	if arr.Len() != len(ss) {
+		t.Errorf("Len() = %d, want %d", arr.Len(), len(ss))
+	}
+
+	ctx.SetGlobal("items", arr)
+	result, err := ctx.Eval("items[4321]")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if result.String() != "item-4321" {
+		t.Errorf("items[4321] = %q, want %q", result.String(), "item-4321")
+	}
+}
+
+func TestStringSlice(t *testing.T) {
+	ctx := newTestContext(t)
+
+	arr, _ := ctx.Eval(`["a", "b", "c"]`)
+	got, err := arr.StringSlice()
+	if err != nil {
+		t.Fatalf("StringSlice error = %v", err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StringSlice = %q, want %q", got, want)
+	}
+
+	mixed, _ := ctx.Eval(`["", 1, 1.5, true, null, undefined, [1, 2], {}, 10n, { toString() { return "custom" } }]`)
+	got, err = mixed.StringSlice()
+	if err != nil {
+		t.Fatalf("StringSlice error = %v", err)
+	}
+	want := []string{"", "1", "1.5", "true", "null", "undefined", "1,2", "[object Object]", "10", "custom"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StringSlice = %q, want %q", got, want)
+	}
+
+	// Round trip through StringArray
+	ss := []string{"x", "héllo", ""}
+	if got, _ := ctx.StringArray(ss).StringSlice(); !reflect.DeepEqual(got, ss) {
+		t.Errorf("StringSlice(StringArray(%q)) = %q", ss, got)
+	}
+
+	sym, _ := ctx.Eval(`["ok", Symbol("s")]`)
+	if _, err := sym.StringSlice(); err == nil {
+		t.Error("StringSlice with a Symbol element should fail")
+	}
+	if _, err := ctx.String("abc").StringSlice(); err == nil {
+		t.Error("StringSlice on a string should fail")
+	}
+}
+
+func TestArrayFrom(t *testing.T) {
+	ctx := newTestContext(t)
+
+	obj := ctx.Object()
+	vals := []Value{ctx.Int32(1), ctx.String("two"), obj, ctx.Null()}
+	arr := ctx.ArrayFrom(vals)
+	if !arr.IsArray() {
+		t.Fatalf("ArrayFrom should return an array")
+	}
+	if arr.Len() != len(vals) {
+		t.Errorf("Len() = %d, want %d", arr.Len(), len(vals))
+	}
+
+	ctx.SetGlobal("arr", arr)
+	ctx.SetGlobal("obj", obj)
+	result, err := ctx.Eval("JSON.stringify(arr) + ' ' + (arr[2] === obj)")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if want := `[1,"two",{},null] true`; result.String() != want {
+		t.Errorf("arr = %q, want %q", result.String(), want)
+	}
+
+	// The caller's values are still usable
+	if vals[1].String() != "two" {
+		t.Errorf("vals[1] = %q after ArrayFrom", vals[1].String())
+	}
+
+	if empty := ctx.ArrayFrom(nil); !empty.IsArray() || empty.Len() != 0 {
+		t.Errorf("ArrayFrom(nil) should be an empty array")
+	}
+}
+
 // ============================================================================
 // Object Operations
 // ============================================================================
@@ -1048,30 +1540,112 @@ func TestObjectOperations(t *testing.T) {
 	}
 }
 
-func TestArrayOperations(t *testing.T) {
-	rt, err := NewRuntime()
+func TestAllKeys(t *testing.T) {
+	ctx := newTestContext(t)
+
+	arr, err := ctx.Eval("[10, 20]")
 	if err != nil {
-		t.Fatalf("NewRuntime() error = %v", err)
+		t.Fatalf("Eval error = %v", err)
 	}
-	defer rt.Close()
-
-	ctx, err := rt.NewContext()
+	keys, err := arr.AllKeys()
 	if err != nil {
-		t.Fatalf("NewContext() error = %v", err)
+		t.Fatalf("AllKeys error = %v", err)
 	}
-	defer ctx.Close()
-
-	arr := ctx.Array()
-
-	// Set elements
-	if err := arr.SetIdx(0, ctx.Int32(10)); err != nil {
-		t.Fatalf("SetIdx error = %v", err)
+	seen := make(map[string]int)
+	for _, k := range keys {
+		seen[k]++
 	}
-	if err := arr.SetIdx(1, ctx.Int32(20)); err != nil {
-		t.Fatalf("SetIdx error = %v", err)
+	for _, want := range []string{"0", "1", "length", "map", "filter", "hasOwnProperty"} {
+		if seen[want] != 1 {
+			t.Errorf("AllKeys() lists %q %d times, want once", want, seen[want])
+		}
 	}
-	if err := arr.SetIdx(2, ctx.Int32(30)); err != nil {
-		t.Fatalf("SetIdx error = %v", err)
+	if keys[0] != "0" || keys[1] != "1" {
+		t.Errorf("AllKeys() should start with the own indices, got %v", keys[:2])
+	}
+
+	// Shadowed properties are listed once
+	obj, _ := ctx.Eval("var Base = class { greet() {} }; var o = new Base(); o.greet = 1; o")
+	keys, _ = obj.AllKeys()
+	count := 0
+	for _, k := range keys {
+		if k == "greet" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("greet listed %d times, want 1", count)
+	}
+
+	null, _ := ctx.Eval("null")
+	if _, err := null.AllKeys(); err == nil {
+		t.Errorf("AllKeys on null should fail")
+	}
+}
+
+func TestPropertyNames(t *testing.T) {
+	ctx := newTestContext(t)
+
+	obj, err := ctx.Eval(`
+		var o = {b: 1, [Symbol("s")]: 2, 1: "x", a: 3, 0: "y"};
+		Object.defineProperty(o, "hidden", {value: 4, enumerable: false});
+		o
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	names, err := obj.PropertyNames()
+	if err != nil {
+		t.Fatalf("PropertyNames error = %v", err)
+	}
+	if want := []string{"0", "1", "b", "a"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("PropertyNames() = %v, want %v", names, want)
+	}
+
+	arr, _ := ctx.Eval("['p', 'q']")
+	names, err = arr.PropertyNames()
+	if err != nil {
+		t.Fatalf("PropertyNames error = %v", err)
+	}
+	if want := []string{"0", "1"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("PropertyNames() of an array = %v, want %v", names, want)
+	}
+
+	for _, code := range []string{"42", "'str'", "null", "undefined"} {
+		val, _ := ctx.Eval(code)
+		if _, err := val.PropertyNames(); err == nil {
+			t.Errorf("PropertyNames on %s should fail", code)
+		}
+	}
+	if _, err := (Value{}).PropertyNames(); err == nil {
+		t.Error("PropertyNames on the zero Value should fail")
+	}
+}
+
+func TestArrayOperations(t *testing.T) {
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer rt.Close()
+
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx.Close()
+
+	arr := ctx.Array()
+
+	// Set elements
+	if err := arr.SetIdx(0, ctx.Int32(10)); err != nil {
+		t.Fatalf("SetIdx error = %v", err)
+	}
+	if err := arr.SetIdx(1, ctx.Int32(20)); err != nil {
+		t.Fatalf("SetIdx error = %v", err)
+	}
+	if err := arr.SetIdx(2, ctx.Int32(30)); err != nil {
+		t.Fatalf("SetIdx error = %v", err)
 	}
 
 	// Get length
@@ -1089,6 +1663,100 @@ func TestArrayOperations(t *testing.T) {
 	}
 }
 
+func TestSetLength(t *testing.T) {
+	ctx := newTestContext(t)
+
+	arr, err := ctx.Eval("[1, 2, 3, 4, 5]")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	if err := arr.SetLength(2); err != nil {
+		t.Fatalf("SetLength(2) error = %v", err)
+	}
+	if arr.Len() != 2 || arr.String() != "1,2" {
+		t.Errorf("after SetLength(2): len %d, %q; want 2, \"1,2\"", arr.Len(), arr.String())
+	}
+
+	if err := arr.SetLength(10); err != nil {
+		t.Fatalf("SetLength(10) error = %v", err)
+	}
+	if arr.Len() != 10 {
+		t.Errorf("Len() = %d after SetLength(10), want 10", arr.Len())
+	}
+	first, _ := arr.GetIdx(1)
+	hole, _ := arr.GetIdx(5)
+	if first.String() != "2" || !hole.IsUndefined() {
+		t.Errorf("arr[1] = %s, arr[5] = %s; want 2, undefined", first.String(), hole.String())
+	}
+	ctx.SetGlobal("arr", arr)
+	if has, _ := ctx.Eval("5 in arr"); has.Bool() {
+		t.Errorf("extending should add holes, not elements")
+	}
+
+	if err := arr.SetLength(-1); err == nil {
+		t.Errorf("SetLength(-1) should fail")
+	}
+	frozen, _ := ctx.Eval("Object.freeze([1, 2, 3])")
+	if err := frozen.SetLength(1); err == nil {
+		t.Errorf("SetLength on a frozen array should fail")
+	}
+	if err := ctx.Object().SetLength(1); err == nil {
+		t.Errorf("SetLength on an object should fail")
+	}
+}
+
+func TestArrayMapFilterReduce(t *testing.T) {
+	ctx := newTestContext(t)
+
+	arr, err := ctx.Eval("[1, 2, 3, 4, 5]")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	doubled, err := arr.Map(func(ctx *Context, this Value, args []Value) Value {
+		n, _ := args[0].Int32()
+		return ctx.Int32(n * 2)
+	})
+	if err != nil {
+		t.Fatalf("Map error = %v", err)
+	}
+	if s, _ := doubled.JSONStringify(); s != "[2,4,6,8,10]" {
+		t.Errorf("Map() = %s, want [2,4,6,8,10]", s)
+	}
+
+	big, err := doubled.Filter(func(ctx *Context, this Value, args []Value) Value {
+		n, _ := args[0].Int32()
+		return ctx.Bool(n > 4)
+	})
+	if err != nil {
+		t.Fatalf("Filter error = %v", err)
+	}
+	if s, _ := big.JSONStringify(); s != "[6,8,10]" {
+		t.Errorf("Filter() = %s, want [6,8,10]", s)
+	}
+
+	sum, err := doubled.Reduce(func(ctx *Context, this Value, args []Value) Value {
+		acc, _ := args[0].Int32()
+		n, _ := args[1].Int32()
+		return ctx.Int32(acc + n)
+	}, ctx.Int32(0))
+	if err != nil {
+		t.Fatalf("Reduce error = %v", err)
+	}
+	if sum.String() != "30" {
+		t.Errorf("Reduce() = %q, want %q", sum.String(), "30")
+	}
+
+	// Errors thrown by the callback are returned
+	_, err = arr.Map(func(ctx *Context, this Value, args []Value) Value {
+		return ctx.ThrowError("map failed")
+	})
+	if err == nil || err.Error() != "map failed" {
+		t.Errorf("Map error = %v, want %q", err, "map failed")
+	}
+}
+
 // ============================================================================
 // Function Calling
 // ============================================================================
@@ -1133,6 +1801,199 @@ func TestCallFunction(t *testing.T) {
 	}
 }
 
+func TestFunctionWithError(t *testing.T) {
+	ctx := newTestContext(t)
+
+	check := ctx.FunctionWithError("checkAge", func(ctx *Context, this Value, args []Value) (Value, error) {
+		if len(args) == 0 {
+			return Value{}, errors.New("age is required")
+		}
+		age, err := args[0].Int32()
+		if err != nil || age < 0 {
+			return ctx.Undefined(), fmt.Errorf("invalid age %s", args[0].String())
+		}
+		return ctx.Bool(true), nil
+	})
+	if err := ctx.SetGlobal("checkAge", check); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+
+	val, err := ctx.Eval(`[checkAge(30), ...[[], [-1]].map((args) => {
+		try { return checkAge(...args); } catch (e) { return (e.constructor === Error) + " " + e.message; }
+	})].join(", ")`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, want := val.String(), "true, true age is required, true invalid age -1"; got != want {
+		t.Errorf("results = %q, want %q", got, want)
+	}
+
+	// An uncaught error reaches Go as a *JSError
+	_, err = ctx.Eval("checkAge()")
+	var jsErr *JSError
+	if !errors.As(err, &jsErr) || jsErr.Name != "Error" || jsErr.Message != "age is required" {
+		t.Errorf("Eval error = %v, want Error: age is required", err)
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct{ code, want string }{
+		{"(function add(a, b) { return a + b; })", "add"},
+		{"(() => 1)", ""},
+		{"(class Point {})", "Point"},
+		{"({ method() {} }).method", "method"},
+		{"(class { static name() {} })", ""},
+	}
+	for _, tt := range tests {
+		fn, err := ctx.Eval(tt.code)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.code, err)
+		}
+		name, err := fn.Name()
+		if err != nil {
+			t.Errorf("Name(%s) error = %v", tt.code, err)
+		} else if name != tt.want {
+			t.Errorf("Name(%s) = %q, want %q", tt.code, name, tt.want)
+		}
+	}
+
+	fn, _ := ctx.Eval("(() => { throw new Error('boom'); })")
+	if err := fn.SetName("renamed"); err != nil {
+		t.Fatalf("SetName error = %v", err)
+	}
+	if name, _ := fn.Name(); name != "renamed" {
+		t.Errorf("Name after SetName = %q, want %q", name, "renamed")
+	}
+	if s := fn.Inspect(); s != "[Function: renamed]" {
+		t.Errorf("Inspect after SetName = %q", s)
+	}
+	ctx.SetGlobal("fn", fn)
+	desc, _ := ctx.Eval(`JSON.stringify(Object.getOwnPropertyDescriptor(fn, "name"))`)
+	if want := `{"value":"renamed","writable":false,"enumerable":false,"configurable":true}`; desc.String() != want {
+		t.Errorf("name descriptor = %s, want %s", desc.String(), want)
+	}
+
+	obj := ctx.Object()
+	if _, err := obj.Name(); err == nil {
+		t.Error("Name of an object should fail")
+	}
+	if err := obj.SetName("x"); err == nil {
+		t.Error("SetName on an object should fail")
+	}
+}
+
+func TestCallSpread(t *testing.T) {
+	ctx := newTestContext(t)
+
+	sum := ctx.Function("sum", func(c *Context, this Value, args []Value) Value {
+		var total int32
+		for _, arg := range args {
+			n, _ := arg.Int32()
+			total += n
+		}
+		return c.Int32(total)
+	})
+	args, err := ctx.Eval("[1, 2, 3]")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	result, err := sum.CallSpread(ctx.Undefined(), args)
+	if err != nil {
+		t.Fatalf("CallSpread error = %v", err)
+	}
+	if n, _ := result.Int32(); n != 6 {
+		t.Errorf("sum(...[1, 2, 3]) = %d, want 6", n)
+	}
+
+	// this is passed through
+	self, _ := ctx.Eval("(function (a) { return this.base + a; })")
+	obj, _ := ctx.Eval("({base: 10})")
+	one, _ := ctx.Eval("[1]")
+	if result, err := self.CallSpread(obj, one); err != nil || result.String() != "11" {
+		t.Errorf("CallSpread with this = %s, %v, want 11", result.String(), err)
+	}
+
+	if _, err := sum.CallSpread(ctx.Undefined(), ctx.Int32(1)); err == nil {
+		t.Error("CallSpread with a non-iterable should fail")
+	}
+	if _, err := args.CallSpread(ctx.Undefined(), args); err == nil {
+		t.Error("CallSpread on a non-function should fail")
+	}
+}
+
+func TestCompileFunction(t *testing.T) {
+	ctx := newTestContext(t)
+
+	add, err := ctx.CompileFunction([]string{"a", "b"}, "return a + b;")
+	if err != nil {
+		t.Fatalf("CompileFunction error = %v", err)
+	}
+	if !add.IsFunction() {
+		t.Fatalf("CompileFunction should return a function")
+	}
+
+	result, err := add.Call(ctx.Undefined(), ctx.Int32(5), ctx.Int32(3))
+	if err != nil {
+		t.Fatalf("Call error = %v", err)
+	}
+	if result.String() != "8" {
+		t.Errorf("add(5, 3) = %q, want %q", result.String(), "8")
+	}
+
+	// The body runs in global scope, not in the caller's scope
+	_, err = ctx.Eval("var scope = 'global'")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	getScope, err := ctx.CompileFunction(nil, "return scope;")
+	if err != nil {
+		t.Fatalf("CompileFunction error = %v", err)
+	}
+	result, err = getScope.Call(ctx.Undefined())
+	if err != nil {
+		t.Fatalf("Call error = %v", err)
+	}
+	if result.String() != "global" {
+		t.Errorf("getScope() = %q, want %q", result.String(), "global")
+	}
+
+	if _, err := ctx.CompileFunction(nil, "return )"); err == nil {
+		t.Errorf("CompileFunction should report syntax errors")
+	}
+
+	if _, err := ctx.CompileFunction([]string{"a-b"}, "return 1;"); err == nil {
+		t.Errorf("CompileFunction should reject invalid parameter names")
+	}
+
+	// A body that closes the function early is rejected without running
+	escapes := []string{
+		"return a}); globalThis.pwned = 1; (function(){",
+		"} + function(){ globalThis.pwned = 1 }() + function(){",
+		"}, globalThis.pwned = 1, function(){",
+	}
+	for _, body := range escapes {
+		if _, err := ctx.CompileFunction([]string{"a"}, body); err == nil {
+			t.Errorf("CompileFunction(%q) should fail", body)
+		}
+	}
+	pwned, _ := ctx.Eval("typeof pwned")
+	if pwned.String() != "undefined" {
+		t.Errorf("typeof pwned = %q, escaping bodies must not run", pwned.String())
+	}
+
+	// Braces inside strings, comments and regular expressions are fine
+	tricky, err := ctx.CompileFunction(nil, "// }\n return '}' + `${'}'}` + /}/.source; /* } */")
+	if err != nil {
+		t.Fatalf("CompileFunction error = %v", err)
+	}
+	result, err = tricky.Call(ctx.Undefined())
+	if err != nil || result.String() != "}}}" {
+		t.Errorf("tricky() = %v, %v; want }}}", result, err)
+	}
+}
+
 // ============================================================================
 // Go Function Binding
 // ============================================================================
@@ -1176,6 +2037,43 @@ func TestGoFunction(t *testing.T) {
 	}
 }
 
+func TestCallerLocation(t *testing.T) {
+	ctx := newTestContext(t)
+
+	type location struct {
+		file string
+		line int
+	}
+	var calls []location
+	ctx.SetGlobal("where", ctx.Function("where", func(c *Context, this Value, args []Value) Value {
+		file, line, ok := c.CallerLocation()
+		if !ok {
+			t.Error("CallerLocation should succeed in a callback")
+		}
+		calls = append(calls, location{file, line})
+		return c.Undefined()
+	}))
+
+	_, err := ctx.EvalFile(`
+function check() {
+	where();
+}
+check();
+[1].forEach(() => where());
+`, "main.js")
+	if err != nil {
+		t.Fatalf("EvalFile error = %v", err)
+	}
+	want := []location{{"main.js", 3}, {"main.js", 6}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("caller locations = %v, want %v", calls, want)
+	}
+
+	if _, _, ok := ctx.CallerLocation(); ok {
+		t.Error("CallerLocation outside a callback should report ok = false")
+	}
+}
+
 func TestGoFunctionWithStrings(t *testing.T) {
 	rt, err := NewRuntime()
 	if err != nil {
@@ -1212,80 +2110,1401 @@ func TestGoFunctionWithStrings(t *testing.T) {
 	}
 }
 
-// ============================================================================
-// JSON
-// ============================================================================
+func TestGoFunctionWithBigInt(t *testing.T) {
+	ctx := newTestContext(t)
 
-func TestJSON(t *testing.T) {
-	rt, err := NewRuntime()
-	if err != nil {
-		t.Fatalf("NewRuntime() error = %v", err)
+	var got *big.Int
+	var readErr error
+	fn := ctx.Function("takeBig", func(c *Context, this Value, args []Value) Value {
+		got, readErr = args[0].BigIntBig()
+		return c.Undefined()
+	})
+	ctx.SetGlobal("takeBig", fn)
+
+	if _, err := ctx.Eval("takeBig(2n ** 100n)"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if readErr != nil {
+		t.Fatalf("BigIntBig error = %v", readErr)
+	}
+	want := new(big.Int).Lsh(big.NewInt(1), 100)
+	if got.Cmp(want) != 0 {
+		t.Errorf("BigIntBig() = %s, want %s", got, want)
 	}
-	defer rt.Close()
 
-	ctx, err := rt.NewContext()
-	if err != nil {
-		t.Fatalf("NewContext() error = %v", err)
+	neg, _ := ctx.Eval("-(3n ** 50n)")
+	if s, err := neg.BigIntString(); err != nil || s != "-717897987691852588770249" {
+		t.Errorf("BigIntString() = %q, %v; want %q", s, err, "-717897987691852588770249")
 	}
-	defer ctx.Close()
 
-	// Parse JSON
-	obj, err := ctx.ParseJSON(`{"name": "John", "age": 30}`)
-	if err != nil {
-		t.Fatalf("ParseJSON error = %v", err)
+	num, _ := ctx.Eval("42")
+	if _, err := num.BigIntBig(); err == nil {
+		t.Errorf("BigIntBig on a number should fail")
 	}
+}
 
-	name, err := obj.Get("name")
-	if err != nil {
-		t.Fatalf("Get error = %v", err)
+func TestToNumber(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want float64
+	}{
+		{"({valueOf() { return 7; }})", 7},
+		{"({[Symbol.toPrimitive](hint) { return hint === 'number' ? 8 : 0; }})", 8},
+		{"'  12.5 '", 12.5},
+		{"true", 1},
+		{"null", 0},
+		{"[3]", 3},
+		{"new Date(1000)", 1000},
+	}
+	for _, tt := range tests {
+		val, err := ctx.Eval("(" + tt.code + ")")
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.code, err)
+		}
+		got, err := val.ToNumber()
+		if err != nil {
+			t.Errorf("ToNumber(%s) error = %v", tt.code, err)
+		} else if got != tt.want {
+			t.Errorf("ToNumber(%s) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+
+	for _, code := range []string{"undefined", "'abc'", "({})"} {
+		val, _ := ctx.Eval("(" + code + ")")
+		if got, err := val.ToNumber(); err != nil || !math.IsNaN(got) {
+			t.Errorf("ToNumber(%s) = %v, %v, want NaN", code, got, err)
+		}
+	}
+
+	for _, tt := range []struct{ code, name string }{
+		{"({valueOf() { throw new RangeError('no'); }})", "RangeError"},
+		{"10n", "TypeError"},
+		{"Symbol()", "TypeError"},
+	} {
+		val, _ := ctx.Eval("(" + tt.code + ")")
+		_, err := val.ToNumber()
+		var jsErr *JSError
+		if !errors.As(err, &jsErr) || jsErr.Name != tt.name {
+			t.Errorf("ToNumber(%s) error = %v, want a %s", tt.code, err, tt.name)
+		}
+	}
+}
+
+func TestInt32Clamped(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want int32
+	}{
+		{"42", 42},
+		{"0", 0},
+		{"150", 150},
+		{"-5", 0},
+		{"200", 150},
+		{"99.9", 99},
+		{"2 ** 40", 150},
+		{"-(2 ** 40)", 0},
+		{"Infinity", 150},
+		{"NaN", 0},
+		{"'12'", 12},
+		{"'abc'", 0},
+	}
+	for _, tt := range tests {
+		val, err := ctx.Eval(tt.code)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.code, err)
+		}
+		if got := val.Int32Clamped(0, 150); got != tt.want {
+			t.Errorf("Int32Clamped(%s, 0, 150) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+	if got := (Value{}).Int32Clamped(-1, 1); got != -1 {
+		t.Errorf("zero Value Int32Clamped = %d, want -1", got)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want time.Duration
+	}{
+		{"1500", 1500 * time.Millisecond},
+		{"0", 0},
+		{"0.25", 250 * time.Microsecond},
+		{"-20", -20 * time.Millisecond},
+		{"60 * 60 * 1000", time.Hour},
+	}
+	for _, tt := range tests {
+		val, _ := ctx.Eval(tt.code)
+		got, err := val.Duration()
+		if err != nil {
+			t.Fatalf("Duration(%s) error = %v", tt.code, err)
+		}
+		if got != tt.want {
+			t.Errorf("Duration(%s) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+
+	for _, code := range []string{"'1500'", "null", "({})", "NaN", "Infinity", "1e300"} {
+		val, _ := ctx.Eval("(" + code + ")")
+		if d, err := val.Duration(); err == nil {
+			t.Errorf("Duration(%s) = %v, want an error", code, d)
+		}
+	}
+}
+
+func TestDefineAccessor(t *testing.T) {
+	ctx := newTestContext(t)
+
+	celsius := 20.0
+	var sets []float64
+	obj := ctx.Object()
+	ctx.SetGlobal("thermostat", obj)
+	err := obj.DefineAccessor("celsius",
+		func(c *Context, this Value, args []Value) Value {
+			return c.Float64(celsius)
+		},
+		func(c *Context, this Value, args []Value) Value {
+			celsius, _ = args[0].Float64()
+			sets = append(sets, celsius)
+			return c.Undefined()
+		})
+	if err != nil {
+		t.Fatalf("DefineAccessor error = %v", err)
+	}
+	err = obj.DefineAccessor("fahrenheit", func(c *Context, this Value, args []Value) Value {
+		return c.Float64(celsius*9/5 + 32)
+	}, nil)
+	if err != nil {
+		t.Fatalf("DefineAccessor error = %v", err)
+	}
+
+	result, err := ctx.Eval("thermostat.celsius")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if result.String() != "20" {
+		t.Errorf("celsius = %s, want 20", result.String())
+	}
+
+	celsius = 25
+	result, _ = ctx.Eval("thermostat.celsius + ' ' + thermostat.fahrenheit")
+	if result.String() != "25 77" {
+		t.Errorf("readings = %s, want 25 77", result.String())
+	}
+
+	if _, err := ctx.Eval("thermostat.celsius = 100"); err != nil {
+		t.Fatalf("assignment error = %v", err)
+	}
+	if celsius != 100 || !reflect.DeepEqual(sets, []float64{100}) {
+		t.Errorf("after assignment celsius = %v, sets = %v", celsius, sets)
+	}
+	result, _ = ctx.Eval("thermostat.fahrenheit")
+	if result.String() != "212" {
+		t.Errorf("fahrenheit = %s, want 212", result.String())
+	}
+
+	// Without a setter, assignment fails in strict mode
+	if _, err := ctx.Eval(`"use strict"; thermostat.fahrenheit = 0`); err == nil {
+		t.Error("assigning a getter-only accessor in strict mode should fail")
+	}
+	result, _ = ctx.Eval("Object.keys(thermostat).join()")
+	if result.String() != "celsius,fahrenheit" {
+		t.Errorf("keys = %s, want celsius,fahrenheit", result.String())
+	}
+
+	if err := obj.DefineAccessor("none", nil, nil); err == nil {
+		t.Error("DefineAccessor without functions should fail")
+	}
+	if err := ctx.Int32(1).DefineAccessor("x", func(c *Context, this Value, args []Value) Value {
+		return c.Undefined()
+	}, nil); err == nil {
+		t.Error("DefineAccessor on a number should fail")
+	}
+}
+
+func TestGetOrCreateObject(t *testing.T) {
+	ctx := newTestContext(t)
+	config := ctx.Object()
+	ctx.SetGlobal("config", config)
+
+	server, err := config.GetOrCreateObject("server")
+	if err != nil {
+		t.Fatalf("GetOrCreateObject error = %v", err)
+	}
+	server.Set("port", ctx.Int32(8080))
+
+	again, err := config.GetOrCreateObject("server")
+	if err != nil {
+		t.Fatalf("second GetOrCreateObject error = %v", err)
+	}
+	if !again.StrictEquals(server) {
+		t.Error("GetOrCreateObject should return the existing object")
+	}
+	tls, err := again.GetOrCreateObject("tls")
+	if err != nil {
+		t.Fatalf("nested GetOrCreateObject error = %v", err)
+	}
+	tls.Set("enabled", ctx.Bool(true))
+
+	got, _ := ctx.Eval("JSON.stringify(config)")
+	if want := `{"server":{"port":8080,"tls":{"enabled":true}}}`; got.String() != want {
+		t.Errorf("config = %s, want %s", got.String(), want)
+	}
+
+	config.Set("name", ctx.String("app"))
+	if _, err := config.GetOrCreateObject("name"); err == nil {
+		t.Error("GetOrCreateObject on a string property should fail")
+	}
+	config.Set("empty", ctx.Null())
+	if empty, err := config.GetOrCreateObject("empty"); err != nil || !empty.IsObject() {
+		t.Errorf("GetOrCreateObject on a null property = %v, %v", empty.Typeof(), err)
+	}
+}
+
+func TestSetFunctions(t *testing.T) {
+	ctx := newTestContext(t)
+
+	store := map[string]string{}
+	service := ctx.Object()
+	err := service.SetFunctions(map[string]GoFunc{
+		"put": func(c *Context, this Value, args []Value) Value {
+			store[args[0].String()] = args[1].String()
+			return c.Undefined()
+		},
+		"get": func(c *Context, this Value, args []Value) Value {
+			v, ok := store[args[0].String()]
+			if !ok {
+				return c.Null()
+			}
+			return c.String(v)
+		},
+		"size": func(c *Context, this Value, args []Value) Value {
+			return c.Int32(int32(len(store)))
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetFunctions error = %v", err)
+	}
+	if err := ctx.SetGlobal("service", service); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+
+	result, err := ctx.Eval(`
+		service.put("a", "1");
+		service.put("b", "2");
+		[service.get("a"), service.get("missing"), service.size(), Object.keys(service).join()]
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, _ := result.JSONStringify(); got != `["1",null,2,"get,put,size"]` {
+		t.Errorf("service calls = %s", got)
+	}
+
+	// Nothing is left behind when a function cannot be set
+	frozen, _ := ctx.Eval("Object.freeze({})")
+	if err := frozen.SetFunctions(map[string]GoFunc{"f": nil}); err == nil {
+		t.Error("SetFunctions on a frozen object should fail")
+	}
+	if err := ctx.Int32(1).SetFunctions(nil); err == nil {
+		t.Error("SetFunctions on a number should fail")
+	}
+}
+
+// ============================================================================
+// JSON
+// ============================================================================
+
+func TestJSON(t *testing.T) {
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer rt.Close()
+
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx.Close()
+
+	// Parse JSON
+	obj, err := ctx.ParseJSON(`{"name": "John", "age": 30}`)
+	if err != nil {
+		t.Fatalf("ParseJSON error = %v", err)
+	}
+
+	name, err := obj.Get("name")
+	if err != nil {
+		t.Fatalf("Get error = %v", err)
+	}
+	if name.String() != "John" {
+		t.Errorf("name = %q, want %q", name.String(), "John")
+	}
+
+	// Stringify
+	jsonStr, err := obj.JSONStringify()
+	if err != nil {
+		t.Fatalf("JSONStringify error = %v", err)
+	}
+	if !strings.Contains(jsonStr, "John") {
+		t.Errorf("JSONStringify should contain 'John', got %q", jsonStr)
+	}
+}
+
+func TestStringBytes(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"'hello'", "hello"},
+		{"''", ""},
+		{"'caf\\u00e9 \\u2603'", "caf\u00e9 \u2603"},
+		{"'a\\0b'", "a\x00b"},
+		{"42", "42"},
+		{"({})", "[object Object]"},
+		{"'x'.repeat(100000)", strings.Repeat("x", 100000)},
+	}
+	for _, tt := range tests {
+		val, err := ctx.Eval(tt.code)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", tt.code, err)
+		}
+		got, err := val.StringBytes()
+		val.free()
+		if err != nil {
+			t.Fatalf("StringBytes(%s) error = %v", tt.code, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("StringBytes(%s) = %q (len %d), want len %d", tt.code, got[:min(len(got), 20)], len(got), len(tt.want))
+		}
+	}
+
+	sym, err := ctx.Eval("Symbol('s')")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	defer sym.free()
+	if _, err := sym.StringBytes(); err == nil {
+		t.Error("StringBytes(Symbol) should fail")
+	}
+
+	// The context is still usable after the failed conversion
+	val, err := ctx.Eval("1 + 1")
+	if err != nil {
+		t.Fatalf("Eval after failed conversion error = %v", err)
+	}
+	if n, _ := val.Int64(); n != 2 {
+		t.Errorf("Eval after failed conversion = %d, want 2", n)
+	}
+}
+
+func TestValueFromJSONBytes(t *testing.T) {
+	ctx := newTestContext(t)
+
+	data, err := json.Marshal(map[string]any{
+		"name":   "John",
+		"tags":   []string{"a", "b"},
+		"nested": map[string]any{"ok": true, "n": 1.5},
+		"text":   "caf\u00e9 \u2603",
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal error = %v", err)
+	}
+
+	obj, err := ctx.ValueFromJSONBytes(data)
+	if err != nil {
+		t.Fatalf("ValueFromJSONBytes error = %v", err)
+	}
+	defer obj.free()
+
+	want, err := ctx.ParseJSON(string(data))
+	if err != nil {
+		t.Fatalf("ParseJSON error = %v", err)
+	}
+	defer want.free()
+
+	got, _ := obj.JSONStringify()
+	wantStr, _ := want.JSONStringify()
+	if got != wantStr {
+		t.Errorf("ValueFromJSONBytes = %s, want %s", got, wantStr)
+	}
+
+	// The slice is not read past its length
+	val, err := ctx.ValueFromJSONBytes([]byte("[1,2]garbage")[:5])
+	if err != nil {
+		t.Fatalf("ValueFromJSONBytes(subslice) error = %v", err)
+	}
+	if n := val.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+	val.free()
+
+	for _, input := range []string{"", "{", "{'a': 1}", "[1,]"} {
+		if _, err := ctx.ValueFromJSONBytes([]byte(input)); err == nil {
+			t.Errorf("ValueFromJSONBytes(%q) should fail", input)
+		}
+	}
+
+	if _, err := ctx.ValueFromJSONBytes(make([]byte, 8<<20)); err == nil {
+		t.Error("ValueFromJSONBytes should reject documents larger than the transfer buffer")
+	}
+}
+
+func TestIsJSONSerializable(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"({name: 'John', age: 30, tags: ['a', 'b'], nested: {ok: true, none: null}})", true},
+		{"JSON.parse('{\"a\": [1, 2, {\"b\": \"c\"}]}')", true},
+		{"Object.create(null)", true},
+		{"[]", true},
+		{"'text'", true},
+		{"null", true},
+		{"({f: () => 1})", false},
+		{"({s: Symbol('s')})", false},
+		{"({[Symbol('k')]: 1})", false},
+		{"({u: undefined})", false},
+		{"[1, , 3]", false},
+		{"[NaN]", false},
+		{"({n: 1n})", false},
+		{"({d: new Date(0)})", false},
+		{"new Map()", false},
+		{"(() => { const o = {}; o.self = o; return o })()", false},
+		{"undefined", false},
+	}
+	for _, tt := range tests {
+		val, err := ctx.Eval("(" + tt.code + ")")
+		if err != nil {
+			t.Fatalf("Eval(%s) error = %v", tt.code, err)
+		}
+		if got := val.IsJSONSerializable(); got != tt.want {
+			t.Errorf("IsJSONSerializable(%s) = %v, want %v", tt.code, got, tt.want)
+		}
+		val.free()
+	}
+
+	// Shared references are fine; only cycles are rejected
+	shared, _ := ctx.Eval("(() => { const x = {v: 1}; return [x, x, {x}] })()")
+	if !shared.IsJSONSerializable() {
+		t.Error("IsJSONSerializable with a shared reference = false, want true")
+	}
+}
+
+func TestHash(t *testing.T) {
+	ctx := newTestContext(t)
+
+	hash := func(code string) uint64 {
+		t.Helper()
+		val, err := ctx.Eval("(" + code + ")")
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", code, err)
+		}
+		h, err := val.Hash()
+		if err != nil {
+			t.Fatalf("Hash(%s) error = %v", code, err)
+		}
+		return h
+	}
+
+	equal := [][2]string{
+		{"({a: 1, b: [1, 2], c: {x: 'y', z: null}})", "({c: {z: null, x: 'y'}, b: [1, 2], a: 1})"},
+		{"1.0", "1"},
+	}
+	for _, pair := range equal {
+		if hash(pair[0]) != hash(pair[1]) {
+			t.Errorf("Hash(%s) != Hash(%s)", pair[0], pair[1])
+		}
+	}
+
+	distinct := []string{"({a: 1})", "({a: 2})", "({b: 1})", "[1, 2]", "[2, 1]", "'1'", "1", "null", "({})", "[]", "''"}
+	seen := map[uint64]string{}
+	for _, code := range distinct {
+		h := hash(code)
+		if prev, ok := seen[h]; ok {
+			t.Errorf("Hash(%s) == Hash(%s)", code, prev)
+		}
+		seen[h] = code
+	}
+
+	// The hash is FNV-1a of the JSON with sorted keys
+	f := fnv.New64a()
+	f.Write([]byte(`{"a":1,"b":{"c":[true,"x"]}}`))
+	if got := hash("({b: {c: [true, 'x']}, a: 1})"); got != f.Sum64() {
+		t.Errorf("Hash = %x, want %x", got, f.Sum64())
+	}
+
+	// Values JSON.stringify would drop or replace, even nested ones, are
+	// rejected rather than colliding with the values they turn into
+	for _, code := range []string{
+		"undefined", "() => 1", "Symbol('s')", "1n", "({n: 1n})", "(() => { const o = {}; o.self = o; return o })()",
+		"({a: 1, skip: undefined})", "({a: 1, f() {}})", "[undefined]", "[NaN]", "new Date(0)", "({d: new Date(0)})",
+	} {
+		val, err := ctx.Eval("(" + code + ")")
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", code, err)
+		}
+		if _, err := val.Hash(); !errors.Is(err, ErrNotJSONSerializable) {
+			t.Errorf("Hash(%s) error = %v, want ErrNotJSONSerializable", code, err)
+		}
+	}
+}
+
+// ============================================================================
+// Print/Console
+// ============================================================================
+
+func TestPrint(t *testing.T) {
+	rt, err := NewRuntime()
+	if err != nil {
+		t.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer rt.Close()
+
+	var logs []string
+	rt.SetLogFunc(func(msg string) {
+		logs = append(logs, msg)
+	})
+
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx.Close()
+
+	_, err = ctx.Eval(`print("hello"); print("world");`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	allLogs := strings.Join(logs, "")
+	if !strings.Contains(allLogs, "hello") {
+		t.Errorf("logs should contain %q, got %v", "hello", logs)
+	}
+	if !strings.Contains(allLogs, "world") {
+		t.Errorf("logs should contain %q, got %v", "world", logs)
+	}
+}
+
+func TestEvalFull(t *testing.T) {
+	ctx := newTestContext(t)
+
+	var printed []string
+	ctx.runtime.SetLogFunc(func(msg string) {
+		printed = append(printed, msg)
+	})
+
+	result, logs, err := ctx.EvalFull(`
+		console.log("value is", 42);
+		console.warn("careful");
+		console.error({}.toString());
+		6 * 7
+	`)
+	if err != nil {
+		t.Fatalf("EvalFull error = %v", err)
+	}
+	if n, _ := result.Int32(); n != 42 {
+		t.Errorf("result = %d, want 42", n)
+	}
+	want := []ConsoleEntry{
+		{Level: "log", Message: "value is 42"},
+		{Level: "warn", Message: "careful"},
+		{Level: "error", Message: "[object Object]"},
+	}
+	if !reflect.DeepEqual(logs, want) {
+		t.Errorf("logs = %+v, want %+v", logs, want)
+	}
+	if len(printed) != 0 {
+		t.Errorf("captured output should not reach the log function, got %q", printed)
+	}
+
+	// Logs written before an exception are still returned
+	_, logs, err = ctx.EvalFull(`console.info("before"); throw new Error("boom")`)
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("EvalFull error = %v, want boom", err)
+	}
+	if len(logs) != 1 || logs[0].Message != "before" {
+		t.Errorf("logs = %+v, want the entry logged before the throw", logs)
+	}
+
+	// The previous console is restored afterwards
+	if _, err := ctx.Eval(`console.log("restored")`); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if !strings.Contains(strings.Join(printed, ""), "restored") {
+		t.Errorf("console.log should reach the log function again, got %q", printed)
+	}
+}
+
+func TestEvalFullNoOutput(t *testing.T) {
+	ctx := newTestContext(t)
+
+	_, logs, err := ctx.EvalFull("1 + 1")
+	if err != nil {
+		t.Fatalf("EvalFull error = %v", err)
+	}
+	if logs != nil {
+		t.Errorf("logs = %+v, want nil for code that logs nothing", logs)
+	}
+
+	_, logs, err = ctx.EvalFull(`console.log("")`)
+	if err != nil {
+		t.Fatalf("EvalFull error = %v", err)
+	}
+	if want := []ConsoleEntry{{Level: "log", Message: ""}}; !reflect.DeepEqual(logs, want) {
+		t.Errorf("logs = %+v, want %+v", logs, want)
+	}
+
+	_, logs, _ = ctx.EvalFull("console.warn()")
+	if want := []ConsoleEntry{{Level: "warn", Message: ""}}; !reflect.DeepEqual(logs, want) {
+		t.Errorf("logs = %+v, want %+v", logs, want)
+	}
+}
+
+func TestSetMaxLogLength(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	var out strings.Builder
+	rt.SetLogFunc(func(msg string) { out.WriteString(msg) })
+	rt.SetMaxLogLength(16)
+
+	_, err := ctx.Eval(`
+		console.log("x".repeat(10 * 1024 * 1024));
+		console.log("short", 1);
+		console.log("abcdefghij", "klmnopqrst");
+		console.log("ééééééééé");
+		print("y".repeat(100));
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	want := strings.Join([]string{
+		strings.Repeat("x", 16) + "…",
+		"short 1",
+		"abcdefghij klmno…",
+		"éééééééé…",
+		strings.Repeat("y", 16) + "…",
+		"",
+	}, "\n")
+	if out.String() != want {
+		t.Errorf("log output = %q, want %q", out.String(), want)
+	}
+
+	_, logs, err := ctx.EvalFull(`console.warn("z".repeat(1000))`)
+	if err != nil {
+		t.Fatalf("EvalFull error = %v", err)
+	}
+	if want := strings.Repeat("z", 16) + "…"; len(logs) != 1 || logs[0].Message != want {
+		t.Errorf("EvalFull logs = %+v, want one message %q", logs, want)
+	}
+
+	// Without a limit messages are passed through whole
+	out.Reset()
+	rt.SetMaxLogLength(0)
+	ctx.Eval(`console.log("w".repeat(100))`)
+	if want := strings.Repeat("w", 100) + "\n"; out.String() != want {
+		t.Errorf("unlimited log output has %d bytes, want %d", out.Len(), len(want))
+	}
+}
+
+func TestSetPrintName(t *testing.T) {
+	ctx := newTestContext(t)
+
+	var printed strings.Builder
+	ctx.runtime.SetLogFunc(func(msg string) {
+		printed.WriteString(msg)
+	})
+
+	if err := ctx.SetPrintName("echo"); err != nil {
+		t.Fatalf("SetPrintName error = %v", err)
+	}
+	result, err := ctx.Eval(`echo("hi"); typeof print`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if result.String() != "undefined" {
+		t.Errorf("typeof print = %s, want undefined", result.String())
+	}
+	if printed.String() != "hi\n" {
+		t.Errorf("printed %q, want %q", printed.String(), "hi\n")
+	}
+
+	if err := ctx.SetPrintName(""); err != nil {
+		t.Fatalf("SetPrintName(\"\") error = %v", err)
+	}
+	result, _ = ctx.Eval("[typeof echo, typeof print, typeof console.log].join()")
+	if result.String() != "undefined,undefined,function" {
+		t.Errorf("after removal = %s, want undefined,undefined,function", result.String())
+	}
+	if err := ctx.SetPrintName("echo"); err == nil {
+		t.Error("SetPrintName after removal should fail")
+	}
+}
+
+func TestNoConsole(t *testing.T) {
+	rt, err := NewRuntimeWithConfig(Config{NoConsole: true})
+	if err != nil {
+		t.Fatalf("NewRuntimeWithConfig() error = %v", err)
+	}
+	defer rt.Close()
+
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx.Close()
+
+	result, err := ctx.Eval("[typeof console, typeof print].join()")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if result.String() != "undefined,undefined" {
+		t.Errorf("typeof console, print = %s, want undefined,undefined", result.String())
+	}
+	if err := ctx.SetPrintName("echo"); err == nil {
+		t.Error("SetPrintName without a console should fail")
+	}
+
+	_, err = ctx.Eval("console.log('hi')")
+	var jsErr *JSError
+	if !errors.As(err, &jsErr) || jsErr.Name != "ReferenceError" {
+		t.Errorf("console.log error = %v, want a ReferenceError", err)
+	}
+}
+
+func TestStackSize(t *testing.T) {
+	const code = "function depth(n) { return n == 0 ? 0 : 1 + depth(n - 1) } depth(5000)"
+
+	newStackContext := func(size uint32) *Context {
+		rt, err := NewRuntimeWithConfig(Config{StackSize: size})
+		if err != nil {
+			t.Fatalf("NewRuntimeWithConfig() error = %v", err)
+		}
+		t.Cleanup(func() { rt.Close() })
+		ctx, err := rt.NewContext()
+		if err != nil {
+			t.Fatalf("NewContext() error = %v", err)
+		}
+		t.Cleanup(func() { ctx.Close() })
+		return ctx
+	}
+	isRangeError := func(err error) bool {
+		var jsErr *JSError
+		return errors.As(err, &jsErr) && jsErr.Name == "RangeError"
+	}
+
+	// A small stack is too shallow for this
+	ctx := newStackContext(64 << 10)
+	if _, err := ctx.Eval(code); !isRangeError(err) {
+		t.Errorf("Eval with a 64 KiB stack error = %v, want a RangeError", err)
+	}
+
+	ctx = newStackContext(4 << 20)
+	val, err := ctx.Eval(code)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 5000 {
+		t.Errorf("depth = %d, want 5000", n)
+	}
+
+	// Runaway recursion reaches the engine's limit before the stack ends
+	if _, err := ctx.Eval("function f() { return f() } f()"); !isRangeError(err) {
+		t.Errorf("runaway recursion error = %v, want a RangeError", err)
+	}
+	if val, err := ctx.Eval("1 + 1"); err != nil || val.String() != "2" {
+		t.Errorf("Eval after overflow = %v, %v", val, err)
+	}
+
+	if _, err := NewRuntimeWithConfig(Config{StackSize: 1024}); err == nil {
+		t.Errorf("a stack below the minimum should be rejected")
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	ctx := newTestContext(t)
+
+	for _, code := range []string{
+		"function f() { return f() } f()",
+		"function g(n) { return g(n + 1) + 1 } g(0)",
+		"const o = {}; o.valueOf = function () { return +this }; +o",
+	} {
+		_, err := ctx.Eval(code)
+		var jsErr *JSError
+		if !errors.As(err, &jsErr) || jsErr.Name != "RangeError" {
+			t.Fatalf("Eval(%q) error = %v, want a RangeError", code, err)
+		}
+		if !strings.Contains(jsErr.Message, "stack") {
+			t.Errorf("Eval(%q) message = %q, want a stack overflow", code, jsErr.Message)
+		}
+
+		val, err := ctx.Eval("[1, 2, 3].map(x => x * 2).join()")
+		if err != nil {
+			t.Fatalf("Eval after overflow error = %v", err)
+		}
+		if got := val.String(); got != "2,4,6" {
+			t.Errorf("Eval after overflow = %q, want %q", got, "2,4,6")
+		}
+		val.free()
+	}
+
+	// The overflow is catchable in JavaScript too
+	val, err := ctx.Eval(`
+		function h() { return h() }
+		try { h() } catch (e) { e instanceof RangeError }
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if !val.Bool() {
+		t.Error("stack overflow should be catchable as a RangeError")
+	}
+}
+
+// ============================================================================
+// Pending Jobs
+// ============================================================================
+
+func TestExecutePendingJobs(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	_, err := ctx.Eval("var order = []; Promise.resolve().then(() => order.push(1)).then(() => order.push(2));")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+
+	n, err := rt.ExecutePendingJobs()
+	if err != nil {
+		t.Fatalf("ExecutePendingJobs error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ExecutePendingJobs() = %d, want 2", n)
+	}
+
+	n, err = rt.ExecutePendingJobs()
+	if err != nil || n != 0 {
+		t.Errorf("ExecutePendingJobs() with no jobs = %d, %v; want 0, nil", n, err)
+	}
+
+	// Without a handler, a throwing job is returned as an error
+	_, err = ctx.Eval("queueMicrotask(() => { throw new Error('unhandled') })")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	_, err = rt.ExecutePendingJobs()
+	if err == nil || err.Error() != "unhandled" {
+		t.Errorf("ExecutePendingJobs error = %v, want %q", err, "unhandled")
+	}
+}
+
+func TestRunUntil(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	var done atomic.Bool
+	finish := ctx.Function("finish", func(c *Context, this Value, args []Value) Value {
+		done.Store(true)
+		return c.Undefined()
+	})
+	if err := ctx.SetGlobal("finish", finish); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+
+	// The chain needs another goroutine to resolve it before it can finish
+	_, err := ctx.Eval(`
+		var release;
+		new Promise(resolve => { release = resolve })
+			.then(() => Promise.resolve(1))
+			.then(n => n + 1)
+			.then(() => finish());
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := ctx.Eval("release()"); err != nil {
+			t.Errorf("Eval error = %v", err)
+		}
+	}()
+
+	if err := rt.RunUntil(done.Load, 5*time.Second); err != nil {
+		t.Fatalf("RunUntil error = %v", err)
+	}
+	if !done.Load() {
+		t.Errorf("RunUntil returned before the condition held")
+	}
+
+	err = rt.RunUntil(func() bool { return false }, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunUntil error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestEvalAsyncStats(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want int32
+		jobs int
+	}{
+		{"40 + 2", 42, 0},
+		{"Promise.resolve(42)", 42, 0},
+		{"Promise.resolve(1).then(n => n + 1).then(n => n * 2).then(n => n * 10 + 2)", 42, 3},
+		{"(async () => { await null; await null; return 42 })()", 42, 2},
+		{"queueMicrotask(() => {}); queueMicrotask(() => {}); 42", 42, 2},
+	}
+	for _, tt := range tests {
+		val, jobs, err := ctx.EvalAsyncStats(tt.code)
+		if err != nil {
+			t.Fatalf("EvalAsyncStats(%q) error = %v", tt.code, err)
+		}
+		if n, _ := val.Int32(); n != tt.want {
+			t.Errorf("EvalAsyncStats(%q) = %d, want %d", tt.code, n, tt.want)
+		}
+		if jobs != tt.jobs {
+			t.Errorf("EvalAsyncStats(%q) ran %d jobs, want %d", tt.code, jobs, tt.jobs)
+		}
+	}
+
+	_, _, err := ctx.EvalAsyncStats("Promise.resolve().then(() => { throw new RangeError('late') })")
+	var jsErr *JSError
+	if !errors.As(err, &jsErr) || jsErr.Name != "RangeError" {
+		t.Errorf("EvalAsyncStats error = %v, want a RangeError", err)
+	}
+	if _, _, err := ctx.EvalAsyncStats("new Promise(() => {})"); !errors.Is(err, ErrUnsettled) {
+		t.Errorf("EvalAsyncStats error = %v, want %v", err, ErrUnsettled)
+	}
+}
+
+func TestRunMain(t *testing.T) {
+	ctx := newTestContext(t)
+
+	result, err := ctx.RunMain(`
+		function main(args) {
+			return args.reduce((sum, arg) => sum + Number(arg), 0);
+		}
+	`, []string{"1", "2", "39.5"})
+	if err != nil {
+		t.Fatalf("RunMain error = %v", err)
+	}
+	if got, _ := result.Float64(); got != 42.5 {
+		t.Errorf("RunMain = %v, want 42.5", got)
+	}
+
+	// An async main declared with const is awaited
+	other, err := ctx.runtime.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer other.Close()
+	result, err = other.RunMain("const main = async (args) => { await null; return args.join('+') }", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("RunMain error = %v", err)
+	}
+	if got := result.String(); got != "a+b" {
+		t.Errorf("RunMain = %q, want %q", got, "a+b")
+	}
+
+	for _, code := range []string{
+		"var notMain = 1",
+		"function main() { throw new Error('failed') }",
+		"async function main() { throw new Error('rejected') }",
+	} {
+		fresh, err := ctx.runtime.NewContext()
+		if err != nil {
+			t.Fatalf("NewContext error = %v", err)
+		}
+		if _, err := fresh.RunMain(code, nil); err == nil {
+			t.Errorf("RunMain(%q) should fail", code)
+		}
+		fresh.Close()
+	}
+}
+
+func TestEvalWithReport(t *testing.T) {
+	ctx := newTestContext(t)
+
+	ctx.SetGlobal("collect", ctx.Function("collect", func(c *Context, this Value, args []Value) Value {
+		c.runtime.RunGC()
+		return c.Undefined()
+	}))
+	result, report, err := ctx.EvalWithReport(`
+		globalThis.data = Array.from({ length: 100000 }, (_, i) => ({ i, s: "item " + i }));
+		collect();
+		(async () => {
+			await null;
+			return data.length;
+		})()
+	`)
+	if err != nil {
+		t.Fatalf("EvalWithReport error = %v", err)
+	}
+	if n, _ := result.Int32(); n != 100000 {
+		t.Errorf("result = %s, want 100000", result.String())
+	}
+	if report.Duration <= 0 {
+		t.Errorf("Duration = %v, want > 0", report.Duration)
+	}
+	if report.Memory <= 0 || report.PeakMemory < report.Memory {
+		t.Errorf("Memory = %d, PeakMemory = %d, want 0 < Memory <= PeakMemory", report.Memory, report.PeakMemory)
+	}
+	if report.Jobs == 0 {
+		t.Error("Jobs = 0, want the async function's continuation counted")
+	}
+	if report.GCs != 1 {
+		t.Errorf("GCs = %d, want 1", report.GCs)
+	}
+
+	// The allocation shows in the peak
+	_, freed, err := ctx.EvalWithReport("globalThis.data = null")
+	if err != nil {
+		t.Fatalf("EvalWithReport error = %v", err)
+	}
+	if freed.PeakMemory <= freed.Memory {
+		t.Errorf("after freeing, PeakMemory = %d, want above Memory = %d", freed.PeakMemory, freed.Memory)
+	}
+
+	_, report, err = ctx.EvalWithReport("throw new Error('boom')")
+	if err == nil {
+		t.Error("EvalWithReport should return the exception")
+	}
+	if report.Duration <= 0 || report.Memory <= 0 {
+		t.Errorf("report on failure = %+v, want it filled in", report)
+	}
+}
+
+func TestIn(t *testing.T) {
+	ctx := newTestContext(t)
+
+	empty, _ := ctx.Eval("[]")
+	if !empty.In("length") {
+		t.Error(`"length" in [] should be true`)
+	}
+	if empty.In("0") {
+		t.Error(`"0" in [] should be false`)
+	}
+	arr, _ := ctx.Eval("[1]")
+	if !arr.In("0") {
+		t.Error(`"0" in [1] should be true`)
+	}
+	if !arr.InKey(ctx.Int32(0)) {
+		t.Error("0 in [1] should be true")
+	}
+	if arr.InKey(ctx.Int32(1)) {
+		t.Error("1 in [1] should be false")
+	}
+
+	obj, _ := ctx.Eval(`
+		const tag = Symbol("tag");
+		globalThis.tag = tag;
+		const o = Object.create({ inherited: 1 });
+		o[tag] = true;
+		o.own = undefined;
+		o
+	`)
+	for prop, want := range map[string]bool{"own": true, "inherited": true, "toString": true, "missing": false} {
+		if got := obj.In(prop); got != want {
+			t.Errorf("In(%q) = %v, want %v", prop, got, want)
+		}
+	}
+	tag, _ := ctx.GetGlobal("tag")
+	if !obj.InKey(tag) {
+		t.Error("symbol key should be found")
+	}
+	other, _ := ctx.Eval(`Symbol("tag")`)
+	if obj.InKey(other) {
+		t.Error("a different symbol should not be found")
+	}
+
+	proxy, _ := ctx.Eval(`new Proxy({}, { has: (t, k) => k.startsWith("x") })`)
+	if !proxy.In("xyz") || proxy.In("abc") {
+		t.Error("In should use the proxy has trap")
+	}
+
+	if ctx.String("abc").In("length") {
+		t.Error("In on a primitive should be false")
+	}
+	if (Value{}).In("x") {
+		t.Error("In on the zero Value should be false")
+	}
+}
+
+func TestRegisterErrorClass(t *testing.T) {
+	ctx := newTestContext(t)
+
+	validationError, err := ctx.RegisterErrorClass("ValidationError", "")
+	if err != nil {
+		t.Fatalf("RegisterErrorClass error = %v", err)
+	}
+	if _, err := ctx.RegisterErrorClass("EmailError", "ValidationError"); err != nil {
+		t.Fatalf("RegisterErrorClass(EmailError) error = %v", err)
+	}
+
+	ctx.SetGlobal("validate", ctx.Function("validate", func(c *Context, this Value, args []Value) Value {
+		if strings.Contains(args[0].String(), "@") {
+			return c.Bool(true)
+		}
+		e, err := validationError.New(c.String("invalid email"))
+		if err != nil {
+			return c.ThrowError(err.Error())
+		}
+		defer e.free()
+		e.Set("field", c.String("email"))
+		return c.Throw(e)
+	}))
+
+	result, err := ctx.Eval(`
+		try {
+			validate("nobody");
+			"no error";
+		} catch (e) {
+			[e instanceof ValidationError, e instanceof Error, e instanceof EmailError,
+				e.name, e.message, e.field].join();
+		}
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if want := "true,true,false,ValidationError,invalid email,email"; result.String() != want {
+		t.Errorf("caught = %s, want %s", result.String(), want)
+	}
+
+	// An uncaught error keeps its class name
+	_, err = ctx.Eval(`validate("nobody")`)
+	var jsErr *JSError
+	if !errors.As(err, &jsErr) || jsErr.Name != "ValidationError" || jsErr.Message != "invalid email" {
+		t.Errorf("Eval error = %v, want the ValidationError", err)
+	}
+
+	result, _ = ctx.Eval(`
+		const e = new EmailError("bad", { cause: 1 });
+		[e instanceof ValidationError, e.name, e.cause, String(e), Object.keys(globalThis).includes("EmailError")].join();
+	`)
+	if want := "true,EmailError,1,EmailError: bad,false"; result.String() != want {
+		t.Errorf("subclass = %s, want %s", result.String(), want)
+	}
+
+	if _, err := ctx.RegisterErrorClass("Bad", "Math"); err == nil {
+		t.Error("RegisterErrorClass with a non-error parent should fail")
+	}
+	if _, err := ctx.RegisterErrorClass("", ""); err == nil {
+		t.Error("RegisterErrorClass with an empty name should fail")
+	}
+}
+
+func TestEvalJSON(t *testing.T) {
+	ctx := newTestContext(t)
+
+	data, err := ctx.EvalJSON(`
+		const user = { id: 7, name: "Ada", tags: ["admin"] };
+		({ ok: true, user })
+	`)
+	if err != nil {
+		t.Fatalf("EvalJSON error = %v", err)
+	}
+	if want := `{"ok":true,"user":{"id":7,"name":"Ada","tags":["admin"]}}`; string(data) != want {
+		t.Errorf("EvalJSON = %s, want %s", data, want)
+	}
+
+	data, err = ctx.EvalJSON(`"a\nb"`)
+	if err != nil || string(data) != `"a\nb"` {
+		t.Errorf("EvalJSON(string) = %s, %v", data, err)
+	}
+
+	// Values JSON.stringify would drop or replace, even nested ones, are
+	// rejected rather than silently left out of the JSON
+	for _, code := range []string{
+		"undefined", "() => 1", "1n", "const o = {}; o.o = o; o",
+		"({id: 7, extra: undefined})", "({id: 7, save() {}})", "[NaN]", "({at: new Date(0)})",
+	} {
+		if data, err := ctx.EvalJSON(code); !errors.Is(err, ErrNotJSONSerializable) {
+			t.Errorf("EvalJSON(%s) = %s, %v, want ErrNotJSONSerializable", code, data, err)
+		}
+	}
+	if _, err := ctx.EvalJSON("throw new Error('boom')"); err == nil {
+		t.Error("EvalJSON of a throwing script should fail")
+	}
+}
+
+func TestEvalExpression(t *testing.T) {
+	ctx := newTestContext(t)
+
+	// As a script, {a:1} is a block holding the labeled statement a: 1
+	result, err := ctx.Eval("{a:1}")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if !result.IsNumber() || result.String() != "1" {
+		t.Errorf("Eval({a:1}) = %s, want 1", result.String())
+	}
+
+	result, err = ctx.EvalExpression("{a:1}")
+	if err != nil {
+		t.Fatalf("EvalExpression error = %v", err)
+	}
+	if !result.IsObject() {
+		t.Fatalf("EvalExpression({a:1}) = %s, want an object", result.String())
+	}
+	a, _ := result.Get("a")
+	if a.String() != "1" {
+		t.Errorf("a = %s, want 1", a.String())
+	}
+
+	if _, err := ctx.Eval("function(){}"); err == nil {
+		t.Error("Eval of an anonymous function declaration should fail")
+	}
+	result, err = ctx.EvalExpression("function(){ return 2 } // done")
+	if err != nil {
+		t.Fatalf("EvalExpression(function) error = %v", err)
+	}
+	if !result.IsFunction() {
+		t.Errorf("EvalExpression(function) = %s, want a function", result.String())
+	}
+
+	result, err = ctx.EvalExpression("1 + 2;")
+	if err != nil || result.String() != "3" {
+		t.Errorf("EvalExpression(1 + 2;) = %v, %v, want 3", result, err)
+	}
+
+	_, err = ctx.EvalExpression("let x = 1")
+	var jsErr *JSError
+	if !errors.As(err, &jsErr) || jsErr.Name != "SyntaxError" {
+		t.Errorf("EvalExpression(let) error = %v, want a SyntaxError", err)
+	}
+
+	// Code that closes the parentheses to run statements is rejected
+	// before any of it runs
+	for _, code := range []string{"1); globalThis.pwn = 1; (2", "1)\nglobalThis.pwn = 1\n(2", "0) || (globalThis.pwn = 1), (0"} {
+		_, err = ctx.EvalExpression(code)
+		if !errors.As(err, &jsErr) || jsErr.Name != "SyntaxError" {
+			t.Errorf("EvalExpression(%q) error = %v, want a SyntaxError", code, err)
+		}
+	}
+	if pwn, _ := ctx.Eval("typeof pwn"); pwn.String() != "undefined" {
+		t.Error("EvalExpression ran code outside the expression")
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"1 > 0", true},
+		{"0", false},
+		{`"x"`, true},
+		{`""`, false},
+		{"null", false},
+		{"undefined", false},
+		{"NaN", false},
+		{"({})", true},
+		{"[]", true},
+		{"0n", false},
+	}
+	for _, tt := range tests {
+		got, err := ctx.EvalBool(tt.code)
+		if err != nil {
+			t.Errorf("EvalBool(%q) error = %v", tt.code, err)
+		} else if got != tt.want {
+			t.Errorf("EvalBool(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+
+	if _, err := ctx.EvalBool("1 >"); err == nil {
+		t.Error("EvalBool with a syntax error should fail")
+	}
+	if _, err := ctx.EvalBool("throw new Error('no')"); err == nil {
+		t.Error("EvalBool of a throwing script should fail")
+	}
+}
+
+func TestEvalDeferJobs(t *testing.T) {
+	ctx := newTestContext(t)
+
+	result, err := ctx.EvalDeferJobs(`
+		var fired = false;
+		Promise.resolve(41).then((n) => { fired = true; return n + 1; });
+	`)
+	if err != nil {
+		t.Fatalf("EvalDeferJobs error = %v", err)
 	}
-	if name.String() != "John" {
-		t.Errorf("name = %q, want %q", name.String(), "John")
+	if !result.IsPromise() {
+		t.Fatalf("EvalDeferJobs should return the promise, got %s", result.Typeof())
+	}
+	if fired, _ := ctx.Eval("fired"); fired.Bool() {
+		t.Fatal(".then fired before the jobs were run")
 	}
 
-	// Stringify
-	jsonStr, err := obj.JSONStringify()
+	n, err := ctx.runtime.ExecutePendingJobs()
 	if err != nil {
-		t.Fatalf("JSONStringify error = %v", err)
+		t.Fatalf("ExecutePendingJobs error = %v", err)
 	}
-	if !strings.Contains(jsonStr, "John") {
-		t.Errorf("JSONStringify should contain 'John', got %q", jsonStr)
+	if n != 1 {
+		t.Errorf("ExecutePendingJobs ran %d jobs, want 1", n)
+	}
+	if fired, _ := ctx.Eval("fired"); !fired.Bool() {
+		t.Error(".then did not fire after ExecutePendingJobs")
 	}
-}
-
-// ============================================================================
-// Print/Console
-// ============================================================================
 
-func TestPrint(t *testing.T) {
-	rt, err := NewRuntime()
-	if err != nil {
-		t.Fatalf("NewRuntime() error = %v", err)
+	ctx.SetGlobal("p", result)
+	ctx.Eval("p.then((n) => { globalThis.got = n; })")
+	ctx.runtime.ExecutePendingJobs()
+	if got, _ := ctx.Eval("got"); got.String() != "42" {
+		t.Errorf("promise result = %s, want 42", got.String())
 	}
-	defer rt.Close()
+}
 
-	var logs []string
-	rt.SetLogFunc(func(msg string) {
-		logs = append(logs, msg)
+func TestUncaughtExceptionHandler(t *testing.T) {
+	ctx := newTestContext(t)
+	rt := ctx.runtime
+
+	var caught []error
+	rt.SetUncaughtExceptionHandler(func(err error) {
+		caught = append(caught, err)
 	})
 
-	ctx, err := rt.NewContext()
+	_, err := ctx.Eval(`
+		var ran = false;
+		queueMicrotask(() => { throw new TypeError('boom') });
+		queueMicrotask(() => { ran = true });
+	`)
 	if err != nil {
-		t.Fatalf("NewContext() error = %v", err)
+		t.Fatalf("Eval error = %v", err)
 	}
-	defer ctx.Close()
 
-	_, err = ctx.Eval(`print("hello"); print("world");`)
-	if err != nil {
-		t.Fatalf("Eval error = %v", err)
+	if _, err := rt.ExecutePendingJobs(); err != nil {
+		t.Fatalf("ExecutePendingJobs error = %v", err)
 	}
 
-	allLogs := strings.Join(logs, "")
-	if !strings.Contains(allLogs, "hello") {
-		t.Errorf("logs should contain %q, got %v", "hello", logs)
+	if len(caught) != 1 {
+		t.Fatalf("handler called %d times, want 1", len(caught))
 	}
-	if !strings.Contains(allLogs, "world") {
-		t.Errorf("logs should contain %q, got %v", "world", logs)
+	var jsErr *JSError
+	if !errors.As(caught[0], &jsErr) || jsErr.Name != "TypeError" || jsErr.Message != "boom" {
+		t.Errorf("handler error = %v, want TypeError: boom", caught[0])
+	}
+
+	// Jobs after the failing one still run
+	ran, _ := ctx.Eval("ran")
+	if !ran.Bool() {
+		t.Errorf("job after the throwing job did not run")
 	}
 }
 
@@ -1440,6 +3659,38 @@ func TestEvalEmptyString(t *testing.T) {
 	}
 }
 
+func TestEvalResult(t *testing.T) {
+	ctx := newTestContext(t)
+
+	result, threw, err := ctx.EvalResult("")
+	if err != nil || threw {
+		t.Fatalf("EvalResult('') = _, %v, %v, want no exception", threw, err)
+	}
+	if !result.IsUndefined() {
+		t.Errorf("EvalResult('') = %v, want undefined", result.String())
+	}
+
+	// Throwing undefined still counts as an exception
+	for _, code := range []string{"throw undefined", "null.x"} {
+		_, threw, err = ctx.EvalResult(code)
+		if !threw {
+			t.Errorf("EvalResult(%q) threw = false, want true", code)
+		}
+		var jsErr *JSError
+		if !errors.As(err, &jsErr) {
+			t.Errorf("EvalResult(%q) error = %v, want a *JSError", code, err)
+		}
+	}
+
+	result, threw, err = ctx.EvalResult("try { throw 1 } catch { } 40 + 2")
+	if err != nil || threw {
+		t.Fatalf("EvalResult = _, %v, %v, want no exception", threw, err)
+	}
+	if n, _ := result.Int32(); n != 42 {
+		t.Errorf("EvalResult = %d, want 42", n)
+	}
+}
+
 func TestEvalSyntaxError(t *testing.T) {
 	rt, err := NewRuntime()
 	if err != nil {
@@ -1598,6 +3849,9 @@ func TestLargeNumbers(t *testing.T) {
 	if str != "Infinity" {
 		t.Errorf("Infinity = %v, want 'Infinity'", str)
 	}
+	if result.IsFinite() || result.IsNaN() {
+		t.Errorf("Infinity: IsFinite() = %v, IsNaN() = %v; want false, false", result.IsFinite(), result.IsNaN())
+	}
 
 	// Test NaN
 	result, _ = ctx.Eval("NaN")
@@ -1605,6 +3859,89 @@ func TestLargeNumbers(t *testing.T) {
 	if str != "NaN" {
 		t.Errorf("NaN = %v, want 'NaN'", str)
 	}
+	if !result.IsNaN() || result.IsFinite() {
+		t.Errorf("NaN: IsNaN() = %v, IsFinite() = %v; want true, false", result.IsNaN(), result.IsFinite())
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"1e21", "1e+21"},
+		{"123456789012345680", "123456789012345680"},
+		{"1e20", "100000000000000000000"},
+		{"0.000001", "0.000001"},
+		{"1e-7", "1e-7"},
+		{"-0", "0"},
+		{"-1.5", "-1.5"},
+		{"2 ** 53", "9007199254740992"},
+		{"NaN", "NaN"},
+		{"-Infinity", "-Infinity"},
+	}
+	for _, tt := range tests {
+		val, err := ctx.Eval(tt.code)
+		if err != nil {
+			t.Fatalf("Eval(%s) error = %v", tt.code, err)
+		}
+		got, err := val.DecimalString()
+		if err != nil {
+			t.Fatalf("DecimalString(%s) error = %v", tt.code, err)
+		}
+		if got != tt.want {
+			t.Errorf("DecimalString(%s) = %q, want %q", tt.code, got, tt.want)
+		}
+		js, _ := ctx.Eval("(" + tt.code + ").toString()")
+		if got != js.String() {
+			t.Errorf("DecimalString(%s) = %q, but toString() gives %q", tt.code, got, js.String())
+		}
+	}
+
+	for _, code := range []string{"'1.5'", "1n", "null"} {
+		val, _ := ctx.Eval(code)
+		if _, err := val.DecimalString(); err == nil {
+			t.Errorf("DecimalString(%s) should fail", code)
+		}
+	}
+}
+
+func TestIsNaNIsFinite(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tests := []struct {
+		code          string
+		nan, isFinite bool
+	}{
+		{"NaN", true, false},
+		{"0 / 0", true, false},
+		{"Infinity", false, false},
+		{"-Infinity", false, false},
+		{"42", false, true},
+		{"-0.5", false, true},
+		{"Number.MAX_VALUE", false, true},
+		{"'abc'", false, false},
+		{"'42'", false, false},
+		{"undefined", false, false},
+		{"({})", false, false},
+		{"10n", false, false},
+	}
+	for _, tt := range tests {
+		val, err := ctx.Eval("(" + tt.code + ")")
+		if err != nil {
+			t.Fatalf("Eval(%s) error = %v", tt.code, err)
+		}
+		if got := val.IsNaN(); got != tt.nan {
+			t.Errorf("IsNaN(%s) = %v, want %v", tt.code, got, tt.nan)
+		}
+		if got := val.IsFinite(); got != tt.isFinite {
+			t.Errorf("IsFinite(%s) = %v, want %v", tt.code, got, tt.isFinite)
+		}
+		val.free()
+	}
 }
 
 func TestSpecialStrings(t *testing.T) {
@@ -1851,6 +4188,88 @@ func TestArrayOperationsFromGo(t *testing.T) {
 	if sum != 100 { // 0+10+20+30+40
 		t.Errorf("Array sum = %d, want 100", sum)
 	}
+
+	// Array methods called from Go
+	list := ctx.Array()
+
+	n, err := list.Push(ctx.Int32(2), ctx.Int32(3), ctx.Int32(4))
+	if err != nil {
+		t.Fatalf("Push error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Push() = %d, want 3", n)
+	}
+
+	n, err = list.Unshift(ctx.Int32(1))
+	if err != nil {
+		t.Fatalf("Unshift error = %v", err)
+	}
+	if n != 4 {
+		t.Errorf("Unshift() = %d, want 4", n)
+	}
+
+	last, err := list.Pop()
+	if err != nil {
+		t.Fatalf("Pop error = %v", err)
+	}
+	if last.String() != "4" {
+		t.Errorf("Pop() = %q, want %q", last.String(), "4")
+	}
+
+	first, err := list.Shift()
+	if err != nil {
+		t.Fatalf("Shift error = %v", err)
+	}
+	if first.String() != "1" {
+		t.Errorf("Shift() = %q, want %q", first.String(), "1")
+	}
+
+	list.Push(ctx.Int32(5), ctx.Int32(6))
+	sliced, err := list.Slice(1, -1)
+	if err != nil {
+		t.Fatalf("Slice error = %v", err)
+	}
+
+	elems, err := sliced.ToSlice()
+	if err != nil {
+		t.Fatalf("ToSlice error = %v", err)
+	}
+	var got []string
+	for _, elem := range elems {
+		got = append(got, elem.String())
+	}
+	if strings.Join(got, ",") != "3,5" {
+		t.Errorf("Slice(1, -1) = %v, want [3 5]", got)
+	}
+
+	tail, err := list.SliceFrom(-2)
+	if err != nil {
+		t.Fatalf("SliceFrom error = %v", err)
+	}
+	if tail.Len() != 2 || tail.String() != "5,6" {
+		t.Errorf("SliceFrom(-2) = %q, want %q", tail.String(), "5,6")
+	}
+
+	elems, err = list.ToSlice()
+	if err != nil {
+		t.Fatalf("ToSlice error = %v", err)
+	}
+	if len(elems) != 4 {
+		t.Errorf("len(ToSlice()) = %d, want 4", len(elems))
+	}
+
+	empty := ctx.Array()
+	popped, err := empty.Pop()
+	if err != nil {
+		t.Fatalf("Pop error = %v", err)
+	}
+	if !popped.IsUndefined() {
+		t.Errorf("Pop() on empty array = %s, want undefined", popped.String())
+	}
+
+	if _, err := ctx.Object().ToSlice(); err == nil {
+		t.Errorf("ToSlice on an object should fail")
+	}
 }
 
 func TestClosurePreservation(t *testing.T) {
@@ -2380,6 +4799,85 @@ func BenchmarkJSONParse(b *testing.B) {
 	}
 }
 
+// largeJSONDocument returns a JSON array of about a megabyte.
+func largeJSONDocument(tb testing.TB) []byte {
+	items := make([]map[string]any, 10000)
+	for i := range items {
+		items[i] = map[string]any{"id": i, "name": fmt.Sprintf("item-%d", i), "tags": []string{"x", "y"}, "score": float64(i) / 3}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		tb.Fatalf("json.Marshal error = %v", err)
+	}
+	return data
+}
+
+// BenchmarkParseJSONLarge benchmarks ParseJSON on a large document held as bytes
+func BenchmarkParseJSONLarge(b *testing.B) {
+	ctx := newTestContext(b)
+	data := largeJSONDocument(b)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for b.Loop() {
+		val, err := ctx.ParseJSON(string(data))
+		if err != nil {
+			b.Fatalf("ParseJSON error = %v", err)
+		}
+		val.free()
+	}
+}
+
+// BenchmarkValueFromJSONBytes benchmarks ValueFromJSONBytes on the same document
+func BenchmarkValueFromJSONBytes(b *testing.B) {
+	ctx := newTestContext(b)
+	data := largeJSONDocument(b)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for b.Loop() {
+		val, err := ctx.ValueFromJSONBytes(data)
+		if err != nil {
+			b.Fatalf("ValueFromJSONBytes error = %v", err)
+		}
+		val.free()
+	}
+}
+
+// BenchmarkStringToBytes benchmarks converting a string through String
+func BenchmarkStringToBytes(b *testing.B) {
+	ctx := newTestContext(b)
+	val, err := ctx.Eval("'x'.repeat(32 * 1024)")
+	if err != nil {
+		b.Fatalf("Eval error = %v", err)
+	}
+	defer val.free()
+
+	b.SetBytes(32 * 1024)
+	b.ResetTimer()
+	for b.Loop() {
+		_ = []byte(val.String())
+	}
+}
+
+// BenchmarkStringBytes benchmarks StringBytes on the same string
+func BenchmarkStringBytes(b *testing.B) {
+	ctx := newTestContext(b)
+	val, err := ctx.Eval("'x'.repeat(32 * 1024)")
+	if err != nil {
+		b.Fatalf("Eval error = %v", err)
+	}
+	defer val.free()
+
+	b.SetBytes(32 * 1024)
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := val.StringBytes(); err != nil {
+			b.Fatalf("StringBytes error = %v", err)
+		}
+	}
+}
+
 // BenchmarkArrayOperations benchmarks array operations
 func BenchmarkArrayOperations(b *testing.B) {
 	rt, err := NewRuntime()
@@ -2402,3 +4900,187 @@ func BenchmarkArrayOperations(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkStringArray benchmarks building a string array with StringArray
+func BenchmarkStringArray(b *testing.B) {
+	rt, err := NewRuntime()
+	if err != nil {
+		b.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer rt.Close()
+
+	ctx, err := rt.NewContext()
+	if err != nil {
+		b.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx.Close()
+
+	ss := make([]string, 1000)
+	for i := range ss {
+		ss[i] = fmt.Sprintf("item-%d", i)
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		ctx.StringArray(ss).free()
+	}
+}
+
+// benchmarkValues returns n numbers for the ArrayFrom benchmarks.
+func benchmarkValues(ctx *Context, n int) []Value {
+	vals := make([]Value, n)
+	for i := range vals {
+		vals[i] = ctx.Int32(int32(i))
+	}
+	return vals
+}
+
+func BenchmarkArrayFrom(b *testing.B) {
+	ctx := newTestContext(b)
+	vals := benchmarkValues(ctx, 10000)
+
+	b.ResetTimer()
+	for b.Loop() {
+		ctx.ArrayFrom(vals).free()
+	}
+}
+
+// BenchmarkArraySetIdx benchmarks building the same array element by element
+func BenchmarkArraySetIdx(b *testing.B) {
+	ctx := newTestContext(b)
+	vals := benchmarkValues(ctx, 10000)
+
+	b.ResetTimer()
+	for b.Loop() {
+		arr := ctx.Array()
+		for i, val := range vals {
+			arr.SetIdx(i, val)
+		}
+		arr.free()
+	}
+}
+
+// BenchmarkMarshalStringSlice benchmarks building the same array with Marshal
+func BenchmarkMarshalStringSlice(b *testing.B) {
+	rt, err := NewRuntime()
+	if err != nil {
+		b.Fatalf("NewRuntime() error = %v", err)
+	}
+	defer rt.Close()
+
+	ctx, err := rt.NewContext()
+	if err != nil {
+		b.Fatalf("NewContext() error = %v", err)
+	}
+	defer ctx.Close()
+
+	ss := make([]string, 1000)
+	for i := range ss {
+		ss[i] = fmt.Sprintf("item-%d", i)
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		arr, err := ctx.Marshal(ss)
+		if err != nil {
+			b.Fatalf("Marshal error = %v", err)
+		}
+		arr.free()
+	}
+}
+
+// BenchmarkFloat32Slice benchmarks reading a Float32Array with Float32Slice
+func BenchmarkFloat32Slice(b *testing.B) {
+	ctx := newTestContext(b)
+	arr, err := ctx.Eval("Float32Array.from({length: 10000}, (_, i) => i / 3)")
+	if err != nil {
+		b.Fatalf("Eval error = %v", err)
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := arr.Float32Slice(); err != nil {
+			b.Fatalf("Float32Slice error = %v", err)
+		}
+	}
+}
+
+// BenchmarkFloat32Elements benchmarks reading the same array element by element
+func BenchmarkFloat32Elements(b *testing.B) {
+	ctx := newTestContext(b)
+	arr, err := ctx.Eval("Float32Array.from({length: 10000}, (_, i) => i / 3)")
+	if err != nil {
+		b.Fatalf("Eval error = %v", err)
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		n := arr.Len()
+		out := make([]float32, n)
+		for i := range n {
+			elem, _ := arr.GetIdx(i)
+			f, _ := elem.Float64()
+			out[i] = float32(f)
+			elem.free()
+		}
+	}
+}
+
+func TestReadOnlyView(t *testing.T) {
+	ctx := newTestContext(t)
+
+	host, err := ctx.Eval(`({name: "host", limits: {cpu: 2}, tags: ["a"], count() { return this.tags.length; }})`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	ctx.SetGlobal("host", host)
+	view, err := host.ReadOnlyView()
+	if err != nil {
+		t.Fatalf("ReadOnlyView error = %v", err)
+	}
+	ctx.SetGlobal("view", view)
+
+	got, err := ctx.Eval(`[view.name, view.limits.cpu, view.tags[0], view.count(), JSON.stringify(view), Array.isArray(view.tags)].join()`)
+	if err != nil {
+		t.Fatalf("reading view error = %v", err)
+	}
+	if want := `host,2,a,1,{"name":"host","limits":{"cpu":2},"tags":["a"]},true`; got.String() != want {
+		t.Errorf("reads = %s, want %s", got.String(), want)
+	}
+
+	for _, code := range []string{
+		`view.name = "evil"`,
+		`view.limits.cpu = 64`,
+		`view.tags.push("b")`,
+		`delete view.name`,
+		`Object.defineProperty(view, "x", {value: 1})`,
+		`Object.setPrototypeOf(view, null)`,
+		`Object.freeze(view.limits)`,
+		`Object.getOwnPropertyDescriptor(view, "limits").value.cpu = 64`,
+	} {
+		_, err := ctx.Eval(`"use strict"; ` + code)
+		var jsErr *JSError
+		if !errors.As(err, &jsErr) || jsErr.Name != "TypeError" {
+			t.Errorf("%s error = %v, want a TypeError", code, err)
+		}
+	}
+	// Sloppy mode writes fail silently
+	if _, err := ctx.Eval(`view.name = "evil"; delete view.tags`); err != nil {
+		t.Errorf("sloppy write error = %v", err)
+	}
+
+	got, _ = ctx.Eval(`JSON.stringify(host)`)
+	if want := `{"name":"host","limits":{"cpu":2},"tags":["a"]}`; got.String() != want {
+		t.Errorf("host = %s, want %s", got.String(), want)
+	}
+
+	// Changes made by the host show through the view
+	ctx.Eval(`host.limits.cpu = 4`)
+	if got, _ := ctx.Eval(`view.limits.cpu`); got.String() != "4" {
+		t.Errorf("view.limits.cpu = %s, want 4", got.String())
+	}
+
+	if _, err := ctx.Int32(1).ReadOnlyView(); err == nil {
+		t.Error("ReadOnlyView of a number should fail")
+	}
+}
@@ -0,0 +1,234 @@
+package quickjs
+
+import "strings"
+
+// EvalREPL evaluates a line of interactive input like Eval, but lets it
+// declare again names that earlier input declared with let or const, as
+// browser consoles do. Plain Eval keeps such bindings too, but evaluating
+// "const x = 2" after "const x = 1" is a redeclaration error.
+//
+// To allow this, top-level let and const declarations are rewritten to var
+// before the code runs, so the names become properties of the global
+// object. As a result, a const declared this way can be assigned to, and
+// names declared with let or const by plain Eval cannot be redeclared
+// through EvalREPL. Declarations inside blocks and functions are left
+// alone, as are class declarations.
+func (c *Context) EvalREPL(code string) (Value, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	code, err := c.runtime.transformSource("<eval>", code)
+	if err != nil {
+		return Value{}, err
+	}
+	return c.evalFile(replDeclarations(code), "<eval>")
+}
+
+// replDeclarations rewrites the top-level let and const declarations of a
+// script to var, padding with spaces so positions in error messages do not
+// move. It only looks at code outside any brackets, skipping strings,
+// template literals, comments and regular expressions as scanJS does.
+func replDeclarations(code string) string {
+	var out []byte // a copy of code, made on the first rewrite
+	prev := byte(0)
+	scanJS(code, func(t jsToken) {
+		if t.depth == 0 && prev != '.' && (t.word == "let" || t.word == "const") && startsBinding(code, t.end) {
+			if out == nil {
+				out = []byte(code)
+			}
+			copy(out[t.start:t.end], "var"+strings.Repeat(" ", len(t.word)-3))
+		}
+		prev = code[t.end-1]
+	})
+	if out == nil {
+		return code
+	}
+	return string(out)
+}
+
+// jsToken is a token of JavaScript source found by scanJS.
+type jsToken struct {
+	start, end int    // position in the source
+	depth      int    // bracket depth around the token
+	word       string // the identifier, keyword or number, if it is one
+	newline    bool   // a line break comes before it
+}
+
+// scanJS calls fn with each token of code, in order: a whole string,
+// regular expression, word or piece of template literal text, or a single
+// character of punctuation. Whitespace and comments are skipped. Brackets
+// are counted, with both brackets of a pair at the depth around them, and
+// the code inside a template substitution is one level deeper than the
+// template.
+func scanJS(code string, fn func(t jsToken)) {
+	depth := 0
+	var templates []int // depth at each ${ that opened a template substitution
+	prev := byte(0)     // last significant character, 0 at the start
+	prevWord := ""      // last word, if prev ends one
+	newline := false
+
+	for i := 0; i < len(code); {
+		b := code[i]
+		switch {
+		case b == '\n':
+			newline = true
+			i++
+			continue
+		case b == ' ' || b == '\t' || b == '\r':
+			i++
+			continue
+		case strings.HasPrefix(code[i:], "//"):
+			i = skipPast(code, i+2, "\n")
+			newline = true
+			continue
+		case strings.HasPrefix(code[i:], "/*"):
+			end := skipPast(code, i+2, "*/")
+			newline = newline || strings.Contains(code[i:end], "\n")
+			i = end
+			continue
+		}
+		t := jsToken{start: i, depth: depth, newline: newline}
+		switch {
+		case b == '\'' || b == '"':
+			i = skipQuoted(code, i)
+		case b == '`':
+			i = skipTemplate(code, i+1)
+			if strings.HasSuffix(code[:i], "${") {
+				depth++
+				templates = append(templates, depth)
+			}
+		case b == '/' && regexAllowed(prev, prevWord):
+			i = skipRegexp(code, i)
+		case b == '(' || b == '[' || b == '{':
+			depth++
+			i++
+		case b == '}' && len(templates) > 0 && templates[len(templates)-1] == depth:
+			templates = templates[:len(templates)-1]
+			depth--
+			t.depth = depth
+			i = skipTemplate(code, i+1)
+			if strings.HasSuffix(code[:i], "${") {
+				depth++
+				templates = append(templates, depth)
+			}
+		case b == ')' || b == ']' || b == '}':
+			depth--
+			t.depth = depth
+			i++
+		case isIdentifierPart(code, i):
+			for isIdentifierPart(code, i) {
+				i++
+			}
+			t.word = code[t.start:i]
+		default:
+			i++
+		}
+		t.end = i
+		fn(t)
+		prev, prevWord = code[i-1], t.word
+		newline = false
+	}
+}
+
+// startsBinding reports whether the code after a let or const keyword at
+// position i starts a binding, a name or a destructuring pattern, rather
+// than using let as an identifier, as in "let = 1" or "let in obj".
+func startsBinding(code string, i int) bool {
+	j := i
+	for j < len(code) && (code[j] == ' ' || code[j] == '\t' || code[j] == '\n' || code[j] == '\r') {
+		j++
+	}
+	if j < len(code) && (code[j] == '{' || code[j] == '[') {
+		return true
+	}
+	k := j
+	for isIdentifierPart(code, k) {
+		k++
+	}
+	word := code[j:k]
+	return word != "" && word != "in" && word != "instanceof" && !('0' <= word[0] && word[0] <= '9')
+}
+
+// regexKeywords are the keywords after which a slash starts a regular
+// expression rather than a division.
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// regexAllowed reports whether a slash after the given character and word
+// starts a regular expression: it does where an expression may start, but
+// not after a value such as a name, a literal or a closing bracket.
+func regexAllowed(prev byte, word string) bool {
+	if word != "" {
+		return regexKeywords[word]
+	}
+	return !strings.ContainsRune(")]}'\"`", rune(prev))
+}
+
+// skipPast returns the index just after the next occurrence of end at or
+// after i, or len(code).
+func skipPast(code string, i int, end string) int {
+	if j := strings.Index(code[i:], end); j >= 0 {
+		return i + j + len(end)
+	}
+	return len(code)
+}
+
+// skipQuoted returns the index just after the string literal at i.
+func skipQuoted(code string, i int) int {
+	quote := code[i]
+	for i++; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case quote, '\n':
+			return i + 1
+		}
+	}
+	return len(code)
+}
+
+// skipTemplate skips template literal text starting at i, returning the
+// index just after the closing backtick or after a "${".
+func skipTemplate(code string, i int) int {
+	for ; i < len(code); i++ {
+		switch {
+		case code[i] == '\\':
+			i++
+		case code[i] == '`':
+			return i + 1
+		case code[i] == '$' && i+1 < len(code) && code[i+1] == '{':
+			return i + 2
+		}
+	}
+	return len(code)
+}
+
+// skipRegexp returns the index just after the regular expression literal,
+// including its flags, at i.
+func skipRegexp(code string, i int) int {
+	inClass := false
+	for i++; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return i
+		case '/':
+			if !inClass {
+				i++
+				for isIdentifierPart(code, i) {
+					i++
+				}
+				return i
+			}
+		}
+	}
+	return len(code)
+}
@@ -0,0 +1,65 @@
+package quickjs
+
+import "testing"
+
+func TestEvalREPL(t *testing.T) {
+	ctx := newTestContext(t)
+
+	steps := []struct{ code, want string }{
+		{"const x = 1", "undefined"},
+		{"x", "1"},
+		{"const x = 2; x * 10", "20"},
+		{"let y = x + 1", "undefined"},
+		{"let y = y * 2; y", "6"},
+		{"const {a, b: [c]} = {a: 'A', b: ['C']}; a + c", "AC"},
+		{"const a = 'again'; a", "again"},
+		{"function f() { const x = 'inner'; return x } f() + x", "inner2"},
+		{"for (let i = 0; i < 2; i++) {}; typeof i", "undefined"},
+	}
+	for _, step := range steps {
+		result, err := ctx.EvalREPL(step.code)
+		if err != nil {
+			t.Fatalf("EvalREPL(%q) error = %v", step.code, err)
+		}
+		if result.String() != step.want {
+			t.Errorf("EvalREPL(%q) = %s, want %s", step.code, result.String(), step.want)
+		}
+	}
+
+	// The bindings are visible to plain Eval too
+	if result, _ := ctx.Eval("x + y"); result.String() != "8" {
+		t.Errorf("x + y = %s, want 8", result.String())
+	}
+
+	if _, err := ctx.EvalREPL("const = 1"); err == nil {
+		t.Error("EvalREPL with a syntax error should fail")
+	}
+}
+
+func TestREPLDeclarations(t *testing.T) {
+	tests := []struct{ code, want string }{
+		{"let x = 1", "var x = 1"},
+		{"const x = 1", "var   x = 1"},
+		{"const x = 1; let y = 2", "var   x = 1; var y = 2"},
+		{"let\nx = 1", "var\nx = 1"},
+		{"const [a] = b, {c} = d", "var   [a] = b, {c} = d"},
+		{"{ let x = 1 }", "{ let x = 1 }"},
+		{"if (a) { const x = 1 }", "if (a) { const x = 1 }"},
+		{"for (let i of xs) {}", "for (let i of xs) {}"},
+		{"() => { let x }", "() => { let x }"},
+		{"'let x = 1'", "'let x = 1'"},
+		{"\"const x\"", "\"const x\""},
+		{"`${a} let x ${ {b: 1}.b } `; let y", "`${a} let x ${ {b: 1}.b } `; var y"},
+		{"// let x\nlet y", "// let x\nvar y"},
+		{"/* const x */ const y = 1", "/* const x */ var   y = 1"},
+		{"x = /let [}]/; let y", "x = /let [}]/; var y"},
+		{"a / b; let y", "a / b; var y"},
+		{"obj.let; let = 5; let in obj", "obj.let; let = 5; let in obj"},
+		{"class A { m() { let x } }", "class A { m() { let x } }"},
+	}
+	for _, tt := range tests {
+		if got := replDeclarations(tt.code); got != tt.want {
+			t.Errorf("replDeclarations(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package quickjs
+
+import "errors"
+
+// WrapSlice returns a JavaScript array backed by the Go slice ptr points
+// to. It is a Proxy: reading an element or the length reads *ptr, and
+// writing one writes it, so both sides always see the same contents. An
+// element set past the end, as push does, appends to the slice, growing it
+// with nil elements if needed, and setting the length truncates or extends
+// it the same way. Array.isArray reports true and the array methods work.
+//
+// Elements are converted as Marshal does when read, and as Unmarshal does
+// into an any when written, so an object read back from the array is a
+// copy: changing its properties from JavaScript does not change the Go
+// element. A value Unmarshal cannot convert, such as a function, makes the
+// write throw a TypeError. The slice must not be used by other goroutines
+// while JavaScript runs.
+func (c *Context) WrapSlice(ptr *[]any) (Value, error) {
+	if ptr == nil {
+		return Value{}, errors.New("nil slice pointer")
+	}
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	resize := func(n int) {
+		if n <= len(*ptr) {
+			clear((*ptr)[n:])
+			*ptr = (*ptr)[:n]
+			return
+		}
+		*ptr = append(*ptr, make([]any, n-len(*ptr))...)
+	}
+	fns := []struct {
+		name string
+		fn   GoFunc
+	}{
+		{"length", func(ctx *Context, this Value, args []Value) Value {
+			return ctx.Int64(int64(len(*ptr)))
+		}},
+		{"get", func(ctx *Context, this Value, args []Value) Value {
+			i, _ := args[0].Int64()
+			if i < 0 || i >= int64(len(*ptr)) {
+				return ctx.Undefined()
+			}
+			val, err := ctx.Marshal((*ptr)[i])
+			if err != nil {
+				return ctx.ThrowTypeError(err.Error())
+			}
+			return val
+		}},
+		{"set", func(ctx *Context, this Value, args []Value) Value {
+			i, _ := args[0].Int64()
+			var x any
+			if err := args[1].Unmarshal(&x); err != nil {
+				return ctx.ThrowTypeError(err.Error())
+			}
+			if i >= int64(len(*ptr)) {
+				resize(int(i) + 1)
+			}
+			(*ptr)[i] = x
+			return ctx.Undefined()
+		}},
+		{"setLength", func(ctx *Context, this Value, args []Value) Value {
+			n, _ := args[0].Int64()
+			resize(int(n))
+			return ctx.Undefined()
+		}},
+	}
+
+	var args []Value
+	var funcIDs []uint32
+	defer func() {
+		for _, fn := range args {
+			fn.free()
+		}
+	}()
+	unregister := func() {
+		for _, id := range funcIDs {
+			c.runtime.bridge.UnregisterGoFunc(id)
+		}
+	}
+	for _, f := range fns {
+		val, funcID := c.newFunction(f.name, f.fn)
+		if funcID == 0 {
+			unregister()
+			return Value{}, errNewFunction
+		}
+		args = append(args, val)
+		funcIDs = append(funcIDs, funcID)
+	}
+
+	sliceProxy, err := c.helper("sliceProxy", sliceProxyJS)
+	if err == nil {
+		var proxy Value
+		this := c.undefinedUnlocked()
+		defer this.freeUnlocked()
+		proxy, err = sliceProxy.Call(this, args...)
+		if err == nil {
+			return proxy, nil
+		}
+	}
+	unregister()
+	return Value{}, err
+}
@@ -0,0 +1,63 @@
+package quickjs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrapSlice(t *testing.T) {
+	ctx := newTestContext(t)
+
+	items := []any{"a", 1.5}
+	arr, err := ctx.WrapSlice(&items)
+	if err != nil {
+		t.Fatalf("WrapSlice error = %v", err)
+	}
+	if err := ctx.SetGlobal("items", arr); err != nil {
+		t.Fatalf("SetGlobal error = %v", err)
+	}
+
+	val, err := ctx.Eval("items.push('b', {n: 2}); items.length")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if n, _ := val.Int32(); n != 4 {
+		t.Errorf("length after push = %d, want 4", n)
+	}
+	want := []any{"a", 1.5, "b", map[string]any{"n": float64(2)}}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %#v, want %#v", items, want)
+	}
+
+	// Changes made from Go are seen by JavaScript
+	items[0] = "changed"
+	items = append(items, true)
+	val, err = ctx.Eval("[Array.isArray(items), items.length, items[0], items.at(-1), JSON.stringify(items)].join(' ')")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if got, want := val.String(), `true 5 changed true ["changed",1.5,"b",{"n":2},true]`; got != want {
+		t.Errorf("Eval = %q, want %q", got, want)
+	}
+
+	if _, err := ctx.Eval("items[6] = 'far'; items.length = 8"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	want = []any{"changed", 1.5, "b", map[string]any{"n": float64(2)}, true, nil, "far", nil}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %#v, want %#v", items, want)
+	}
+	if _, err := ctx.Eval("items.splice(1, 6)"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if want := []any{"changed", nil}; !reflect.DeepEqual(items, want) {
+		t.Errorf("items after splice = %#v, want %#v", items, want)
+	}
+
+	if _, err := ctx.Eval("items.push(() => 1)"); err == nil {
+		t.Error("pushing a function should throw")
+	}
+	if _, err := ctx.WrapSlice(nil); err == nil {
+		t.Error("WrapSlice(nil) should fail")
+	}
+}
@@ -0,0 +1,286 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Timers are kept by the runtime and run by RunEventLoop, which alternates
+// them with promise jobs the way a browser alternates tasks and microtasks:
+// after each timer callback, every pending job runs before the next timer.
+
+// timer is a callback scheduled with setTimeout or setInterval.
+type timer struct {
+	id       int64
+	ctx      *Context
+	fn       Value
+	args     []Value
+	due      time.Time
+	interval time.Duration // how often it repeats, or 0 for a timeout
+}
+
+// free releases the timer's callback and arguments. Caller must hold the
+// mutex.
+func (t *timer) free() {
+	t.fn.freeUnlocked()
+	for _, arg := range t.args {
+		arg.freeUnlocked()
+	}
+}
+
+// installTimers defines setTimeout, setInterval, clearTimeout and
+// clearInterval in a new context. Caller must hold the mutex.
+func (c *Context) installTimers() error {
+	schedule := func(repeat bool) GoFunc {
+		return func(ctx *Context, this Value, args []Value) Value {
+			if len(args) == 0 || !args[0].IsFunction() {
+				for _, arg := range args {
+					arg.freeUnlocked()
+				}
+				return ctx.ThrowTypeError("callback is not a function")
+			}
+			var delay time.Duration
+			if len(args) > 1 {
+				ms, _ := args[1].Float64()
+				if ms > 0 && ms < math.MaxInt64/float64(time.Millisecond) {
+					delay = time.Duration(ms * float64(time.Millisecond))
+				}
+				args[1].freeUnlocked()
+			}
+			r := ctx.runtime
+			r.lastTimerID++
+			t := &timer{
+				id:   r.lastTimerID,
+				ctx:  ctx,
+				fn:   args[0],
+				args: args[min(len(args), 2):],
+				due:  time.Now().Add(delay),
+			}
+			if repeat {
+				t.interval = delay
+			}
+			if r.timers == nil {
+				r.timers = make(map[int64]*timer)
+			}
+			r.timers[t.id] = t
+			r.wakeLoop()
+			return ctx.Int64(t.id)
+		}
+	}
+	cancel := func(ctx *Context, this Value, args []Value) Value {
+		defer func() {
+			for _, arg := range args {
+				arg.freeUnlocked()
+			}
+		}()
+		if len(args) > 0 && args[0].IsNumber() {
+			id, _ := args[0].Int64()
+			if t, ok := ctx.runtime.timers[id]; ok && t.ctx == ctx {
+				delete(ctx.runtime.timers, id)
+				t.free()
+			}
+		}
+		return ctx.Undefined()
+	}
+
+	fns := []struct {
+		name string
+		fn   GoFunc
+	}{
+		{"setTimeout", schedule(false)},
+		{"setInterval", schedule(true)},
+		{"clearTimeout", cancel},
+		{"clearInterval", cancel},
+	}
+	for _, f := range fns {
+		val, _ := c.newFunction(f.name, f.fn)
+		err := c.SetGlobal(f.name, val)
+		val.freeUnlocked()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearTimers drops the timers of a context that is being closed. Caller
+// must hold the mutex.
+func (r *Runtime) clearTimers(c *Context) {
+	for id, t := range r.timers {
+		if t.ctx == c {
+			delete(r.timers, id)
+			t.free()
+		}
+	}
+}
+
+// PendingTimers returns the number of timers scheduled in this context with
+// setTimeout or setInterval that have not run or been cleared. An interval
+// counts until it is cleared.
+func (c *Context) PendingTimers() int {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	n := 0
+	for _, t := range c.runtime.timers {
+		if t.ctx == c {
+			n++
+		}
+	}
+	return n
+}
+
+// ClearAllTimers cancels every timer scheduled in this context, as if each
+// had been passed to clearTimeout, so that a context reused between
+// requests starts without callbacks left by earlier ones.
+func (c *Context) ClearAllTimers() {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+	c.runtime.clearTimers(c)
+}
+
+// RunEventLoop runs pending jobs and the callbacks of timers scheduled with
+// setTimeout and setInterval, which Config.Timers installs, until neither
+// is left and no call of a function made with AsyncFunction is still
+// running. It waits for such calls to finish, then runs the reactions to
+// the promises they settle. Each time a callback has run, all pending jobs
+// run before the next callback, so promise reactions queued by a callback
+// come before later timers, as in a browser. Timers that are due run in
+// order of their due time, then of scheduling. While waiting, the runtime
+// is unlocked, so other goroutines may use it.
+//
+// Once ctx is done, RunEventLoop returns an error wrapping ctx.Err(),
+// aborting any JavaScript then running as EvalWithContext does. Timers left
+// then still run on the next call. An exception thrown by a callback or a
+// job is passed to the handler set with SetUncaughtExceptionHandler and the
+// loop carries on; without a handler, RunEventLoop stops and returns it as
+// a *JSError. An interval that is never cleared keeps the loop running
+// until ctx is done.
+func (r *Runtime) RunEventLoop(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.runJobs(ctx); err != nil {
+			return err
+		}
+		t, wait, err := r.nextTimer()
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			if !r.awaitingCalls() {
+				return nil
+			}
+			select {
+			case <-r.loopWake:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		if wait > 0 {
+			sleep := time.NewTimer(wait)
+			select {
+			case <-sleep.C:
+			case <-r.loopWake:
+				sleep.Stop()
+			case <-ctx.Done():
+				sleep.Stop()
+				return ctx.Err()
+			}
+			continue
+		}
+		if err := r.runTimer(ctx, t); err != nil {
+			return err
+		}
+	}
+}
+
+// awaitingCalls reports whether any AsyncFunction call is still running.
+func (r *Runtime) awaitingCalls() bool {
+	r.lock()
+	defer r.unlock()
+	return r.asyncCalls > 0
+}
+
+// wakeLoop wakes RunEventLoop if it is waiting, to look for work again.
+// Caller must hold the mutex.
+func (r *Runtime) wakeLoop() {
+	select {
+	case r.loopWake <- struct{}{}:
+	default:
+	}
+}
+
+// runJobs runs pending jobs until none is left, aborting if ctx is done.
+func (r *Runtime) runJobs(ctx context.Context) error {
+	r.lock()
+	defer r.unlock()
+	_, err := r.interruptible(ctx, func() (Value, error) {
+		return r.withDeadline(func() (Value, error) {
+			for {
+				ran, err := r.executePendingJob()
+				if err != nil || !ran {
+					return Value{}, err
+				}
+			}
+		})
+	})
+	return err
+}
+
+// nextTimer returns the timer to run next and how long until it is due, or
+// nil if there are no timers.
+func (r *Runtime) nextTimer() (*timer, time.Duration, error) {
+	r.lock()
+	defer r.unlock()
+	if r.closed {
+		return nil, 0, ErrClosed
+	}
+	var next *timer
+	for _, t := range r.timers {
+		if next == nil || t.due.Before(next.due) || (t.due.Equal(next.due) && t.id < next.id) {
+			next = t
+		}
+	}
+	if next == nil {
+		return nil, 0, nil
+	}
+	return next, time.Until(next.due), nil
+}
+
+// runTimer runs the callback of t, unless it was cleared meanwhile, and
+// schedules it again if it repeats.
+func (r *Runtime) runTimer(ctx context.Context, t *timer) error {
+	r.lock()
+	defer r.unlock()
+	if r.timers[t.id] != t {
+		return nil
+	}
+	if t.interval == 0 {
+		delete(r.timers, t.id)
+		defer t.free()
+	}
+
+	undefined := t.ctx.undefinedUnlocked()
+	defer undefined.freeUnlocked()
+	result, err := r.interruptible(ctx, func() (Value, error) {
+		return t.fn.Call(undefined, t.args...)
+	})
+	if t.interval > 0 {
+		t.due = time.Now().Add(t.interval)
+	}
+	if err == nil {
+		result.freeUnlocked()
+		return nil
+	}
+
+	var jsErr *JSError
+	if r.uncaughtHandler == nil || !errors.As(err, &jsErr) || errors.Is(err, ErrInterrupted) {
+		return err
+	}
+	r.uncaughtHandler(err)
+	return nil
+}
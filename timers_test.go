@@ -0,0 +1,167 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTimersContext(t *testing.T) (*Runtime, *Context) {
+	t.Helper()
+	rt, err := NewRuntimeWithConfig(Config{Timers: true})
+	if err != nil {
+		t.Fatalf("NewRuntimeWithConfig error = %v", err)
+	}
+	t.Cleanup(func() { rt.Close() })
+	ctx, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	return rt, ctx
+}
+
+func TestRunEventLoop(t *testing.T) {
+	rt, ctx := newTimersContext(t)
+
+	_, err := ctx.Eval(`
+		var log = [];
+		setTimeout(() => log.push("late"), 30);
+		setTimeout((a, b) => {
+			log.push("timeout " + a + b);
+			Promise.resolve().then(() => log.push("microtask"));
+		}, 0, "x", "y");
+		setTimeout(() => log.push("second"), 0);
+		const cleared = setTimeout(() => log.push("cleared"), 0);
+		clearTimeout(cleared);
+		let ticks = 0;
+		const interval = setInterval(() => {
+			log.push("tick");
+			if (++ticks === 3) clearInterval(interval);
+		}, 5);
+		Promise.resolve().then(() => log.push("first job"));
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if err := rt.RunEventLoop(context.Background()); err != nil {
+		t.Fatalf("RunEventLoop error = %v", err)
+	}
+	val, err := ctx.Eval("log.join(', ')")
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	want := "first job, timeout xy, microtask, second, tick, tick, tick, late"
+	if got := val.String(); got != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+
+	if _, err := ctx.Eval("setTimeout('code', 0)"); err == nil {
+		t.Error("setTimeout with a string should throw")
+	}
+
+	// A thrown callback stops the loop without a handler
+	if _, err := ctx.Eval("setTimeout(() => { throw new Error('boom') }, 0)"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	var jsErr *JSError
+	if err := rt.RunEventLoop(context.Background()); !errors.As(err, &jsErr) || jsErr.Message != "boom" {
+		t.Errorf("RunEventLoop error = %v, want boom", err)
+	}
+}
+
+func TestClearAllTimers(t *testing.T) {
+	rt, ctx := newTimersContext(t)
+	other, err := rt.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext error = %v", err)
+	}
+	defer other.Close()
+
+	_, err = ctx.Eval(`
+		var fired = [];
+		setTimeout(() => fired.push("timeout"), 0);
+		setTimeout(() => fired.push("later"), 5);
+		setInterval(() => fired.push("interval"), 1);
+		clearTimeout(setTimeout(() => fired.push("cleared"), 0));
+	`)
+	if err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if _, err := other.Eval("var ran = false; setTimeout(() => { ran = true }, 0)"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	if n := ctx.PendingTimers(); n != 3 {
+		t.Errorf("PendingTimers = %d, want 3", n)
+	}
+	if n := other.PendingTimers(); n != 1 {
+		t.Errorf("PendingTimers of the other context = %d, want 1", n)
+	}
+
+	ctx.ClearAllTimers()
+	if n := ctx.PendingTimers(); n != 0 {
+		t.Errorf("PendingTimers after ClearAllTimers = %d, want 0", n)
+	}
+	if err := rt.RunEventLoop(context.Background()); err != nil {
+		t.Fatalf("RunEventLoop error = %v", err)
+	}
+	if val, _ := ctx.Eval("fired.length"); val.String() != "0" {
+		fired, _ := ctx.Eval("fired.join(', ')")
+		t.Errorf("cleared timers fired: %s", fired.String())
+	}
+	// Timers of other contexts are left alone
+	if val, _ := other.Eval("ran"); !val.Bool() {
+		t.Error("timer of the other context did not run")
+	}
+	if n := other.PendingTimers(); n != 0 {
+		t.Errorf("PendingTimers after it ran = %d, want 0", n)
+	}
+}
+
+func TestRunEventLoopCanceled(t *testing.T) {
+	rt, ctx := newTimersContext(t)
+
+	if _, err := ctx.Eval("var n = 0; setInterval(() => n++, 1)"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := rt.RunEventLoop(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunEventLoop error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n, _ := ctx.Eval("n"); n.String() == "0" {
+		t.Error("interval never ran")
+	}
+
+	// A runaway callback is interrupted
+	if _, err := ctx.Eval("clearInterval(1); setTimeout(() => { for (;;) {} }, 0)"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	timeout, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := rt.RunEventLoop(timeout); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("RunEventLoop error = %v, want ErrInterrupted", err)
+	}
+
+	// Closing the context drops its timers
+	if _, err := ctx.Eval("setTimeout(() => {}, 10000)"); err != nil {
+		t.Fatalf("Eval error = %v", err)
+	}
+	ctx.Close()
+	done := make(chan error, 1)
+	go func() { done <- rt.RunEventLoop(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunEventLoop error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunEventLoop did not return after the context was closed")
+	}
+
+	// Without Config.Timers there are no timer functions
+	plain := newTestContext(t)
+	if val, _ := plain.Eval("typeof setTimeout"); val.String() != "undefined" {
+		t.Errorf("typeof setTimeout = %q without Config.Timers", val.String())
+	}
+}
@@ -0,0 +1,74 @@
+package quickjs
+
+import "errors"
+
+// Transformer applies a compiled JavaScript function to JSON documents. It
+// is created by Context.Transformer and belongs to that context.
+type Transformer struct {
+	ctx *Context
+	fn  Value
+}
+
+// Transformer compiles funcSource, the source of a function of one
+// argument such as "(doc) => doc.items.length", for use with Apply. The
+// source is compiled once, so applying it to many documents only pays for
+// parsing, calling and stringifying each one.
+func (c *Context) Transformer(funcSource string) (*Transformer, error) {
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	fn, err := c.evalFile("("+funcSource+")", "<transformer>")
+	if err != nil {
+		return nil, err
+	}
+	if !fn.IsFunction() {
+		fn.free()
+		return nil, errors.New("transformer source is not a function")
+	}
+	return &Transformer{ctx: c, fn: fn}, nil
+}
+
+// Apply parses jsonInput, calls the function with the result, and returns
+// the JSON of what it returns. Invalid input returns a SyntaxError, an
+// exception thrown by the function returns it as a *JSError, and a result
+// JSON cannot represent, such as undefined, returns a TypeError.
+func (t *Transformer) Apply(jsonInput []byte) ([]byte, error) {
+	c := t.ctx
+	c.runtime.lock()
+	defer c.runtime.unlock()
+
+	if t.fn.ctx == nil {
+		return nil, errors.New("transformer is closed")
+	}
+	applyJSON, err := c.helper("applyJSON", applyJSONJS)
+	if err != nil {
+		return nil, err
+	}
+	valPtr, err := c.runtime.bridge.JSONParseBytes(c.runtime.goCtx, c.ctxPtr, jsonInput)
+	if err != nil {
+		return nil, err
+	}
+	input, err := c.checkException(valPtr)
+	if err != nil {
+		return nil, err
+	}
+	defer input.free()
+
+	this := c.undefinedUnlocked()
+	defer this.freeUnlocked()
+	result, err := applyJSON.Call(this, t.fn, input)
+	if err != nil {
+		return nil, err
+	}
+	defer result.free()
+	return result.StringBytes()
+}
+
+// Close releases the compiled function. The transformer cannot be used
+// afterwards.
+func (t *Transformer) Close() {
+	t.ctx.runtime.lock()
+	defer t.ctx.runtime.unlock()
+	t.fn.free()
+	t.fn = Value{}
+}
@@ -0,0 +1,129 @@
+package quickjs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransformer(t *testing.T) {
+	ctx := newTestContext(t)
+
+	tr, err := ctx.Transformer(`(order) => ({
+		id: order.id,
+		total: order.items.reduce((sum, item) => sum + item.price * item.qty, 0),
+	})`)
+	if err != nil {
+		t.Fatalf("Transformer error = %v", err)
+	}
+	defer tr.Close()
+
+	for i := range 100 {
+		input := fmt.Sprintf(`{"id": %d, "items": [{"price": 2.5, "qty": %d}, {"price": 10, "qty": 1}]}`, i, i)
+		out, err := tr.Apply([]byte(input))
+		if err != nil {
+			t.Fatalf("Apply(%s) error = %v", input, err)
+		}
+		var got map[string]any
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatalf("json.Unmarshal(%s) error = %v", out, err)
+		}
+		want := map[string]any{"id": float64(i), "total": 2.5*float64(i) + 10}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Apply(%s) = %s, want %v", input, out, want)
+		}
+	}
+
+	// Output is not limited in size
+	big, err := ctx.Transformer(`(n) => "x".repeat(n)`)
+	if err != nil {
+		t.Fatalf("Transformer error = %v", err)
+	}
+	defer big.Close()
+	out, err := big.Apply([]byte("100000"))
+	if err != nil {
+		t.Fatalf("Apply error = %v", err)
+	}
+	if want := `"` + strings.Repeat("x", 100000) + `"`; string(out) != want {
+		t.Errorf("Apply returned %d bytes, want %d", len(out), len(want))
+	}
+}
+
+func TestTransformerErrors(t *testing.T) {
+	ctx := newTestContext(t)
+
+	for _, src := range []string{"42", "(x) =>", "'not a function'"} {
+		if _, err := ctx.Transformer(src); err == nil {
+			t.Errorf("Transformer(%q) should fail", src)
+		}
+	}
+
+	tr, err := ctx.Transformer(`(x) => { if (x.fail) throw new Error("boom"); return x.value }`)
+	if err != nil {
+		t.Fatalf("Transformer error = %v", err)
+	}
+
+	for _, input := range []string{`{`, `{"fail": true}`, `{}`} {
+		if _, err := tr.Apply([]byte(input)); err == nil {
+			t.Errorf("Apply(%s) should fail", input)
+		}
+	}
+	if out, err := tr.Apply([]byte(`{"value": [1]}`)); err != nil || string(out) != "[1]" {
+		t.Errorf("Apply after errors = %s, %v, want [1]", out, err)
+	}
+
+	tr.Close()
+	if _, err := tr.Apply([]byte(`{}`)); err == nil {
+		t.Error("Apply after Close should fail")
+	}
+}
+
+// TestTransformerManyCalls applies a transformer more times than there are
+// value slots, which only works if each call gives back the slots it takes.
+func TestTransformerManyCalls(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping stress test in short mode")
+	}
+	ctx := newTestContext(t)
+	tr, err := ctx.Transformer(`(x) => x + 1`)
+	if err != nil {
+		t.Fatalf("Transformer error = %v", err)
+	}
+	defer tr.Close()
+
+	for i := range 70000 {
+		out, err := tr.Apply([]byte("1"))
+		if err != nil {
+			t.Fatalf("Apply #%d error = %v", i, err)
+		}
+		if string(out) != "2" {
+			t.Fatalf("Apply #%d = %s, want 2", i, out)
+		}
+	}
+}
+
+// BenchmarkTransformer benchmarks applying a transformer to 10k payloads
+func BenchmarkTransformer(b *testing.B) {
+	ctx := newTestContext(b)
+	tr, err := ctx.Transformer(`(u) => ({name: u.first + " " + u.last, adult: u.age >= 18})`)
+	if err != nil {
+		b.Fatalf("Transformer error = %v", err)
+	}
+	defer tr.Close()
+
+	payloads := make([][]byte, 10000)
+	for i := range payloads {
+		payloads[i] = fmt.Appendf(nil, `{"first": "User", "last": "%d", "age": %d}`, i, i%40)
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		for _, p := range payloads {
+			if _, err := tr.Apply(p); err != nil {
+				b.Fatalf("Apply error = %v", err)
+			}
+		}
+	}
+}